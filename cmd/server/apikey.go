@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/TXM983/wallpaper-api-v1/internal/middleware"
+	"github.com/TXM983/wallpaper-api-v1/internal/service"
+	utils "github.com/TXM983/wallpaper-api-v1/internal/util"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// 创建 API Key，明文 Key 只在此时返回一次
+func createAPIKey(c *gin.Context) {
+	type CreateAPIKeyRequest struct {
+		Name       string   `json:"name" binding:"required"`
+		Scopes     []string `json:"scopes" binding:"required"`
+		RateLimit  int      `json:"rateLimit"`
+		DailyQuota int64    `json:"dailyQuota"`
+		// 防盗链域名，如 example.com、*.example.com
+		AllowedReferers []string `json:"allowedReferers"`
+		DeniedReferers  []string `json:"deniedReferers"`
+	}
+
+	var req CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, 400, "invalid parameters", "Invalid request parameters. Please check name and scopes.")
+		return
+	}
+
+	raw, key, err := apiKeys.Create(context.Background(), service.APIKey{
+		Name:            req.Name,
+		Scopes:          req.Scopes,
+		RateLimit:       req.RateLimit,
+		DailyQuota:      req.DailyQuota,
+		AllowedReferers: req.AllowedReferers,
+		DeniedReferers:  req.DeniedReferers,
+	})
+	if err != nil {
+		utils.ErrorResponse(c, 400, "create api key error", err.Error())
+		recordAudit(c, service.AuditAPIKeyCreate, nil, nil, req, err)
+		return
+	}
+
+	middleware.Log(c).WithFields(logrus.Fields{"api_key_id": key.ID, "scopes": key.Scopes}).Infof("API key %s created", key.Name)
+	utils.SuccessResponse(c, "API key created successfully", gin.H{"key": raw, "apiKey": key})
+	recordAudit(c, service.AuditAPIKeyCreate, []string{"apikey:" + key.ID}, nil, key, nil)
+}
+
+// 列出 API Key
+func listAPIKeys(c *gin.Context) {
+	keys, err := apiKeys.List(context.Background())
+	if err != nil {
+		utils.ErrorResponse(c, 500, "list api keys error", fmt.Sprintf("Failed to list API keys: %v", err))
+		return
+	}
+	utils.SuccessResponse(c, "API keys retrieved successfully", keys)
+}
+
+// 吊销 API Key
+func revokeAPIKey(c *gin.Context) {
+	id := c.Param("id")
+	targets := []string{"apikey:" + id}
+
+	before, err := apiKeys.Get(context.Background(), id)
+	if err == nil {
+		err = apiKeys.Revoke(context.Background(), id)
+	}
+	if errors.Is(err, service.ErrAPIKeyNotFound) {
+		utils.ErrorResponse(c, 404, "api key not found", fmt.Sprintf("API key '%s' does not exist.", id))
+		return
+	}
+	if err != nil {
+		utils.ErrorResponse(c, 500, "revoke api key error", fmt.Sprintf("Failed to revoke API key '%s': %v", id, err))
+		recordAudit(c, service.AuditAPIKeyRevoke, targets, before, nil, err)
+		return
+	}
+
+	middleware.Log(c).WithField("api_key_id", id).Info("API key revoked")
+	utils.SuccessResponseNoData(c, "API key revoked successfully")
+	recordAudit(c, service.AuditAPIKeyRevoke, targets, before, nil, nil)
+}
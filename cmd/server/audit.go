@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TXM983/wallpaper-api-v1/internal/middleware"
+	"github.com/TXM983/wallpaper-api-v1/internal/service"
+	utils "github.com/TXM983/wallpaper-api-v1/internal/util"
+	"github.com/gin-gonic/gin"
+)
+
+// 审计日志查询条数上限
+const maxAuditLimit = 500
+
+// 记录一次管理操作，需在写出响应后调用：err 为空且状态码小于 400 时为 success，403 为 denied，其余为 failure
+// 写入失败只记录日志，不影响请求结果
+func recordAudit(c *gin.Context, action string, targets []string, before interface{}, after interface{}, err error) {
+	entry := &service.AuditEntry{
+		Action:    action,
+		Targets:   targets,
+		Before:    before,
+		After:     after,
+		IP:        c.ClientIP(),
+		RequestID: middleware.RequestIDFromContext(c),
+		Role:      middleware.RoleFromContext(c),
+		Result:    service.AuditResultSuccess,
+	}
+	if principal := middleware.PrincipalFromContext(c); principal != nil {
+		entry.Actor = principal.DisplayName()
+		entry.Subject = principal.Subject
+		entry.Method = principal.Method
+	}
+	switch status := c.Writer.Status(); {
+	case status == http.StatusForbidden:
+		entry.Result = service.AuditResultDenied
+	case err != nil || status >= http.StatusBadRequest:
+		entry.Result = service.AuditResultFailure
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	if err := auditLog.Record(context.Background(), entry); err != nil {
+		middleware.Log(c).WithError(err).Errorf("Failed to record audit entry for %s", action)
+	}
+}
+
+// 记录 RequirePermission 拒绝的请求，对象为请求的路由
+func recordPermissionDenied(c *gin.Context, permissions []string) {
+	targets := []string{"route:" + c.Request.Method + " " + c.FullPath()}
+	recordAudit(c, service.AuditPermissionDenied, targets, nil, nil,
+		fmt.Errorf("missing permission %s", strings.Join(permissions, " or ")))
+}
+
+// 壁纸的审计对象：类型/文件名
+func wallpaperTargets(deviceType string, fileNames ...string) []string {
+	targets := make([]string, 0, len(fileNames))
+	for _, fileName := range fileNames {
+		targets = append(targets, deviceType+"/"+fileName)
+	}
+	return targets
+}
+
+// 解析时间查询参数，支持 RFC3339 和毫秒时间戳
+func parseAuditTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if ms, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.UnixMilli(ms), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// 查询审计日志，支持按操作者、操作、对象、结果和时间过滤，按时间倒序分页
+func listAuditLog(c *gin.Context) {
+	since, err := parseAuditTime(c.Query("since"))
+	if err != nil {
+		utils.ErrorResponse(c, 400, "invalid parameters", fmt.Sprintf("Invalid since '%s', expected RFC3339 or milliseconds.", c.Query("since")))
+		return
+	}
+	until, err := parseAuditTime(c.Query("until"))
+	if err != nil {
+		utils.ErrorResponse(c, 400, "invalid parameters", fmt.Sprintf("Invalid until '%s', expected RFC3339 or milliseconds.", c.Query("until")))
+		return
+	}
+
+	entries, cursor, err := auditLog.Query(context.Background(), service.AuditQuery{
+		Actor:  c.Query("actor"),
+		Action: c.Query("action"),
+		Target: c.Query("target"),
+		Result: c.Query("result"),
+		Since:  since,
+		Until:  until,
+		Cursor: c.Query("cursor"),
+		Limit:  int(parseLimit(c, 50, maxAuditLimit)),
+	})
+	if err != nil {
+		utils.ErrorResponse(c, 500, "query audit log error", fmt.Sprintf("Failed to query audit log: %v", err))
+		return
+	}
+	utils.SuccessResponse(c, "Audit log retrieved successfully", gin.H{"entries": entries, "nextCursor": cursor})
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/TXM983/wallpaper-api-v1/internal/middleware"
+	"github.com/TXM983/wallpaper-api-v1/internal/service"
+	utils "github.com/TXM983/wallpaper-api-v1/internal/util"
+	"github.com/gin-gonic/gin"
+)
+
+// 签发会话并写入 Cookie
+func startSession(c *gin.Context, principal *service.Principal) bool {
+	token, err := sessions.Issue(principal)
+	if err != nil {
+		middleware.Log(c).WithError(err).Errorf("Error issuing session for %s", principal.Subject)
+		utils.ErrorResponse(c, 500, "session error", "Failed to create login session.")
+		return false
+	}
+	middleware.SetSessionCookie(c, token, int(sessions.TTL().Seconds()))
+	middleware.Log(c).WithField("auth_method", principal.Method).Infof("%s logged in", principal.DisplayName())
+	return true
+}
+
+// 使用用户名和密码登录：配置文件中的管理员或用户表中的本地用户
+func login(c *gin.Context) {
+	type LoginRequest struct {
+		Username string `json:"username"`
+		Password string `json:"password" binding:"required"`
+	}
+
+	var req LoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, 400, "invalid parameters", "Invalid request parameters. Please check username and password.")
+		return
+	}
+
+	var principal *service.Principal
+	if req.Username == "" || req.Username == adminAuth.Username() {
+		var verified bool
+		if req.Username == "" {
+			// 管理页面表单只填写密码
+			verified = adminAuth.VerifyPasswordOnly(req.Password)
+		} else {
+			verified = adminAuth.VerifyPassword(req.Username, req.Password)
+		}
+		if verified {
+			principal = &service.Principal{
+				Subject: adminAuth.Username(),
+				Name:    adminAuth.Username(),
+				Method:  service.AuthMethodPassword,
+			}
+		}
+	} else {
+		user, err := users.Authenticate(context.Background(), req.Username, req.Password)
+		if err != nil && !errors.Is(err, service.ErrInvalidCredentials) {
+			middleware.Log(c).WithError(err).Errorf("Error authenticating user %s", req.Username)
+			utils.ErrorResponse(c, 500, "server error", "Failed to verify credentials.")
+			return
+		}
+		if user != nil {
+			principal = &service.Principal{
+				Subject: user.Username,
+				Name:    user.Name,
+				Email:   user.Email,
+				Method:  service.AuthMethodPassword,
+			}
+		}
+	}
+
+	if principal == nil {
+		middleware.Log(c).Warnf("Login failed for username: %s", req.Username)
+		utils.ErrorResponse(c, 401, "unauthorized", "Login failed. Invalid username or password.")
+		return
+	}
+
+	if !startSession(c, principal) {
+		return
+	}
+	utils.SuccessResponse(c, "Logged in successfully", principal)
+}
+
+// 注销当前会话
+func logout(c *gin.Context) {
+	if claims := middleware.SessionFromContext(c); claims != nil {
+		if err := sessions.Revoke(context.Background(), claims); err != nil {
+			middleware.Log(c).WithError(err).Errorf("Error revoking session %s", claims.ID)
+		}
+	}
+	middleware.ClearSessionCookie(c)
+	utils.SuccessResponseNoData(c, "Logged out successfully")
+}
+
+// 查询当前登录用户，同时返回可用的登录方式
+func currentUser(c *gin.Context) {
+	principal := middleware.PrincipalFromContext(c)
+	if principal == nil {
+		c.JSON(http.StatusUnauthorized, utils.ApiResponse{
+			Code:    http.StatusUnauthorized,
+			Status:  "error",
+			Message: "Not logged in.",
+			Error:   "unauthorized",
+			Data:    gin.H{"oidc": oidcClient.Enabled()},
+		})
+		return
+	}
+	utils.SuccessResponse(c, "Current user retrieved successfully", gin.H{
+		"user": principal,
+		"role": middleware.RoleFromContext(c),
+		"oidc": oidcClient.Enabled(),
+	})
+}
+
+// 跳转到 OIDC 提供方登录
+func oidcLogin(c *gin.Context) {
+	if !oidcClient.Enabled() {
+		utils.ErrorResponseNoError(c, 404, "OIDC login is not configured")
+		return
+	}
+
+	authURL, err := oidcClient.AuthURL(context.Background())
+	if err != nil {
+		middleware.Log(c).WithError(err).Error("Error starting OIDC login")
+		utils.ErrorResponse(c, 502, "oidc error", "Failed to contact the OIDC provider.")
+		return
+	}
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// OIDC 登录回调，校验通过后签发会话并返回首页
+func oidcCallback(c *gin.Context) {
+	if !oidcClient.Enabled() {
+		utils.ErrorResponseNoError(c, 404, "OIDC login is not configured")
+		return
+	}
+
+	if errCode := c.Query("error"); errCode != "" {
+		utils.ErrorResponse(c, 401, errCode, fmt.Sprintf("OIDC login was rejected: %s", c.Query("error_description")))
+		return
+	}
+
+	principal, err := oidcClient.Exchange(context.Background(), c.Query("state"), c.Query("code"))
+	if errors.Is(err, service.ErrInvalidOIDCState) {
+		utils.ErrorResponse(c, 400, "invalid state", "The login request has expired. Please try again.")
+		return
+	}
+	if err != nil {
+		middleware.Log(c).WithError(err).Error("Error completing OIDC login")
+		utils.ErrorResponse(c, 401, "oidc error", "Failed to complete OIDC login.")
+		return
+	}
+
+	// 只为允许列表中或用户表中已登记的账号签发会话
+	allowed, err := oidcAccountAllowed(principal)
+	if err != nil {
+		middleware.Log(c).WithError(err).Errorf("Error checking OIDC account %s", principal.Subject)
+		utils.ErrorResponse(c, 500, "server error", "Failed to verify the OIDC account.")
+		return
+	}
+	if !allowed {
+		middleware.Log(c).Warnf("OIDC login rejected for %s (%s): not allowed", principal.DisplayName(), principal.Subject)
+		utils.ErrorResponse(c, 403, "forbidden", service.ErrOIDCNotAllowed.Error())
+		return
+	}
+
+	if !startSession(c, principal) {
+		return
+	}
+	c.Redirect(http.StatusFound, "/")
+}
+
+// OIDC 账号是否允许登录：邮箱在允许列表中，或已在用户表中登记
+func oidcAccountAllowed(principal *service.Principal) (bool, error) {
+	if oidcClient.Allowed(principal) {
+		return true, nil
+	}
+	_, err := users.FindByEmail(context.Background(), principal.Email)
+	if errors.Is(err, service.ErrUserNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
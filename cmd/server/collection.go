@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/TXM983/wallpaper-api-v1/internal/middleware"
+	"github.com/TXM983/wallpaper-api-v1/internal/service"
+	utils "github.com/TXM983/wallpaper-api-v1/internal/util"
+	"github.com/gin-gonic/gin"
+)
+
+// 创建或更新壁纸合集，wallpapers 为完整列表（类型/文件名）
+func saveCollection(c *gin.Context) {
+	type SaveCollectionRequest struct {
+		ID          string   `json:"id" binding:"required"`
+		Name        string   `json:"name" binding:"required"`
+		Description string   `json:"description"`
+		Wallpapers  []string `json:"wallpapers"`
+	}
+
+	var req SaveCollectionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, 400, "invalid parameters", "Invalid request parameters. Please check id, name and wallpapers.")
+		return
+	}
+
+	// 修改前的合集，用于审计日志
+	targets := []string{"collection:" + req.ID}
+	var before interface{}
+	if existing, err := service.GetCollection(context.Background(), rdb, req.ID); err == nil {
+		before = existing
+	}
+
+	collection, err := service.SaveCollection(context.Background(), rdb, service.Collection{
+		ID:          req.ID,
+		Name:        req.Name,
+		Description: req.Description,
+		Wallpapers:  req.Wallpapers,
+		UpdatedBy:   middleware.PrincipalFromContext(c).DisplayName(),
+	})
+	if err != nil {
+		utils.ErrorResponse(c, 400, "save collection error", err.Error())
+		recordAudit(c, service.AuditCollectionSave, targets, before, gin.H{"name": req.Name, "wallpapers": req.Wallpapers}, err)
+		return
+	}
+
+	middleware.Log(c).Infof("Collection %s saved with %d wallpapers", collection.ID, len(collection.Wallpapers))
+	utils.SuccessResponse(c, "Collection saved successfully", collection)
+	recordAudit(c, service.AuditCollectionSave, targets, before, collection, nil)
+}
+
+// 列出壁纸合集
+func listCollections(c *gin.Context) {
+	list, err := service.ListCollections(context.Background(), rdb)
+	if err != nil {
+		utils.ErrorResponse(c, 500, "list collections error", fmt.Sprintf("Failed to list collections: %v", err))
+		return
+	}
+	utils.SuccessResponse(c, "Collections retrieved successfully", list)
+}
+
+// 查询壁纸合集
+func getCollection(c *gin.Context) {
+	id := c.Param("id")
+
+	collection, err := service.GetCollection(context.Background(), rdb, id)
+	if errors.Is(err, service.ErrCollectionNotFound) {
+		utils.ErrorResponse(c, 404, "collection not found", fmt.Sprintf("Collection '%s' does not exist.", id))
+		return
+	}
+	if err != nil {
+		utils.ErrorResponse(c, 500, "query collection error", fmt.Sprintf("Failed to query collection '%s': %v", id, err))
+		return
+	}
+	utils.SuccessResponse(c, "Collection retrieved successfully", collection)
+}
+
+// 删除壁纸合集，不删除其中的壁纸
+func deleteCollection(c *gin.Context) {
+	id := c.Param("id")
+	targets := []string{"collection:" + id}
+
+	before, err := service.GetCollection(context.Background(), rdb, id)
+	if err == nil {
+		err = service.DeleteCollection(context.Background(), rdb, id)
+	}
+	if errors.Is(err, service.ErrCollectionNotFound) {
+		utils.ErrorResponse(c, 404, "collection not found", fmt.Sprintf("Collection '%s' does not exist.", id))
+		return
+	}
+	if err != nil {
+		utils.ErrorResponse(c, 500, "delete collection error", fmt.Sprintf("Failed to delete collection '%s': %v", id, err))
+		recordAudit(c, service.AuditCollectionDelete, targets, before, nil, err)
+		return
+	}
+
+	middleware.Log(c).Infof("Collection %s deleted", id)
+	utils.SuccessResponseNoData(c, "Collection deleted successfully")
+	recordAudit(c, service.AuditCollectionDelete, targets, before, nil, nil)
+}
@@ -2,49 +2,115 @@ package main
 
 import (
 	"context"
+	"crypto/subtle"
 	"errors"
 	"fmt"
 	utils "github.com/TXM983/wallpaper-api-v1/internal/util"
+	"io"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/TXM983/wallpaper-api-v1/internal/config"
+	"github.com/TXM983/wallpaper-api-v1/internal/lifecycle"
 	"github.com/TXM983/wallpaper-api-v1/internal/logger"
+	"github.com/TXM983/wallpaper-api-v1/internal/metrics"
 	"github.com/TXM983/wallpaper-api-v1/internal/middleware"
 	"github.com/TXM983/wallpaper-api-v1/internal/service"
+	"github.com/TXM983/wallpaper-api-v1/internal/tracing"
 	"github.com/aliyun/aliyun-oss-go-sdk/oss"
 	"github.com/gin-gonic/gin"
 	"github.com/go-redis/redis/v8"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
 var (
-	rdb       *redis.Client
-	appConfig *config.AppConfig
-	ossClient *oss.Client
-	bucket    *oss.Bucket
+	rdb        *redis.Client
+	appConfig  *config.AppConfig
+	ossClient  *oss.Client
+	bucket     *oss.Bucket
+	urlSigner  *service.URLSigner
+	eventBus   *service.EventBus
+	webhooks   *service.WebhookService
+	reconciler *service.Reconciler
+	jobs       *service.JobManager
+	adminAuth  *middleware.AdminAuthenticator
+	apiKeys    *service.APIKeyService
+	sessions   *service.SessionManager
+	users      *service.UserService
+	oidcClient *service.OIDCClient
+	auditLog   *service.AuditLog
+	health     *service.HealthChecker
+
+	// 后台任务（限流器清理、对账、Webhook 投递、事件总线），退出时统一停止
+	background *lifecycle.Manager
+	// 收到退出信号后置为 true，/readyz 返回 503
+	draining atomic.Bool
+
+	// 退出时导出剩余的 Span
+	shutdownTracing func(context.Context) error
+	// 访问日志输出，退出时写完并关闭
+	accessLogWriter io.WriteCloser
 )
 
 func main() {
 
-	// 初始化日志
-	logger.Init()
-
 	// 加载配置
 	appConfig = config.LoadConfig()
 
+	// 初始化日志
+	initLogger()
+
+	// 初始化链路追踪
+	initTracing()
+
+	// 后台任务管理
+	background = lifecycle.New()
+
 	// 初始化 Redis
 	initRedis()
 
 	// 初始化阿里云 OSS
 	initOSS()
 
-	// 启动后台清理任务
-	middleware.InitRateLimiterCleanup(30 * time.Minute)
+	// 初始化壁纸库事件总线
+	eventBus = service.NewEventBus(rdb)
+	background.Go("event-bus", eventBus.Run)
+
+	// 启动 Webhook 投递
+	webhooks = service.NewWebhookService(rdb, service.WebhookOptions{
+		Workers:     appConfig.Webhook.Workers,
+		MaxAttempts: appConfig.Webhook.MaxAttempts,
+		Timeout:     time.Duration(appConfig.Webhook.TimeoutSeconds) * time.Second,
+		BaseBackoff: time.Duration(appConfig.Webhook.BackoffSeconds) * time.Second,
+	})
+	background.Go("webhook", webhooks.Run)
+
+	// 启动 OSS 与 Redis 定期对账
+	reconciler = service.NewReconciler(rdb, bucket, time.Duration(appConfig.Reconcile.IntervalSeconds)*time.Second, publishEvent)
+	background.Go("reconciler", reconciler.Run)
+
+	// 初始化管理操作审计日志
+	auditLog = service.NewAuditLog(rdb, service.AuditOptions{
+		MaxLen:    appConfig.Audit.MaxLen,
+		Retention: time.Duration(appConfig.Audit.RetentionDays) * 24 * time.Hour,
+	})
+	middleware.PermissionDenied = recordPermissionDenied
+
+	// 就绪检查：Redis、OSS 和各分类缓存
+	health = service.NewHealthChecker(rdb, bucket, service.DeviceTypes, time.Duration(appConfig.Health.TimeoutSeconds)*time.Second)
+
+	// 初始化缓存重建任务管理
+	jobs = service.NewJobManager(rdb, bucket, publishCacheRebuilt)
+
+	// 初始化限流驱动
+	initRateLimiter()
 
 	// **确保 Redis 和 OSS 初始化成功**
 	if rdb == nil {
@@ -61,16 +127,27 @@ func main() {
 		os.Exit(1)
 	}
 
+	// **初始化管理员认证、登录会话和 API Key**
+	initAdminAuth()
+	initSessions()
+	initUsers()
+	apiKeys = service.NewAPIKeyService(rdb)
+	if appConfig.APIKey.DefaultRateLimit > 0 {
+		middleware.DefaultAPIKeyRateLimit = appConfig.APIKey.DefaultRateLimit
+	}
+
 	// **创建 Gin 引擎**
 	r := setupRouter()
 
-	logger.LogInfo(fmt.Sprintf("Server started on port: %d", appConfig.Server.Port))
+	logger.LogInfo("Server started on port: %d", appConfig.Server.Port)
 
 	// **启动 HTTP 服务器**
 	server := &http.Server{
 		Addr:    fmt.Sprintf(":%d", appConfig.Server.Port),
 		Handler: r,
 	}
+	// 退出时通知 SSE / WebSocket 推送结束
+	server.RegisterOnShutdown(stopStreams)
 
 	go func() {
 		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
@@ -78,19 +155,73 @@ func main() {
 		}
 	}()
 
+	// SIGHUP 重新加载限流策略
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	go func() {
+		for range reload {
+			reloadRateLimitOnSignal()
+		}
+	}()
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
+	// 再次收到信号时按默认行为立即退出
+	signal.Stop(quit)
+
+	shutdown(server)
+}
+
+// 按顺序退出：/readyz 返回 503，停止接收请求并等待进行中的请求，停止缓存重建任务和后台任务，导出 Span，关闭 Redis，最后写完日志
+func shutdown(server *http.Server) {
+	timeout := time.Duration(appConfig.Server.ShutdownTimeoutSeconds) * time.Second
+	logger.LogInfo("Shutting down, waiting up to %s for in-flight requests and background tasks", timeout)
+	draining.Store(true)
+
+	// 等待负载均衡通过 /readyz 摘除本实例，期间仍正常处理请求
+	if delay := time.Duration(appConfig.Server.ShutdownDelaySeconds) * time.Second; delay > 0 {
+		time.Sleep(delay)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// **关闭 HTTP 服务器**：不再接收新连接，等待进行中的请求（如上传）完成，超时后强制关闭
+	if err := server.Shutdown(ctx); err != nil {
+		logger.LogError("Server forced to shutdown: %v", err)
+		server.Close()
+	}
+
+	// 取消进行中的缓存重建任务，未按时结束的任务标记为失败并释放重建锁（需在关闭 Redis 之前）
+	if err := jobs.Stop(ctx); err != nil {
+		logger.LogError("Failed to stop rebuild jobs: %v", err)
+	}
+
+	// 停止后台任务，等待进行中的对账和 Webhook 投递完成
+	if err := background.Stop(ctx); err != nil {
+		logger.LogError("Failed to stop background tasks: %v", err)
+	}
+
+	// 导出剩余的 Span
+	tracingCtx, tracingCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	if err := shutdownTracing(tracingCtx); err != nil {
+		logger.LogError("Failed to shutdown tracing: %v", err)
+	}
+	tracingCancel()
 
 	// 关闭 Redis 连接
 	if err := rdb.Close(); err != nil {
-		logger.LogInfo("Failed to close Redis: %v\n", err)
+		logger.LogError("Failed to close Redis: %v", err)
 	}
 
-	// **关闭 HTTP 服务器**
-	if err := server.Close(); err != nil {
-		panic(fmt.Sprintf("Server forced to shutdown: %v", err))
+	logger.LogInfo("Server exited")
+
+	// 写完队列中的日志
+	if accessLogWriter != nil {
+		accessLogWriter.Close()
 	}
+	logger.Close()
 }
 
 func initRedis() {
@@ -112,11 +243,172 @@ func initRedis() {
 		os.Exit(1) // 如果 Redis 连接失败，退出程序
 	}
 
+	// 统计 Redis 命令耗时和错误，并为每条命令创建 Span
+	rdb.AddHook(metrics.RedisHook{})
+	rdb.AddHook(tracing.RedisHook{Addr: appConfig.Redis.Addr, DB: appConfig.Redis.DB})
+
 	// 如果连接成功，打印 Redis 连接成功
 	logger.LogInfo("Connected to Redis successfully！")
 	fmt.Println("Connected to Redis successfully！")
 }
 
+func initLogger() {
+	opts := logger.DefaultOptions()
+	opts.Level = appConfig.Log.Level
+	opts.Format = appConfig.Log.Format
+	opts.Output = appConfig.Log.Output
+	opts.FilePath = appConfig.Log.FilePath
+	opts.MaxSizeMB = appConfig.Log.MaxSizeMB
+	opts.MaxBackups = appConfig.Log.MaxBackups
+	opts.MaxAgeDays = appConfig.Log.MaxAgeDays
+	opts.TimeZone = appConfig.Log.TimeZone
+	opts.BufferSize = appConfig.Log.BufferSize
+	if err := logger.Init(opts); err != nil {
+		fmt.Printf("Failed to initialize logger: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// 创建访问日志中间件，写入独立的轮转文件，时区与应用日志一致
+func initAccessLog() gin.HandlerFunc {
+	opts := logger.DefaultOptions()
+	opts.Output = appConfig.AccessLog.Output
+	opts.FilePath = appConfig.AccessLog.FilePath
+	opts.MaxSizeMB = appConfig.AccessLog.MaxSizeMB
+	opts.MaxBackups = appConfig.AccessLog.MaxBackups
+	opts.MaxAgeDays = appConfig.AccessLog.MaxAgeDays
+	opts.BufferSize = appConfig.AccessLog.BufferSize
+
+	var loc *time.Location
+	if appConfig.Log.TimeZone != "" {
+		var err error
+		if loc, err = time.LoadLocation(appConfig.Log.TimeZone); err != nil {
+			fmt.Printf("Invalid access log time zone: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	w, err := logger.NewWriter(opts)
+	if err != nil {
+		fmt.Printf("Failed to open access log: %v\n", err)
+		os.Exit(1)
+	}
+	handler, err := middleware.AccessLog(w, appConfig.AccessLog.Format, loc, appConfig.AccessLog.SkipPaths)
+	if err != nil {
+		fmt.Printf("Invalid access log config: %v\n", err)
+		os.Exit(1)
+	}
+	accessLogWriter = w
+	return handler
+}
+
+func initTracing() {
+	var err error
+	shutdownTracing, err = tracing.Init(context.Background(), tracing.Options{
+		Enabled:     appConfig.Tracing.Enabled,
+		ServiceName: appConfig.Tracing.ServiceName,
+		Endpoint:    appConfig.Tracing.Endpoint,
+		Insecure:    appConfig.Tracing.Insecure,
+		Headers:     appConfig.Tracing.Headers,
+		SampleRatio: appConfig.Tracing.SampleRatio,
+	})
+	if err != nil {
+		logger.LogError("Failed to initialize tracing: %v\n", err)
+		fmt.Printf("Failed to initialize tracing: %v\n", err)
+		os.Exit(1)
+	}
+	if appConfig.Tracing.Enabled {
+		logger.LogInfo("Tracing enabled, exporting to %s", appConfig.Tracing.Endpoint)
+	}
+}
+
+func initRateLimiter() {
+	policy, err := buildRateLimitPolicy(appConfig)
+	if err != nil {
+		logger.LogError("Invalid rate_limit config: %v\n", err)
+		fmt.Printf("Invalid rate_limit config: %v\n", err)
+		os.Exit(1)
+	}
+	middleware.SetRateLimitPolicy(policy)
+
+	switch appConfig.RateLimit.Driver {
+	case middleware.LimiterDriverRedis:
+		middleware.RateLimiter = middleware.NewRedisLimiter(rdb)
+	case middleware.LimiterDriverMemory, "":
+		middleware.RateLimiter = middleware.NewMemoryLimiter()
+		// 启动后台清理任务
+		background.Go("ratelimit-cleanup", func(ctx context.Context) {
+			middleware.RunRateLimiterCleanup(ctx, 30*time.Minute)
+		})
+	default:
+		logger.LogError("Invalid rate_limit.driver: %s\n", appConfig.RateLimit.Driver)
+		fmt.Printf("Invalid rate_limit.driver: %s\n", appConfig.RateLimit.Driver)
+		os.Exit(1)
+	}
+
+	logger.LogInfo("Rate limiter driver: %s", appConfig.RateLimit.Driver)
+}
+
+func initAdminAuth() {
+	passwordHash := appConfig.Admin.PasswordHash
+
+	// 兼容旧配置：未配置哈希时使用明文密码生成哈希
+	if passwordHash == "" && appConfig.INDEX.Password != "" {
+		hash, err := middleware.HashAdminPassword(appConfig.INDEX.Password)
+		if err != nil {
+			logger.LogError("Failed to hash admin password: %v\n", err)
+			fmt.Printf("Failed to hash admin password: %v\n", err)
+			os.Exit(1)
+		}
+		passwordHash = hash
+		logger.LogInfo("admin.password_hash is not configured, falling back to deprecated index.password")
+	}
+
+	adminAuth = middleware.NewAdminAuthenticator(appConfig.Admin.Username, passwordHash, appConfig.Admin.Tokens)
+	if !adminAuth.Enabled() {
+		logger.LogInfo("No admin credentials configured, administrative endpoints are disabled")
+		fmt.Println("No admin credentials configured, administrative endpoints are disabled")
+	}
+}
+
+func initSessions() {
+	if appConfig.Session.Secret == "" {
+		logger.LogInfo("session.secret is not configured, using a random key: sessions will not survive restarts or be shared between instances")
+	}
+
+	var err error
+	sessions, err = service.NewSessionManager(rdb, appConfig.Session.Secret, time.Duration(appConfig.Session.TTLMinutes)*time.Minute)
+	if err != nil {
+		logger.LogError("Failed to initialize sessions: %v\n", err)
+		fmt.Printf("Failed to initialize sessions: %v\n", err)
+		os.Exit(1)
+	}
+	middleware.SessionCookieSecure = appConfig.Session.CookieSecure
+
+	oidcClient = service.NewOIDCClient(rdb, service.OIDCOptions{
+		Issuer:         appConfig.OIDC.Issuer,
+		ClientID:       appConfig.OIDC.ClientID,
+		ClientSecret:   appConfig.OIDC.ClientSecret,
+		RedirectURL:    appConfig.OIDC.RedirectURL,
+		Scopes:         appConfig.OIDC.Scopes,
+		AllowedEmails:  appConfig.OIDC.AllowedEmails,
+		AllowedDomains: appConfig.OIDC.AllowedDomains,
+	})
+	if oidcClient.Enabled() {
+		logger.LogInfo("OIDC login enabled, issuer: %s", appConfig.OIDC.Issuer)
+	}
+}
+
+func initUsers() {
+	defaultRole := appConfig.RBAC.DefaultRole
+	if defaultRole != "" && !service.ValidateRole(defaultRole) {
+		logger.LogError("Invalid rbac.default_role: %s\n", defaultRole)
+		fmt.Printf("Invalid rbac.default_role: %s\n", defaultRole)
+		os.Exit(1)
+	}
+	users = service.NewUserService(rdb, defaultRole)
+}
+
 func initOSS() {
 	var err error
 	// 创建OSS客户端
@@ -137,165 +429,189 @@ func initOSS() {
 
 	fmt.Println("Successfully initialized Alibaba Cloud OSS！")
 
-	// 需要向阿里云OSS配置触发事件，上传或者删除事件将触发处理函数
-}
-
-// **工具函数：将 []string 转换为 []interface{}**
-func stringSliceToInterfaceSlice(strs []string) []interface{} {
-	result := make([]interface{}, len(strs))
-	for i, v := range strs {
-		result[i] = v
+	// 壁纸地址签名
+	urlSigner, err = service.NewURLSigner(service.URLSignOptions{
+		Mode:        appConfig.URLSigning.Mode,
+		BaseURL:     appConfig.CDN.BaseURL,
+		TTL:         time.Duration(appConfig.URLSigning.TTLSeconds) * time.Second,
+		CDNKey:      appConfig.URLSigning.CDNKey,
+		CDNValidity: time.Duration(appConfig.URLSigning.CDNValiditySeconds) * time.Second,
+	}, bucket)
+	if err != nil {
+		logger.LogError("Failed to initialize url signing: %v\n", err)
+		fmt.Printf("Failed to initialize url signing: %v\n", err)
+		os.Exit(1)
 	}
-	return result
+
+	// 在阿里云OSS配置事件通知（MNS HTTP 订阅）推送到 /oss/events，上传或者删除事件将同步到 Redis
 }
 
 func resetCache(rdb *redis.Client, bucket *oss.Bucket) error {
-	ctx := context.Background()
-
-	// **清空 Redis 旧缓存**
-	if err := rdb.Del(ctx, "wallpaper:pc", "wallpaper:mobile", "wallpaper:cache:pc", "wallpaper:cache:mobile").Err(); err != nil {
-		return fmt.Errorf("failed to clear old cache: %v", err)
-	}
-
-	// **初始化 PC 和 Mobile 类型壁纸**
-	pcCount, err := populateWallpaperList(ctx, rdb, bucket, "pc/")
+	// **依次重建 PC 和 Mobile 壁纸缓存，每个类型独立原子替换**
+	pc, err := refreshCache(rdb, bucket, "pc")
 	if err != nil {
-		return fmt.Errorf("failed to populate PC wallpapers: %v", err)
+		return err
 	}
-
-	mobileCount, err := populateWallpaperList(ctx, rdb, bucket, "mobile/")
+	mobile, err := refreshCache(rdb, bucket, "mobile")
 	if err != nil {
-		return fmt.Errorf("failed to populate Mobile wallpapers: %v", err)
-	}
-
-	// **初始化随机壁纸缓存**
-	if err := initRandomWallpaperCache(rdb, "pc"); err != nil {
-		return fmt.Errorf("error initializing random wallpaper cache for PC: %v", err)
-	}
-	if err := initRandomWallpaperCache(rdb, "mobile"); err != nil {
-		return fmt.Errorf("error initializing random wallpaper cache for Mobile: %v", err)
+		return err
 	}
 
 	// **打印最终的壁纸数量**
-	logger.LogInfo("Wallpaper cache initialized successfully. PC count: %d, Mobile count: %d\n", pcCount, mobileCount)
-	fmt.Printf("Wallpaper cache initialized successfully. PC count: %d, Mobile count: %d\n", pcCount, mobileCount)
+	logger.LogInfo("Wallpaper cache initialized successfully. PC count: %d, Mobile count: %d\n", pc.Count, mobile.Count)
+	fmt.Printf("Wallpaper cache initialized successfully. PC count: %d, Mobile count: %d\n", pc.Count, mobile.Count)
 
 	return nil
 }
 
-func refreshCacheByDevice(rdb *redis.Client, bucket *oss.Bucket, deviceType string) error {
-	ctx := context.Background()
-
-	// **根据deviceType清空 Redis 旧缓存**
-	if err := rdb.Del(ctx, "wallpaper:"+deviceType, "wallpaper:cache:"+deviceType).Err(); err != nil {
-		return fmt.Errorf("failed to clear old cache: %v", err)
-	}
-
-	// **根据deviceType初始化壁纸**
-	deviceTypeCount, err := populateWallpaperList(ctx, rdb, bucket, deviceType+"/")
+// **从 OSS 重建指定类型的壁纸缓存（暂存 Key + RENAME），并通知缓存已重建**
+func refreshCache(rdb *redis.Client, bucket *oss.Bucket, deviceType string) (*service.RebuildResult, error) {
+	result, err := service.RebuildWallpaperCache(context.Background(), rdb, bucket, deviceType, nil)
 	if err != nil {
-		return fmt.Errorf("failed to populate %v wallpapers: %v", deviceType, err)
+		return nil, fmt.Errorf("failed to rebuild %v wallpaper cache: %v", deviceType, err)
 	}
 
-	// **根据deviceType初始化随机壁纸缓存**
-	if err := initRandomWallpaperCache(rdb, deviceType); err != nil {
-		return fmt.Errorf("error initializing random wallpaper cache for %v: %v", deviceType, err)
-	}
+	logger.LogInfo("Rebuilt %s wallpaper cache, count: %d, generation: %d", deviceType, result.Count, result.Generation)
+	publishCacheRebuilt(result)
 
-	// **打印最终的壁纸缓存数量**
-	logger.LogInfo("Wallpaper cache initialized successfully. %v count: %d\n", deviceType, deviceTypeCount)
-	fmt.Printf("Wallpaper cache initialized successfully. %v count: %d\n", deviceType, deviceTypeCount)
-
-	return nil
+	return result, nil
 }
 
-func initRandomWallpaperCache(rdb *redis.Client, deviceType string) error {
-	ctx := context.Background()
-	keyOriginal := "wallpaper:" + deviceType    // 原始壁纸列表
-	keyCache := "wallpaper:cache:" + deviceType // 缓存列表
-
-	// 检查缓存是否为空
-	cacheExists, err := rdb.Exists(ctx, keyCache).Result()
-	if err != nil {
-		logger.LogError(fmt.Sprintf("Error checking cache existence for key %s: %v", keyCache, err))
-		return err
+// 发布壁纸库事件并触发 Webhook，未初始化或发布失败时不影响主流程
+func publishEvent(event service.LibraryEvent) {
+	if eventBus != nil {
+		_ = eventBus.Publish(context.Background(), event)
 	}
-
-	// 如果缓存为空，则重新填充
-	if cacheExists == 0 {
-		logger.LogInfo(fmt.Sprintf("Random wallpaper cache for %s is empty, refilling...", deviceType))
-		err = service.RefillCache(ctx, rdb, keyOriginal, keyCache)
-		if err != nil {
-			logger.LogError(fmt.Sprintf("Error refilling random wallpaper cache for key %s: %v", keyCache, err))
-			return err
+	if webhooks != nil {
+		if err := webhooks.Enqueue(context.Background(), event); err != nil {
+			logger.LogError("Error enqueuing webhooks for event %s: %v", event.Type, err)
 		}
 	}
+}
 
-	cacheLength, err := rdb.LLen(ctx, keyCache).Result()
-	if err != nil {
-		logger.LogError(fmt.Sprintf("Error getting cache length for key %s: %v", keyCache, err))
-		return err
-	}
-	fmt.Printf("Successfully refilled random wallpaper cache for %s. Cache length: %d\n", deviceType, cacheLength)
-
-	return nil
+// 发布缓存重建事件
+func publishCacheRebuilt(result *service.RebuildResult) {
+	event := service.NewLibraryEvent(service.EventCacheRebuilt, result.DeviceType)
+	event.Count = result.Count
+	event.Generation = result.Generation
+	publishEvent(event)
 }
 
-// **从 OSS 读取文件并存入 Redis List**
-func populateWallpaperList(ctx context.Context, rdb *redis.Client, bucket *oss.Bucket, prefix string) (int, error) {
-	marker := ""
-	var wallpaperList []string
-	totalCount := 0
+// 根据配置创建防盗链策略
+func newHotlinkPolicy() *service.HotlinkPolicy {
+	categories := make(map[string]service.HotlinkRules, len(appConfig.Hotlink.Categories))
+	for category, rules := range appConfig.Hotlink.Categories {
+		categories[category] = service.HotlinkRules{Allow: rules.Allow, Deny: rules.Deny}
+	}
+	return service.NewHotlinkPolicy(service.HotlinkOptions{
+		Enabled:           appConfig.Hotlink.Enabled,
+		AllowEmptyReferer: appConfig.Hotlink.AllowEmptyReferer,
+		Global:            service.HotlinkRules{Allow: appConfig.Hotlink.Allow, Deny: appConfig.Hotlink.Deny},
+		Categories:        categories,
+	})
+}
 
-	for {
-		// **每次最多获取 1000 个文件**
-		objects, err := bucket.ListObjects(oss.Marker(marker), oss.Prefix(prefix), oss.MaxKeys(1000))
-		if err != nil {
-			return totalCount, fmt.Errorf("failed to list objects for %s: %v", prefix, err)
-		}
+// 支持的客户端 IP 请求头
+var clientIPHeaders = []string{"X-Forwarded-For", "X-Real-IP", "CF-Connecting-IP", "Ali-CDN-Real-IP"}
 
-		// **筛选有效文件**
-		for _, object := range objects.Objects {
-			if strings.HasSuffix(object.Key, ".alist") {
-				continue // 跳过 .alist 文件
-			}
-			filename := getFilenameFromKey(object.Key)
-			wallpaperList = append(wallpaperList, filename)
-			totalCount++
-		}
-
-		// **检查是否还有更多文件**
-		if objects.IsTruncated {
-			marker = objects.NextMarker
-		} else {
+// 配置可信代理和客户端 IP 请求头，限流、日志等统一通过 c.ClientIP() 获取客户端 IP
+// 只有连接来自可信代理时才读取请求头，否则使用连接地址，避免伪造请求头绕过限流
+func configureClientIP(r *gin.Engine) error {
+	header := strings.TrimSpace(appConfig.Server.ClientIPHeader)
+	if header == "" {
+		header = clientIPHeaders[0]
+	}
+	supported := false
+	for _, h := range clientIPHeaders {
+		if strings.EqualFold(h, header) {
+			header, supported = h, true
 			break
 		}
 	}
+	if !supported {
+		return fmt.Errorf("unsupported client_ip_header '%s', expected one of %s", header, strings.Join(clientIPHeaders, ", "))
+	}
 
-	// **批量存入 Redis**
-	if len(wallpaperList) > 0 {
-		key := "wallpaper:" + strings.TrimSuffix(prefix, "/") // "wallpaper:pc" or "wallpaper:mobile"
-		if err := rdb.LPush(ctx, key, stringSliceToInterfaceSlice(wallpaperList)...).Err(); err != nil {
-			return totalCount, fmt.Errorf("failed to push wallpapers to Redis for %s: %v", prefix, err)
+	// 为空时不信任任何代理（gin 默认信任所有代理）
+	var proxies []string
+	for _, proxy := range appConfig.Server.TrustedProxies {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
 		}
 	}
+	if err := r.SetTrustedProxies(proxies); err != nil {
+		return fmt.Errorf("invalid trusted_proxies: %v", err)
+	}
+	r.RemoteIPHeaders = []string{header}
 
-	return totalCount, nil
+	if len(proxies) == 0 {
+		logger.LogInfo("No trusted proxies configured, using the connection address as client IP")
+	} else {
+		logger.LogInfo("Trusted proxies: %s, client IP header: %s", strings.Join(proxies, ", "), header)
+	}
+	return nil
 }
 
-// 辅助函数，用于从对象Key中提取文件名
-func getFilenameFromKey(objectKey string) string {
-	parts := strings.Split(objectKey, "/")
-	return parts[len(parts)-1]
+// /metrics 的访问校验：来自允许网段的请求直接放行，其余请求需携带 Bearer Token（未配置 Token 时拒绝）
+func metricsAuth(token string, networks []*net.IPNet) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if middleware.IPInNetworks(c.ClientIP(), networks) {
+			c.Next()
+			return
+		}
+		provided := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if token == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			c.Header("WWW-Authenticate", `Bearer realm="metrics"`)
+			utils.ErrorResponse(c, 401, "unauthorized", "A valid metrics token is required.")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
 }
 
 func setupRouter() *gin.Engine {
 	r := gin.New()
+	if err := configureClientIP(r); err != nil {
+		logger.LogError("Invalid client IP config: %v", err)
+		fmt.Printf("Invalid client IP config: %v\n", err)
+		os.Exit(1)
+	}
 
 	// 中间件
 	r.Use(
 		gin.Recovery(),
 	)
+	if appConfig.Metrics.Enabled {
+		networks, err := middleware.ParseCIDRs(appConfig.Metrics.AllowCIDRs)
+		if err != nil {
+			logger.LogError("Invalid metrics.allow_cidrs: %v", err)
+			fmt.Printf("Invalid metrics.allow_cidrs: %v\n", err)
+			os.Exit(1)
+		}
+		if appConfig.Metrics.Token == "" && len(networks) == 0 {
+			logger.LogError("metrics.enabled requires metrics.token or metrics.allow_cidrs")
+			fmt.Println("metrics.enabled requires metrics.token or metrics.allow_cidrs")
+			os.Exit(1)
+		}
+		metrics.RegisterCacheSize(rdb, service.DeviceTypes)
+		r.Use(metrics.Middleware())
+		r.GET("/metrics", metricsAuth(appConfig.Metrics.Token, networks), metrics.Handler())
+	}
+	// 每个请求一个 Span，从 traceparent 头继承上游链路，Span 名为路由模板
+	r.Use(otelgin.Middleware(appConfig.Tracing.ServiceName, otelgin.WithFilter(func(req *http.Request) bool {
+		switch req.URL.Path {
+		case "/metrics", "/health", "/livez", "/readyz":
+			return false
+		}
+		return true
+	})))
+	// 请求 ID 和请求级日志字段
+	r.Use(middleware.RequestID())
+	// 访问日志
+	if appConfig.AccessLog.Enabled {
+		r.Use(initAccessLog())
+	}
 
 	r.Static("/static", "./internal/static")
 
@@ -310,56 +626,41 @@ func setupRouter() *gin.Engine {
 	r.LoadHTMLFiles(files...)
 
 	// 路由
-	r.GET("/health", func(c *gin.Context) {
-		c.JSON(200, gin.H{"status": "ok"})
-	})
+	// 存活检查：进程能处理请求即返回 ok，/health 保留兼容
+	livez := func(c *gin.Context) {
+		c.JSON(200, gin.H{"status": service.HealthStatusOK})
+	}
+	r.GET("/health", livez)
+	r.GET("/livez", livez)
+
+	// 就绪检查：Redis、OSS 和各分类缓存任一异常时返回 503，内网和管理员会话可以查看各依赖详情
+	healthNetworks, err := middleware.ParseCIDRs(appConfig.Health.DetailCIDRs)
+	if err != nil {
+		logger.LogError("Invalid health.detail_cidrs: %v", err)
+		fmt.Printf("Invalid health.detail_cidrs: %v\n", err)
+		os.Exit(1)
+	}
+	r.GET("/readyz", middleware.RateLimit(middleware.RateLimitGroupHealth), middleware.OptionalSession(adminAuth, sessions, users), handleReadyz(healthNetworks))
 
 	// 新增路由：提供 API 文档页面
 	r.GET("/", func(c *gin.Context) {
 		c.HTML(http.StatusOK, "index.html", nil) // 渲染 index.html 页面
 	})
 
-	// 新增 /resetCache 接口，并为其添加限流中间件
-	r.GET("/resetCache", middleware.RateLimit(5), func(c *gin.Context) {
-
-		// 调用 initWallpaperCache 函数，传入 redis 客户端和 OSS 存储桶
-		err := resetCache(rdb, bucket)
-		if err != nil {
-			utils.ErrorResponse(c, 500, err.Error(), "Failed to initialize cache")
-			return
-		}
-		utils.SuccessResponseNoData(c, "Cache initialized successfully")
-	})
-
-	// 新增 /refreshCacheByDevice 接口，并为其添加限流中间件
-	r.GET("/refreshCacheByDevice", middleware.RateLimit(5), func(c *gin.Context) {
-		deviceType := c.Query("type") // 获取查询参数 "type" 的值
-
-		// 校验设备类型是否合法
-		if !service.ValidateDeviceType(deviceType) {
-			logger.LogError(fmt.Sprintf("Invalid device type '%s' provided in request", deviceType))
-			utils.ErrorResponse(c, 400, "invalid device type", fmt.Sprintf("The device type '%s' is not recognized or supported.", deviceType))
-			return
-		}
-
-		// 调用 refreshCacheByDevice 函数，传入 redis 客户端和 OSS 存储桶
-		err := refreshCacheByDevice(rdb, bucket, deviceType)
-		if err != nil {
-			logger.LogError(fmt.Sprintf("Error refreshing cache for device type '%s': %v", deviceType, err))
-			utils.ErrorResponse(c, 500, err.Error(), "Failed to refresh cache")
-			return
-		}
+	// 公开接口的 API Key 校验（需要 read 权限，未开启 required 时允许匿名访问）
+	readKeyAuth := middleware.APIKeyAuth(apiKeys, service.ScopeRead, appConfig.APIKey.Required)
 
-		logger.LogInfo(fmt.Sprintf("Cache for device type '%s' refreshed successfully", deviceType))
-		utils.SuccessResponseNoData(c, fmt.Sprintf("Cache for device type '%s' refreshed successfully", deviceType))
-	})
+	// 防盗链：按 Referer/Origin 校验嵌入来源（全局、分类和 API Key 配置）
+	hotlink := newHotlinkPolicy()
 
 	// 给 /wallpaper 路由添加限流中间件 (群组)
 	wallpaperGroup := r.Group("/wallpaper")
 	{
-		// 添加限流中间件（每秒 5 请求/每个 IP）
-		wallpaperGroup.Use(middleware.RateLimit(5))
-		wallpaperGroup.GET("", handleWallpaper)
+		// 添加限流中间件（默认每秒 5 请求/每个 IP，携带 API Key 时按 Key 限流）
+		wallpaperGroup.Use(readKeyAuth, middleware.RateLimit(middleware.RateLimitGroupWallpaper))
+		wallpaperGroup.GET("", middleware.Hotlink(hotlink, appConfig.Hotlink.FallbackImage), handleWallpaper)
+		// 壁纸轮播推送（SSE / WebSocket）
+		wallpaperGroup.GET("/stream", middleware.Hotlink(hotlink, ""), handleWallpaperStream)
 	}
 
 	// 处理路由不存在的情况
@@ -367,14 +668,83 @@ func setupRouter() *gin.Engine {
 		utils.ErrorResponseNoError(c, 404, "The page or route you requested does not exist")
 	})
 
-	// 图片上传接口
-	r.POST("/upload", middleware.RateLimit(2), uploadWallpapers)
+	// 查询指定deviceType下的所有图片
+	r.GET("/selectImages", readKeyAuth, middleware.RateLimit(middleware.RateLimitGroupSelectImages), getWallpapers)
 
-	// 图片删除接口
-	r.GET("/delete", middleware.RateLimit(2), deleteWallpaper)
+	// OSS / S3 存储桶事件通知（请求自带签名校验）
+	r.POST("/oss/events", handleBucketEvents)
 
-	// 查询指定deviceType下的所有图片
-	r.GET("/selectImages", middleware.RateLimit(2), getWallpapers)
+	// 登录会话：管理员密码或 OIDC 登录后签发 JWT Cookie
+	authGroup := r.Group("/auth", middleware.RateLimit(middleware.RateLimitGroupAuth), middleware.OptionalSession(adminAuth, sessions, users))
+	{
+		authGroup.POST("/login", login)
+		authGroup.POST("/logout", logout)
+		authGroup.GET("/me", currentUser)
+		authGroup.GET("/oidc/login", oidcLogin)
+		authGroup.GET("/oidc/callback", oidcCallback)
+	}
+
+	// 管理接口：统一认证（管理员凭据、登录会话或 API Key），限流（默认每秒 2 请求/每个 IP），按路由校验角色权限
+	adminGroup := r.Group("/admin", middleware.RateLimit(middleware.RateLimitGroupAdmin), middleware.AdminAuth(adminAuth, apiKeys, sessions, users))
+	{
+		requireCache := middleware.RequirePermission(service.PermCacheManage)
+
+		// 后台重建缓存，返回任务 ID（admin）
+		adminGroup.POST("/cache/reset", requireCache, handleResetCache)
+		adminGroup.POST("/cache/refresh", requireCache, handleRefreshCache)
+		adminGroup.GET("/jobs/:id", requireCache, getJob)
+
+		// 最近一次 OSS 与 Redis 对账报告（admin）
+		adminGroup.GET("/reconcile", requireCache, getReconcileReport)
+
+		// 图片上传、删除（uploader 只能删除自己上传的壁纸），元数据、标签和合集（curator）
+		adminGroup.POST("/upload", middleware.RequirePermission(service.PermWallpaperUpload), uploadWallpapers)
+		adminGroup.DELETE("/wallpapers/:deviceType/:fileName", middleware.RequirePermission(service.PermWallpaperDeleteAny, service.PermWallpaperDeleteOwn), deleteWallpaper)
+		adminGroup.GET("/wallpapers/:deviceType", middleware.RequirePermission(service.PermWallpaperRead), listWallpaperMeta)
+		adminGroup.PUT("/wallpapers/:deviceType/:fileName/tags", middleware.RequirePermission(service.PermTagsManage), setWallpaperTags)
+
+		// 壁纸合集：查看需要 wallpaper:read，维护需要 collections:manage（curator）
+		collectionGroup := adminGroup.Group("/collections")
+		{
+			collectionGroup.GET("", middleware.RequirePermission(service.PermWallpaperRead), listCollections)
+			collectionGroup.GET("/:id", middleware.RequirePermission(service.PermWallpaperRead), getCollection)
+			collectionGroup.POST("", middleware.RequirePermission(service.PermCollectionsManage), saveCollection)
+			collectionGroup.DELETE("/:id", middleware.RequirePermission(service.PermCollectionsManage), deleteCollection)
+		}
+
+		// API Key 管理（admin）
+		keyGroup := adminGroup.Group("/keys", middleware.RequirePermission(service.PermKeysManage))
+		{
+			keyGroup.POST("", createAPIKey)
+			keyGroup.GET("", listAPIKeys)
+			keyGroup.DELETE("/:id", revokeAPIKey)
+		}
+
+		// 用户和角色管理（admin）
+		userGroup := adminGroup.Group("/users", middleware.RequirePermission(service.PermUsersManage))
+		{
+			userGroup.POST("", saveUser)
+			userGroup.GET("", listUsers)
+			userGroup.DELETE("/:username", deleteUser)
+		}
+
+		// 限流策略查看和重新加载（admin）
+		adminGroup.GET("/rate-limit", middleware.RequirePermission(service.PermConfigManage), getRateLimitPolicy)
+		adminGroup.POST("/rate-limit/reload", middleware.RequirePermission(service.PermConfigManage), handleReloadRateLimit)
+
+		// 审计日志查询（admin）
+		adminGroup.GET("/audit", middleware.RequirePermission(service.PermAuditRead), listAuditLog)
+
+		// Webhook 订阅管理（admin）
+		webhookGroup := adminGroup.Group("/webhooks", middleware.RequirePermission(service.PermWebhooksManage))
+		{
+			webhookGroup.POST("", createWebhook)
+			webhookGroup.GET("", listWebhooks)
+			webhookGroup.GET("/dead-letter", listWebhookDeadLetters)
+			webhookGroup.DELETE("/:id", deleteWebhook)
+			webhookGroup.GET("/:id/deliveries", listWebhookDeliveries)
+		}
+	}
 
 	return r
 }
@@ -385,35 +755,55 @@ func handleWallpaper(c *gin.Context) {
 	dataType := c.Query("dataType") // 额外的参数，判断返回格式
 
 	// 记录接收到的请求信息
-	logger.LogInfoAsync("Received request for wallpaper, device type: %s, dataType: %s", deviceType, dataType)
+	log := middleware.Log(c)
+	log.Debugf("Received request for wallpaper, device type: %s, dataType: %s", deviceType, dataType)
+
+	// 未指定 type 或 type=auto 时，根据 User-Agent 和 Client Hints 自动识别设备类型
+	if service.IsAutoDeviceType(deviceType) {
+		deviceType = service.DetectDeviceType(c.Request.Header)
+		c.Header("Accept-CH", strings.Join(service.ClientHintHeaders, ", "))
+		c.Writer.Header().Add("Vary", strings.Join(service.DeviceVaryHeaders, ", "))
+		log.Debugf("Detected device type %s from request headers", deviceType)
+	}
 
 	// 校验设备类型是否合法
 	if !service.ValidateDeviceType(deviceType) {
-		logger.LogErrorAsync(fmt.Sprintf("Invalid device type '%s' provided in request", deviceType))
+		log.Warnf("Invalid device type '%s' provided in request", deviceType)
 		utils.ErrorResponse(c, 400, "invalid device type", fmt.Sprintf("The device type '%s' is not recognized or supported.", deviceType))
 		return
 	}
+	log = log.WithField(logger.FieldDeviceType, deviceType)
 
 	// 获取随机壁纸
-	filename, err := service.GetRandomWallpaper(rdb, deviceType)
+	filename, err := service.GetRandomWallpaper(c.Request.Context(), rdb, deviceType)
 	if err != nil {
-		logger.LogErrorAsync(fmt.Sprintf("Error fetching wallpaper for device type %s: %v", deviceType, err))
+		log.WithError(err).Error("Error fetching wallpaper")
 		utils.ErrorResponse(c, 500, "server error", fmt.Sprintf("An error occurred while fetching the wallpaper for device type '%s'. Error: %v", deviceType, err))
 		return
 	}
 
 	// 如果没有找到壁纸
 	if filename == "" {
-		logger.LogErrorAsync(fmt.Sprintf("No wallpaper found for device type %s", deviceType))
+		log.Error("No wallpaper found")
 		utils.ErrorResponse(c, 404, "no wallpaper found", fmt.Sprintf("No wallpapers are available for the device type '%s'.", deviceType))
 		return
 	}
 
-	// 图片的绝对路径
-	imageURL := fmt.Sprintf("%s/%s/%s", appConfig.CDN.BaseURL, deviceType, filename)
+	// 图片的绝对路径（开启签名时为限时有效的地址）
+	imageURL, err := urlSigner.WallpaperURL(deviceType, filename)
+	if err != nil {
+		log.WithError(err).Errorf("Error signing wallpaper URL for %s", filename)
+		utils.ErrorResponse(c, 500, "server error", "An error occurred while generating the wallpaper URL.")
+		return
+	}
+	if urlSigner.Signed() {
+		// 签名地址会过期，禁止客户端和代理缓存跳转结果
+		c.Header("Cache-Control", "no-store")
+	}
 
 	// 记录返回的图片链接
-	logger.LogInfoAsync(fmt.Sprintf("Returning wallpaper URL for device type %s: %s", deviceType, imageURL))
+	log.WithField("wallpaper", filename).Infof("Returning wallpaper URL: %s", imageURL)
+	middleware.SetWallpaper(c, deviceType, filename)
 
 	// 判断 dataType 是否为 "json" 或 "url"，决定返回 JSON 还是 302 跳转
 	switch dataType {
@@ -430,20 +820,116 @@ func handleWallpaper(c *gin.Context) {
 	c.Redirect(http.StatusFound, imageURL)
 }
 
-// 上传图片接口
-func uploadWallpapers(c *gin.Context) {
+// 就绪检查：来自 networks 的请求和有 cache:manage 权限的会话返回各依赖的状态、耗时和错误，其余请求只返回整体状态
+func handleReadyz(networks []*net.IPNet) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if draining.Load() {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"status": service.HealthStatusDraining})
+			return
+		}
 
-	deviceType := c.PostForm("deviceType") // 额外的参数，判断返回格式
-	password := c.PostForm("password")     // 上传图片时需要验证密码
+		report := health.Ready(c.Request.Context())
+		code := http.StatusOK
+		if report.Status != service.HealthStatusOK {
+			code = http.StatusServiceUnavailable
+			middleware.Log(c).WithField("checks", report.Checks).Warn("Readiness check failed")
+		}
+		if middleware.IPInNetworks(c.ClientIP(), networks) || middleware.HasPermission(c, service.PermCacheManage) {
+			c.JSON(code, report)
+			return
+		}
+		c.JSON(code, gin.H{"status": report.Status})
+	}
+}
+
+// 后台重建所有壁纸缓存
+func handleResetCache(c *gin.Context) {
+	submitRebuildJob(c, service.DeviceTypes)
+}
+
+// 后台重建指定类型的壁纸缓存
+func handleRefreshCache(c *gin.Context) {
+	deviceType := c.Query("type") // 获取查询参数 "type" 的值
+
+	// 校验设备类型是否合法
+	if !service.ValidateDeviceType(deviceType) {
+		middleware.Log(c).Warnf("Invalid device type '%s' provided in request", deviceType)
+		utils.ErrorResponse(c, 400, "invalid device type", fmt.Sprintf("The device type '%s' is not recognized or supported.", deviceType))
+		return
+	}
+
+	submitRebuildJob(c, []string{deviceType})
+}
+
+// 提交缓存重建任务，返回 202 和任务详情
+func submitRebuildJob(c *gin.Context, deviceTypes []string) {
+	targets := make([]string, 0, len(deviceTypes))
+	for _, deviceType := range deviceTypes {
+		targets = append(targets, "cache:"+deviceType)
+	}
+
+	job, err := jobs.SubmitRebuild(context.Background(), deviceTypes)
+	var inProgress *service.RebuildInProgressError
+	if errors.As(err, &inProgress) {
+		utils.ErrorResponse(c, 409, "rebuild in progress", fmt.Sprintf("Cache rebuild for device type '%s' is already running as job '%s'.", inProgress.DeviceType, inProgress.JobID))
+		recordAudit(c, service.AuditCacheRebuild, targets, nil, nil, err)
+		return
+	}
+	if errors.Is(err, service.ErrJobManagerStopped) {
+		utils.ErrorResponse(c, 503, "shutting down", "The server is shutting down, please retry later.")
+		recordAudit(c, service.AuditCacheRebuild, targets, nil, nil, err)
+		return
+	}
+	if err != nil {
+		middleware.Log(c).WithError(err).Errorf("Error submitting rebuild job for %v", deviceTypes)
+		utils.ErrorResponse(c, 500, err.Error(), "Failed to submit cache rebuild job")
+		recordAudit(c, service.AuditCacheRebuild, targets, nil, nil, err)
+		return
+	}
+
+	middleware.Log(c).WithField("job_id", job.ID).Infof("Rebuild job submitted for %v", deviceTypes)
+	utils.AcceptedResponse(c, "Cache rebuild job submitted", job)
+	recordAudit(c, service.AuditCacheRebuild, targets, nil, gin.H{"jobId": job.ID}, nil)
+}
+
+// 查询缓存重建任务
+func getJob(c *gin.Context) {
+	id := c.Param("id")
 
-	if password != appConfig.INDEX.Password {
-		utils.ErrorResponse(c, 400, "invalid password", "密码错误，请输入正确的密码")
+	job, err := jobs.GetJob(context.Background(), id)
+	if errors.Is(err, service.ErrJobNotFound) {
+		utils.ErrorResponse(c, 404, "job not found", fmt.Sprintf("Job '%s' does not exist or has expired.", id))
+		return
+	}
+	if err != nil {
+		utils.ErrorResponse(c, 500, "query job error", fmt.Sprintf("Failed to query job '%s': %v", id, err))
 		return
 	}
+	utils.SuccessResponse(c, "Job retrieved successfully", job)
+}
+
+// 查询最近一次对账报告
+func getReconcileReport(c *gin.Context) {
+	report, err := service.LastReconcileReport(context.Background(), rdb)
+	if err != nil {
+		utils.ErrorResponse(c, 500, "query reconcile report error", fmt.Sprintf("Failed to query reconcile report: %v", err))
+		return
+	}
+	if report == nil {
+		utils.ErrorResponseNoError(c, 404, "No reconcile has run yet")
+		return
+	}
+	utils.SuccessResponse(c, "Reconcile report retrieved successfully", report)
+}
+
+// 上传图片接口
+func uploadWallpapers(c *gin.Context) {
+
+	deviceType := c.PostForm("deviceType") // 额外的参数，判断返回格式
 
 	// 校验设备类型是否合法
 	if !service.ValidateDeviceType(deviceType) {
-		logger.LogError(fmt.Sprintf("Invalid device type '%s' provided in request", deviceType))
+		middleware.Log(c).Warnf("Invalid device type '%s' provided in request", deviceType)
 		utils.ErrorResponse(c, 400, "invalid device type", fmt.Sprintf("The device type '%s' is not recognized or supported.", deviceType))
 		return
 	}
@@ -458,8 +944,28 @@ func uploadWallpapers(c *gin.Context) {
 		return
 	}
 
+	// 记录上传者
+	meta := service.NewWallpaperMeta(middleware.PrincipalFromContext(c))
+
 	// 批量上传的结果
 	var uploadedFiles []string
+	var uploadedNames []string
+
+	// 审计日志：对象为本次提交的全部文件，after 为实际上传成功的文件（中途失败时也会记录）
+	requestedNames := make([]string, 0, len(files))
+	for _, file := range files {
+		requestedNames = append(requestedNames, file.Filename)
+	}
+	var uploadErr error
+	defer func() {
+		recordAudit(c, service.AuditWallpaperUpload, wallpaperTargets(deviceType, requestedNames...), nil,
+			gin.H{"uploaded": uploadedNames, "uploader": meta.Uploader}, uploadErr)
+	}()
+
+	// 上传前校验全部文件，避免部分文件已上传后才失败
+	principal := middleware.PrincipalFromContext(c)
+	canOverwriteAny := middleware.HasPermission(c, service.PermWallpaperDeleteAny)
+	existingTags := make(map[string][]string)
 	for _, file := range files {
 		// 校验文件类型是否是图片
 		if !service.IsImageFile(file.Filename) {
@@ -467,9 +973,39 @@ func uploadWallpapers(c *gin.Context) {
 			return
 		}
 
+		// 同名文件会覆盖已有壁纸并改写上传者，没有删除任意壁纸的权限时只能覆盖自己上传的壁纸
+		existing, err := service.GetWallpaperMeta(context.Background(), rdb, deviceType, file.Filename)
+		if err != nil {
+			uploadErr = err
+			utils.ErrorResponse(c, 500, "query meta error", fmt.Sprintf("Failed to query meta of '%s': %v", file.Filename, err))
+			return
+		}
+		if existing != nil {
+			existingTags[file.Filename] = existing.Tags
+		}
+		if canOverwriteAny {
+			continue
+		}
+		exists := existing != nil
+		if !exists {
+			if exists, err = service.WallpaperExists(context.Background(), rdb, deviceType, file.Filename); err != nil {
+				uploadErr = err
+				utils.ErrorResponse(c, 500, "query wallpaper error", fmt.Sprintf("Failed to query wallpaper '%s': %v", file.Filename, err))
+				return
+			}
+		}
+		if exists && (existing == nil || !existing.IsUploadedBy(principal)) {
+			middleware.Log(c).WithField(logger.FieldDeviceType, deviceType).Warnf("%s tried to overwrite '%s' uploaded by someone else", meta.Uploader, file.Filename)
+			utils.ErrorResponse(c, 403, "forbidden", fmt.Sprintf("The wallpaper '%s' already exists and was uploaded by someone else.", file.Filename))
+			return
+		}
+	}
+
+	for _, file := range files {
 		// 上传文件到OSS
-		ossFileURL, err := service.UploadToOSS(file, bucket, appConfig, deviceType)
+		ossFileURL, err := service.UploadToOSS(c.Request.Context(), file, bucket, urlSigner, deviceType, meta.Uploader)
 		if err != nil {
+			uploadErr = err
 			utils.ErrorResponse(c, 500, "Failed to upload image", fmt.Sprintf("Error uploading '%s' to OSS: %v", file.Filename, err))
 			return
 		}
@@ -477,6 +1013,7 @@ func uploadWallpapers(c *gin.Context) {
 		// 将图片添加到壁纸缓存
 		err = service.AddToWallpaperCache(file.Filename, rdb, deviceType)
 		if err != nil {
+			uploadErr = err
 			utils.ErrorResponse(c, 500, "Failed to update wallpaper cache", fmt.Sprintf("Error adding '%s' to wallpaper cache: %v", file.Filename, err))
 			return
 		}
@@ -484,67 +1021,99 @@ func uploadWallpapers(c *gin.Context) {
 		// 将图片添加到随机壁纸缓存
 		err = service.AddToRandomWallpaperCache(file.Filename, rdb, deviceType)
 		if err != nil {
+			uploadErr = err
 			utils.ErrorResponse(c, 500, "Failed to update random wallpaper cache", fmt.Sprintf("Error adding '%s' to random wallpaper cache: %v", file.Filename, err))
 			return
 		}
 
+		// 覆盖已有壁纸时保留标签
+		fileMeta := meta
+		fileMeta.Tags = existingTags[file.Filename]
+		if err := service.SaveWallpaperMeta(context.Background(), rdb, deviceType, file.Filename, fileMeta); err != nil {
+			middleware.Log(c).WithError(err).WithField(logger.FieldDeviceType, deviceType).Errorf("Error saving meta for '%s'", file.Filename)
+		}
+
 		uploadedFiles = append(uploadedFiles, ossFileURL)
+		uploadedNames = append(uploadedNames, file.Filename)
+
+		// 每个文件写入壁纸库后立即通知，后续文件失败时已上传的文件也会触发事件和 Webhook
+		publishEvent(service.NewLibraryEvent(service.EventWallpaperCreated, deviceType, file.Filename))
 	}
 
+	middleware.Log(c).WithField(logger.FieldDeviceType, deviceType).Infof("%s uploaded %v", meta.Uploader, uploadedNames)
+
 	// 返回上传成功的文件URL
 	utils.SuccessResponse(c, "Files uploaded successfully", uploadedFiles)
 }
 
 // 删除指定 deviceType 和 图片名称的壁纸接口
 func deleteWallpaper(c *gin.Context) {
-	// Define request structure
-	type DeleteWallpaperRequest struct {
-		DeviceType string `json:"deviceType" binding:"required"`
-		FileName   string `json:"fileName" binding:"required"`
-		Password   string `json:"password" binding:"required"`
-	}
-
-	var req DeleteWallpaperRequest
+	deviceType := c.Param("deviceType")
+	fileName := c.Param("fileName")
 
-	// Parse and validate request body
-	if err := c.ShouldBindJSON(&req); err != nil {
-		utils.ErrorResponse(c, 400, "invalid parameters", "Invalid request parameters. Please check deviceType, fileName, and password.")
+	if fileName == "" {
+		utils.ErrorResponse(c, 400, "invalid parameters", "Invalid request parameters. Please check deviceType and fileName.")
 		return
 	}
 
-	// Password check (hash comparison recommended)
-	if req.Password != appConfig.INDEX.Password {
-		utils.ErrorResponse(c, 401, "invalid password", "Authentication failed. Incorrect password.")
+	// Validate device type
+	if !service.ValidateDeviceType(deviceType) {
+		middleware.Log(c).Warnf("Invalid device type '%s' provided in request", deviceType)
+		utils.ErrorResponse(c, 400, "invalid device type", fmt.Sprintf("Device type '%s' is not supported.", deviceType))
 		return
 	}
 
-	// Validate device type
-	if !service.ValidateDeviceType(req.DeviceType) {
-		logger.LogError(fmt.Sprintf("Invalid device type '%s' provided in request", req.DeviceType))
-		utils.ErrorResponse(c, 400, "invalid device type", fmt.Sprintf("Device type '%s' is not supported.", req.DeviceType))
+	// 删除前的元数据，用于权限校验和审计日志
+	meta, err := service.GetWallpaperMeta(context.Background(), rdb, deviceType, fileName)
+	if err != nil {
+		utils.ErrorResponse(c, 500, "query meta error", fmt.Sprintf("Failed to query meta of '%s': %v", fileName, err))
 		return
 	}
+	targets := wallpaperTargets(deviceType, fileName)
+
+	// 没有删除任意壁纸的权限时，只能删除自己上传的壁纸
+	if !middleware.HasPermission(c, service.PermWallpaperDeleteAny) {
+		if meta == nil || !meta.IsUploadedBy(middleware.PrincipalFromContext(c)) {
+			utils.ErrorResponse(c, 403, "forbidden", "You can only delete wallpapers you uploaded.")
+			recordAudit(c, service.AuditWallpaperDelete, targets, meta, nil, nil)
+			return
+		}
+	}
 
 	// Delete from OSS
-	if err := service.DeleteFromOSS(req.FileName, req.DeviceType, bucket); err != nil {
-		utils.ErrorResponse(c, 500, "delete error", fmt.Sprintf("Failed to delete '%s' from OSS: %v", req.FileName, err))
+	if err := service.DeleteFromOSS(c.Request.Context(), fileName, deviceType, bucket); err != nil {
+		utils.ErrorResponse(c, 500, "delete error", fmt.Sprintf("Failed to delete '%s' from OSS: %v", fileName, err))
+		recordAudit(c, service.AuditWallpaperDelete, targets, meta, nil, err)
 		return
 	}
 
 	// Remove from wallpaper cache
-	if err := service.RemoveFromWallpaperCache(req.FileName, rdb, req.DeviceType); err != nil {
-		utils.ErrorResponse(c, 500, "cache update error", fmt.Sprintf("Failed to remove '%s' from wallpaper cache: %v", req.FileName, err))
+	if err := service.RemoveFromWallpaperCache(fileName, rdb, deviceType); err != nil {
+		utils.ErrorResponse(c, 500, "cache update error", fmt.Sprintf("Failed to remove '%s' from wallpaper cache: %v", fileName, err))
+		recordAudit(c, service.AuditWallpaperDelete, targets, meta, nil, err)
 		return
 	}
 
 	// Remove from random wallpaper cache
-	if err := service.RemoveFromRandomWallpaperCache(req.FileName, rdb, req.DeviceType); err != nil {
-		utils.ErrorResponse(c, 500, "random cache update error", fmt.Sprintf("Failed to remove '%s' from random wallpaper cache: %v", req.FileName, err))
+	if err := service.RemoveFromRandomWallpaperCache(fileName, rdb, deviceType); err != nil {
+		utils.ErrorResponse(c, 500, "random cache update error", fmt.Sprintf("Failed to remove '%s' from random wallpaper cache: %v", fileName, err))
+		recordAudit(c, service.AuditWallpaperDelete, targets, meta, nil, err)
 		return
 	}
 
+	if err := service.DeleteWallpaperMeta(context.Background(), rdb, deviceType, fileName); err != nil {
+		middleware.Log(c).WithError(err).WithField(logger.FieldDeviceType, deviceType).Errorf("Error deleting meta for '%s'", fileName)
+	}
+	if err := service.RemoveFromCollections(context.Background(), rdb, deviceType, fileName); err != nil {
+		middleware.Log(c).WithError(err).WithField(logger.FieldDeviceType, deviceType).Errorf("Error removing '%s' from collections", fileName)
+	}
+
+	// 通知壁纸库已删除
+	publishEvent(service.NewLibraryEvent(service.EventWallpaperDeleted, deviceType, fileName))
+
 	// 返回删除成功的响应
 	utils.SuccessResponse(c, "Image deleted successfully", nil)
+	recordAudit(c, service.AuditWallpaperDelete, targets, meta, nil, nil)
 }
 
 // 查询壁纸的接口
@@ -553,13 +1122,13 @@ func getWallpapers(c *gin.Context) {
 
 	// 校验设备类型是否合法
 	if !service.ValidateDeviceType(deviceType) {
-		logger.LogError(fmt.Sprintf("Invalid device type '%s' provided in request", deviceType))
+		middleware.Log(c).Warnf("Invalid device type '%s' provided in request", deviceType)
 		utils.ErrorResponse(c, 400, "invalid device type", fmt.Sprintf("The device type '%s' is not recognized or supported.", deviceType))
 		return
 	}
 
 	// 获取图片 URL 列表
-	wallpaperURLs, err := service.GetWallpaperURLsFromOSS(bucket, deviceType, appConfig)
+	wallpaperURLs, err := service.GetWallpaperURLsFromOSS(c.Request.Context(), bucket, deviceType, urlSigner)
 	if err != nil {
 		utils.ErrorResponse(c, 500, "Failed to retrieve wallpapers", fmt.Sprintf("Error: %v", err))
 		return
@@ -568,3 +1137,71 @@ func getWallpapers(c *gin.Context) {
 	// 返回图片 URL 列表
 	utils.SuccessResponse(c, "Wallpapers retrieved successfully", wallpaperURLs)
 }
+
+// 查询指定类型壁纸的元数据（上传者、上传时间）
+func listWallpaperMeta(c *gin.Context) {
+	deviceType := c.Param("deviceType")
+	if !service.ValidateDeviceType(deviceType) {
+		utils.ErrorResponse(c, 400, "invalid device type", fmt.Sprintf("Device type '%s' is not supported.", deviceType))
+		return
+	}
+
+	metas, err := service.ListWallpaperMeta(context.Background(), rdb, deviceType)
+	if err != nil {
+		utils.ErrorResponse(c, 500, "query meta error", fmt.Sprintf("Failed to query wallpaper meta: %v", err))
+		return
+	}
+	utils.SuccessResponse(c, "Wallpaper meta retrieved successfully", metas)
+}
+
+// 设置壁纸标签
+func setWallpaperTags(c *gin.Context) {
+	type SetTagsRequest struct {
+		Tags []string `json:"tags"`
+	}
+
+	deviceType := c.Param("deviceType")
+	fileName := c.Param("fileName")
+	if !service.ValidateDeviceType(deviceType) {
+		utils.ErrorResponse(c, 400, "invalid device type", fmt.Sprintf("Device type '%s' is not supported.", deviceType))
+		return
+	}
+
+	var req SetTagsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, 400, "invalid parameters", "Invalid request parameters. Please check tags.")
+		return
+	}
+
+	exists, err := service.WallpaperExists(context.Background(), rdb, deviceType, fileName)
+	if err != nil {
+		utils.ErrorResponse(c, 500, "query wallpaper error", fmt.Sprintf("Failed to query wallpaper '%s': %v", fileName, err))
+		return
+	}
+	if !exists {
+		utils.ErrorResponse(c, 404, "wallpaper not found", fmt.Sprintf("Wallpaper '%s' does not exist.", fileName))
+		return
+	}
+
+	before, err := service.GetWallpaperMeta(context.Background(), rdb, deviceType, fileName)
+	if err != nil {
+		utils.ErrorResponse(c, 500, "query meta error", fmt.Sprintf("Failed to query meta of '%s': %v", fileName, err))
+		return
+	}
+	var beforeTags []string
+	if before != nil {
+		beforeTags = before.Tags
+	}
+	targets := wallpaperTargets(deviceType, fileName)
+
+	meta, err := service.SetWallpaperTags(context.Background(), rdb, deviceType, fileName, req.Tags)
+	if err != nil {
+		utils.ErrorResponse(c, 400, "set tags error", err.Error())
+		recordAudit(c, service.AuditTagsUpdate, targets, gin.H{"tags": beforeTags}, gin.H{"tags": req.Tags}, err)
+		return
+	}
+
+	middleware.Log(c).WithField(logger.FieldDeviceType, deviceType).Infof("%s set tags of %s to %v", middleware.PrincipalFromContext(c).DisplayName(), fileName, meta.Tags)
+	utils.SuccessResponse(c, "Tags updated successfully", meta)
+	recordAudit(c, service.AuditTagsUpdate, targets, gin.H{"tags": beforeTags}, gin.H{"tags": meta.Tags}, nil)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/TXM983/wallpaper-api-v1/internal/logger"
+	"github.com/TXM983/wallpaper-api-v1/internal/middleware"
+	"github.com/TXM983/wallpaper-api-v1/internal/service"
+	utils "github.com/TXM983/wallpaper-api-v1/internal/util"
+	"github.com/gin-gonic/gin"
+)
+
+// 事件通知请求体大小上限
+const maxBucketEventBody = 1 << 20
+
+// 接收 OSS（MNS HTTP 推送）和 S3 风格的存储桶事件通知，同步到 Redis
+func handleBucketEvents(c *gin.Context) {
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxBucketEventBody))
+	if err != nil {
+		utils.ErrorResponse(c, 400, "invalid body", fmt.Sprintf("Failed to read notification body: %v", err))
+		return
+	}
+
+	// 校验签名
+	if service.IsMNSNotification(c.Request.Header) {
+		err = service.VerifyMNSSignature(c.Request, body)
+	} else {
+		err = service.VerifySharedSecret(c.Request.Header, body, appConfig.OSSEvent.Secret)
+	}
+	if err != nil {
+		middleware.Log(c).WithError(err).Warn("Rejected bucket event notification")
+		utils.ErrorResponse(c, 401, "invalid signature", "Event notification signature verification failed.")
+		return
+	}
+
+	events, err := service.ParseBucketNotification(body)
+	if err != nil {
+		middleware.Log(c).WithError(err).Error("Error parsing bucket event notification")
+		utils.ErrorResponse(c, 400, "invalid notification", err.Error())
+		return
+	}
+
+	applied, ignored := 0, 0
+	ctx := context.Background()
+	for _, event := range events {
+		// 忽略其他存储桶的事件
+		if appConfig.OSS.Bucket != "" && event.Bucket != "" && event.Bucket != appConfig.OSS.Bucket {
+			ignored++
+			continue
+		}
+
+		changed, err := service.ApplyObjectEvent(ctx, rdb, event)
+		if err != nil {
+			middleware.Log(c).WithError(err).WithField(logger.FieldDeviceType, event.DeviceType).Errorf("Error applying %s event for %s", event.EventName, event.Key)
+			utils.ErrorResponse(c, 500, "sync error", fmt.Sprintf("Failed to apply event for '%s': %v", event.Key, err))
+			return
+		}
+		applied++
+		middleware.Log(c).WithField(logger.FieldDeviceType, event.DeviceType).Infof("Applied bucket event %s for %s", event.EventName, event.Key)
+
+		// 通过接口上传/删除的文件已发布过事件，只有壁纸列表实际变化时才发布
+		if !changed {
+			continue
+		}
+		if event.Action == service.ObjectEventCreated {
+			publishEvent(service.NewLibraryEvent(service.EventWallpaperCreated, event.DeviceType, event.Filename))
+		} else {
+			publishEvent(service.NewLibraryEvent(service.EventWallpaperDeleted, event.DeviceType, event.Filename))
+		}
+	}
+
+	utils.SuccessResponse(c, "Bucket events applied successfully", gin.H{"applied": applied, "ignored": ignored})
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/TXM983/wallpaper-api-v1/internal/config"
+	"github.com/TXM983/wallpaper-api-v1/internal/logger"
+	"github.com/TXM983/wallpaper-api-v1/internal/middleware"
+	"github.com/TXM983/wallpaper-api-v1/internal/service"
+	utils "github.com/TXM983/wallpaper-api-v1/internal/util"
+	"github.com/gin-gonic/gin"
+)
+
+// 限流策略的 JSON 展示
+type rateLimitRuleView struct {
+	Rate       int `json:"rate"`
+	Burst      int `json:"burst,omitempty"`
+	BanSeconds int `json:"banSeconds"`
+}
+
+type cidrRuleView struct {
+	CIDR string `json:"cidr"`
+	rateLimitRuleView
+}
+
+// 将配置中的规则转换为限流规则，未配置封禁时长时使用默认值
+func toRateLimitRule(rule config.RateLimitRule, defaultBan int) (middleware.RateLimitRule, error) {
+	if rule.Rate <= 0 {
+		return middleware.RateLimitRule{}, fmt.Errorf("rate must be positive")
+	}
+	if rule.Burst < 0 {
+		return middleware.RateLimitRule{}, fmt.Errorf("burst must not be negative")
+	}
+	ban := defaultBan
+	if rule.BanSeconds != nil {
+		ban = *rule.BanSeconds
+	}
+	return middleware.RateLimitRule{Rate: rule.Rate, Burst: rule.Burst, Ban: time.Duration(ban) * time.Second}, nil
+}
+
+// 根据配置创建限流策略
+func buildRateLimitPolicy(cfg *config.AppConfig) (*middleware.RateLimitPolicy, error) {
+	rl := cfg.RateLimit
+	policy := &middleware.RateLimitPolicy{
+		Groups:  make(map[string]middleware.RateLimitRule, len(rl.Groups)),
+		APIKeys: make(map[string]middleware.RateLimitRule, len(rl.APIKeys)),
+	}
+
+	for group, rule := range rl.Groups {
+		converted, err := toRateLimitRule(rule, rl.BanSeconds)
+		if err != nil {
+			return nil, fmt.Errorf("group '%s': %v", group, err)
+		}
+		policy.Groups[group] = converted
+	}
+
+	// API Key 默认不封禁
+	for id, rule := range rl.APIKeys {
+		converted, err := toRateLimitRule(rule, 0)
+		if err != nil {
+			return nil, fmt.Errorf("api key '%s': %v", id, err)
+		}
+		policy.APIKeys[id] = converted
+	}
+
+	for _, cidr := range rl.CIDRs {
+		converted, err := toRateLimitRule(cidr.RateLimitRule, rl.BanSeconds)
+		if err != nil {
+			return nil, fmt.Errorf("cidr '%s': %v", cidr.CIDR, err)
+		}
+		rule, err := middleware.NewCIDRRule(cidr.CIDR, converted)
+		if err != nil {
+			return nil, err
+		}
+		policy.CIDRs = append(policy.CIDRs, rule)
+	}
+
+	var err error
+	if policy.Allow, err = middleware.ParseCIDRs(rl.AllowCIDRs); err != nil {
+		return nil, fmt.Errorf("allow_cidrs: %v", err)
+	}
+	if policy.Deny, err = middleware.ParseCIDRs(rl.DenyCIDRs); err != nil {
+		return nil, fmt.Errorf("deny_cidrs: %v", err)
+	}
+	return policy, nil
+}
+
+// 重新读取配置文件和环境变量并替换限流策略，配置有误时保留原策略。
+// 限流驱动在启动时确定，修改 driver 需要重启才能生效
+func reloadRateLimitPolicy() error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	policy, err := buildRateLimitPolicy(cfg)
+	if err != nil {
+		return err
+	}
+
+	middleware.SetRateLimitPolicy(policy)
+	logger.LogInfo("Rate limit policy reloaded")
+
+	driver := cfg.RateLimit.Driver
+	if driver == "" {
+		driver = middleware.LimiterDriverMemory
+	}
+	if active := middleware.RateLimiter.Driver(); driver != active {
+		logger.Log.Warnf("rate_limit.driver changed from %s to %s, restart to switch drivers", active, driver)
+	}
+	return nil
+}
+
+// 收到 SIGHUP 时重新加载限流策略，操作者记为 system
+func reloadRateLimitOnSignal() {
+	entry := &service.AuditEntry{
+		Actor:   "system",
+		Method:  "signal",
+		Action:  service.AuditRateLimitReload,
+		Targets: []string{"config:rate_limit"},
+		Before:  rateLimitPolicyView(),
+		Result:  service.AuditResultSuccess,
+	}
+	if err := reloadRateLimitPolicy(); err != nil {
+		logger.LogError("Failed to reload rate limit policy: %v", err)
+		entry.Result = service.AuditResultFailure
+		entry.Error = err.Error()
+	} else {
+		entry.After = rateLimitPolicyView()
+	}
+	if err := auditLog.Record(context.Background(), entry); err != nil {
+		logger.LogError("Failed to record audit entry for %s: %v", entry.Action, err)
+	}
+}
+
+func toRuleView(rule middleware.RateLimitRule) rateLimitRuleView {
+	return rateLimitRuleView{Rate: rule.Rate, Burst: rule.Burst, BanSeconds: int(rule.Ban.Seconds())}
+}
+
+// 查询当前生效的限流策略
+func getRateLimitPolicy(c *gin.Context) {
+	utils.SuccessResponse(c, "Rate limit policy retrieved successfully", rateLimitPolicyView())
+}
+
+// 当前生效的限流策略，用于查询接口和审计日志
+func rateLimitPolicyView() gin.H {
+	policy := middleware.CurrentRateLimitPolicy()
+
+	groups := make(map[string]rateLimitRuleView, len(policy.Groups))
+	for group, rule := range policy.Groups {
+		groups[group] = toRuleView(rule)
+	}
+	apiKeys := make(map[string]rateLimitRuleView, len(policy.APIKeys))
+	for id, rule := range policy.APIKeys {
+		apiKeys[id] = toRuleView(rule)
+	}
+	cidrs := make([]cidrRuleView, 0, len(policy.CIDRs))
+	for _, rule := range policy.CIDRs {
+		cidrs = append(cidrs, cidrRuleView{CIDR: rule.Network.String(), rateLimitRuleView: toRuleView(rule.Rule)})
+	}
+	networks := func(list []*net.IPNet) []string {
+		result := make([]string, 0, len(list))
+		for _, n := range list {
+			result = append(result, n.String())
+		}
+		return result
+	}
+
+	return gin.H{
+		"driver":     middleware.RateLimiter.Driver(),
+		"groups":     groups,
+		"apiKeys":    apiKeys,
+		"cidrs":      cidrs,
+		"allowCidrs": networks(policy.Allow),
+		"denyCidrs":  networks(policy.Deny),
+	}
+}
+
+// 重新加载限流策略
+func handleReloadRateLimit(c *gin.Context) {
+	targets := []string{"config:rate_limit"}
+	before := rateLimitPolicyView()
+	if err := reloadRateLimitPolicy(); err != nil {
+		middleware.Log(c).WithError(err).Error("Failed to reload rate limit policy")
+		utils.ErrorResponse(c, 400, "reload error", fmt.Sprintf("Failed to reload rate limit policy, the previous policy is kept: %v", err))
+		recordAudit(c, service.AuditRateLimitReload, targets, before, nil, err)
+		return
+	}
+	after := rateLimitPolicyView()
+	utils.SuccessResponse(c, "Rate limit policy reloaded successfully", after)
+	recordAudit(c, service.AuditRateLimitReload, targets, before, after, nil)
+}
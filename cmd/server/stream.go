@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/TXM983/wallpaper-api-v1/internal/logger"
+	"github.com/TXM983/wallpaper-api-v1/internal/middleware"
+	"github.com/TXM983/wallpaper-api-v1/internal/service"
+	"github.com/TXM983/wallpaper-api-v1/internal/tracing"
+	utils "github.com/TXM983/wallpaper-api-v1/internal/util"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// 推送间隔的默认值和上下限
+const (
+	defaultStreamInterval = 60 * time.Second
+	minStreamInterval     = 5 * time.Second
+	maxStreamInterval     = 24 * time.Hour
+)
+
+// WebSocket 写超时
+const wsWriteTimeout = 10 * time.Second
+
+// 退出时通知长连接推送结束（server.Shutdown 不会等待或中断长连接）
+var streamsCtx, stopStreams = context.WithCancel(context.Background())
+
+var wsUpgrader = websocket.Upgrader{
+	// 壁纸推送为公开数据，允许任意来源嵌入
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// StreamPayload 推送给客户端的壁纸数据
+type StreamPayload struct {
+	DeviceType string `json:"deviceType"`
+	URL        string `json:"url"`
+	Reason     string `json:"reason"` // initial / tick / wallpaper.created / wallpaper.deleted
+	Timestamp  int64  `json:"timestamp"`
+}
+
+// 解析推送间隔，支持 "60s"、"5m" 以及纯数字秒数
+func parseStreamInterval(value string) (time.Duration, error) {
+	if value == "" {
+		return defaultStreamInterval, nil
+	}
+
+	interval, err := time.ParseDuration(value)
+	if err != nil {
+		seconds, convErr := strconv.Atoi(value)
+		if convErr != nil {
+			return 0, fmt.Errorf("invalid interval '%s'", value)
+		}
+		interval = time.Duration(seconds) * time.Second
+	}
+
+	if interval < minStreamInterval || interval > maxStreamInterval {
+		return 0, fmt.Errorf("interval must be between %s and %s", minStreamInterval, maxStreamInterval)
+	}
+	return interval, nil
+}
+
+// 获取一张随机壁纸并组装推送数据
+func nextStreamPayload(ctx context.Context, deviceType string, reason string) (payload *StreamPayload, err error) {
+	ctx, span := tracing.Start(ctx, "wallpaper.stream.push", attribute.String("wallpaper.device_type", deviceType), attribute.String("stream.reason", reason))
+	defer func() { tracing.End(span, err) }()
+
+	filename, err := service.GetRandomWallpaper(ctx, rdb, deviceType)
+	if err != nil {
+		return nil, err
+	}
+	if filename == "" {
+		return nil, fmt.Errorf("no wallpapers available for device type %s", deviceType)
+	}
+
+	imageURL, err := urlSigner.WallpaperURL(deviceType, filename)
+	if err != nil {
+		return nil, err
+	}
+
+	return &StreamPayload{
+		DeviceType: deviceType,
+		URL:        imageURL,
+		Reason:     reason,
+		Timestamp:  time.Now().Unix(),
+	}, nil
+}
+
+// 壁纸轮播推送接口，默认使用 SSE，携带 WebSocket 升级头时切换为 WebSocket
+func handleWallpaperStream(c *gin.Context) {
+	deviceType := c.Query("type")
+	if service.IsAutoDeviceType(deviceType) {
+		deviceType = service.DetectDeviceType(c.Request.Header)
+	}
+
+	if !service.ValidateDeviceType(deviceType) {
+		middleware.Log(c).Warnf("Invalid device type '%s' provided in stream request", deviceType)
+		utils.ErrorResponse(c, 400, "invalid device type", fmt.Sprintf("The device type '%s' is not recognized or supported.", deviceType))
+		return
+	}
+
+	interval, err := parseStreamInterval(c.Query("interval"))
+	if err != nil {
+		utils.ErrorResponse(c, 400, "invalid interval", err.Error())
+		return
+	}
+
+	if websocket.IsWebSocketUpgrade(c.Request) {
+		serveWallpaperWebSocket(c, deviceType, interval)
+		return
+	}
+	serveWallpaperSSE(c, deviceType, interval)
+}
+
+// SSE 推送
+func serveWallpaperSSE(c *gin.Context, deviceType string, interval time.Duration) {
+	ctx := c.Request.Context()
+
+	// 订阅壁纸库变更，上传或删除时立即推送
+	sub := eventBus.Subscribe(service.EventWallpaperCreated, service.EventWallpaperDeleted)
+	defer sub.Close()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // 关闭 nginx 缓冲
+
+	log := middleware.Log(c).WithField(logger.FieldDeviceType, deviceType)
+	log.Infof("SSE stream opened, interval %s", interval)
+
+	send := func(reason string) bool {
+		payload, err := nextStreamPayload(ctx, deviceType, reason)
+		if err != nil {
+			log.WithError(err).Error("Error preparing stream payload")
+			c.SSEvent("error", gin.H{"error": err.Error()})
+		} else {
+			c.SSEvent("wallpaper", payload)
+		}
+		c.Writer.Flush()
+		return ctx.Err() == nil
+	}
+
+	if !send("initial") {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	events := sub.C
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info("SSE stream closed")
+			return
+		case <-streamsCtx.Done():
+			// 通知客户端服务正在退出，由客户端重连到其他实例
+			c.SSEvent("shutdown", gin.H{"reason": "server shutting down"})
+			c.Writer.Flush()
+			log.Info("SSE stream closed for shutdown")
+			return
+		case <-ticker.C:
+			if !send("tick") {
+				return
+			}
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if event.DeviceType != deviceType {
+				continue
+			}
+			if !send(string(event.Type)) {
+				return
+			}
+			ticker.Reset(interval)
+		}
+	}
+}
+
+// WebSocket 推送
+func serveWallpaperWebSocket(c *gin.Context, deviceType string, interval time.Duration) {
+	ctx := c.Request.Context()
+	conn, err := wsUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		middleware.Log(c).WithError(err).Error("Error upgrading to WebSocket")
+		return
+	}
+	defer conn.Close()
+
+	sub := eventBus.Subscribe(service.EventWallpaperCreated, service.EventWallpaperDeleted)
+	defer sub.Close()
+
+	log := middleware.Log(c).WithField(logger.FieldDeviceType, deviceType)
+	log.Infof("WebSocket stream opened, interval %s", interval)
+
+	// 读取客户端消息以处理 close/ping 帧，连接断开时通知写循环退出
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	send := func(reason string) bool {
+		conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+		payload, err := nextStreamPayload(ctx, deviceType, reason)
+		if err != nil {
+			log.WithError(err).Error("Error preparing stream payload")
+			return conn.WriteJSON(gin.H{"error": err.Error()}) == nil
+		}
+		return conn.WriteJSON(payload) == nil
+	}
+
+	if !send("initial") {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	events := sub.C
+	for {
+		select {
+		case <-closed:
+			log.Info("WebSocket stream closed")
+			return
+		case <-streamsCtx.Done():
+			conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"), time.Now().Add(wsWriteTimeout))
+			log.Info("WebSocket stream closed for shutdown")
+			return
+		case <-ticker.C:
+			if !send("tick") {
+				return
+			}
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if event.DeviceType != deviceType {
+				continue
+			}
+			if !send(string(event.Type)) {
+				return
+			}
+			ticker.Reset(interval)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/TXM983/wallpaper-api-v1/internal/middleware"
+	"github.com/TXM983/wallpaper-api-v1/internal/service"
+	utils "github.com/TXM983/wallpaper-api-v1/internal/util"
+	"github.com/gin-gonic/gin"
+)
+
+// 创建或更新用户，password 为空时保留原密码（OIDC 用户无需密码）
+func saveUser(c *gin.Context) {
+	type SaveUserRequest struct {
+		Username string `json:"username" binding:"required"`
+		Password string `json:"password"`
+		Name     string `json:"name"`
+		Email    string `json:"email"`
+		Role     string `json:"role" binding:"required"`
+	}
+
+	var req SaveUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, 400, "invalid parameters", "Invalid request parameters. Please check username and role.")
+		return
+	}
+
+	// 配置文件中的管理员账号不能在用户表中重复定义
+	if req.Username == adminAuth.Username() {
+		utils.ErrorResponse(c, 400, "invalid username", fmt.Sprintf("Username '%s' is reserved for the configured administrator.", req.Username))
+		return
+	}
+
+	// 修改前的用户，用于审计日志
+	targets := []string{"user:" + req.Username}
+	var before interface{}
+	if existing, err := users.Get(context.Background(), req.Username); err == nil {
+		before = existing.Public()
+	}
+
+	user, err := users.Save(context.Background(), service.User{
+		Username: req.Username,
+		Name:     req.Name,
+		Email:    req.Email,
+		Role:     req.Role,
+	}, req.Password)
+	if err != nil {
+		utils.ErrorResponse(c, 400, "save user error", err.Error())
+		recordAudit(c, service.AuditUserSave, targets, before, gin.H{"role": req.Role}, err)
+		return
+	}
+
+	middleware.Log(c).WithField("role", user.Role).Infof("User %s saved", user.Username)
+	utils.SuccessResponse(c, "User saved successfully", user.Public())
+	recordAudit(c, service.AuditUserSave, targets, before, gin.H{"user": user.Public(), "passwordChanged": req.Password != ""}, nil)
+}
+
+// 列出用户
+func listUsers(c *gin.Context) {
+	list, err := users.List(context.Background())
+	if err != nil {
+		utils.ErrorResponse(c, 500, "list users error", fmt.Sprintf("Failed to list users: %v", err))
+		return
+	}
+	utils.SuccessResponse(c, "Users retrieved successfully", list)
+}
+
+// 删除用户
+func deleteUser(c *gin.Context) {
+	username := c.Param("username")
+	targets := []string{"user:" + username}
+
+	var before interface{}
+	user, err := users.Get(context.Background(), username)
+	if err == nil {
+		before = user.Public()
+		err = users.Delete(context.Background(), username)
+	}
+	if errors.Is(err, service.ErrUserNotFound) {
+		utils.ErrorResponse(c, 404, "user not found", fmt.Sprintf("User '%s' does not exist.", username))
+		return
+	}
+	if err != nil {
+		utils.ErrorResponse(c, 500, "delete user error", fmt.Sprintf("Failed to delete user '%s': %v", username, err))
+		recordAudit(c, service.AuditUserDelete, targets, before, nil, err)
+		return
+	}
+
+	middleware.Log(c).Infof("User %s deleted", username)
+	utils.SuccessResponseNoData(c, "User deleted successfully")
+	recordAudit(c, service.AuditUserDelete, targets, before, nil, nil)
+}
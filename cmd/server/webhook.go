@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/TXM983/wallpaper-api-v1/internal/middleware"
+	"github.com/TXM983/wallpaper-api-v1/internal/service"
+	utils "github.com/TXM983/wallpaper-api-v1/internal/util"
+	"github.com/gin-gonic/gin"
+)
+
+// 投递记录查询条数上限
+const maxWebhookLogLimit = 200
+
+// 解析 limit 查询参数
+func parseLimit(c *gin.Context, defaultLimit int64, maxLimit int64) int64 {
+	limit, err := strconv.ParseInt(c.Query("limit"), 10, 64)
+	if err != nil || limit <= 0 {
+		return defaultLimit
+	}
+	if limit > maxLimit {
+		return maxLimit
+	}
+	return limit
+}
+
+// 创建 Webhook 订阅
+func createWebhook(c *gin.Context) {
+	type CreateWebhookRequest struct {
+		URL    string              `json:"url" binding:"required"`
+		Secret string              `json:"secret"`
+		Events []service.EventType `json:"events"`
+	}
+
+	var req CreateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, 400, "invalid parameters", "Invalid request parameters. Please check url and events.")
+		return
+	}
+
+	webhook, err := webhooks.Create(context.Background(), req.URL, req.Secret, req.Events)
+	if err != nil {
+		utils.ErrorResponse(c, 400, "create webhook error", err.Error())
+		recordAudit(c, service.AuditWebhookCreate, nil, nil, gin.H{"url": req.URL, "events": req.Events}, err)
+		return
+	}
+
+	middleware.Log(c).WithField("webhook_id", webhook.ID).Infof("Webhook created for %s", webhook.URL)
+	// 密钥仅在创建时返回一次
+	utils.SuccessResponse(c, "Webhook created successfully", webhook)
+	recordAudit(c, service.AuditWebhookCreate, []string{"webhook:" + webhook.ID}, nil, gin.H{"url": webhook.URL, "events": webhook.Events}, nil)
+}
+
+// 列出 Webhook 订阅（隐藏密钥）
+func listWebhooks(c *gin.Context) {
+	list, err := webhooks.List(context.Background())
+	if err != nil {
+		utils.ErrorResponse(c, 500, "list webhooks error", fmt.Sprintf("Failed to list webhooks: %v", err))
+		return
+	}
+
+	for i := range list {
+		list[i].Secret = ""
+	}
+	utils.SuccessResponse(c, "Webhooks retrieved successfully", list)
+}
+
+// 删除 Webhook 订阅
+func deleteWebhook(c *gin.Context) {
+	id := c.Param("id")
+	targets := []string{"webhook:" + id}
+
+	var before interface{}
+	webhook, err := webhooks.Get(context.Background(), id)
+	if err == nil {
+		before = gin.H{"url": webhook.URL, "events": webhook.Events}
+		err = webhooks.Delete(context.Background(), id)
+	}
+	if errors.Is(err, service.ErrWebhookNotFound) {
+		utils.ErrorResponse(c, 404, "webhook not found", fmt.Sprintf("Webhook '%s' does not exist.", id))
+		return
+	}
+	if err != nil {
+		utils.ErrorResponse(c, 500, "delete webhook error", fmt.Sprintf("Failed to delete webhook '%s': %v", id, err))
+		recordAudit(c, service.AuditWebhookDelete, targets, before, nil, err)
+		return
+	}
+
+	middleware.Log(c).WithField("webhook_id", id).Info("Webhook deleted")
+	utils.SuccessResponseNoData(c, "Webhook deleted successfully")
+	recordAudit(c, service.AuditWebhookDelete, targets, before, nil, nil)
+}
+
+// 查询 Webhook 投递记录
+func listWebhookDeliveries(c *gin.Context) {
+	id := c.Param("id")
+
+	if _, err := webhooks.Get(context.Background(), id); err != nil {
+		if errors.Is(err, service.ErrWebhookNotFound) {
+			utils.ErrorResponse(c, 404, "webhook not found", fmt.Sprintf("Webhook '%s' does not exist.", id))
+			return
+		}
+		utils.ErrorResponse(c, 500, "query webhook error", err.Error())
+		return
+	}
+
+	logs, err := webhooks.Deliveries(context.Background(), id, parseLimit(c, 50, maxWebhookLogLimit))
+	if err != nil {
+		utils.ErrorResponse(c, 500, "query deliveries error", fmt.Sprintf("Failed to query deliveries for webhook '%s': %v", id, err))
+		return
+	}
+	utils.SuccessResponse(c, "Webhook deliveries retrieved successfully", logs)
+}
+
+// 查询死信列表
+func listWebhookDeadLetters(c *gin.Context) {
+	deliveries, err := webhooks.DeadLetters(context.Background(), parseLimit(c, 50, maxWebhookLogLimit))
+	if err != nil {
+		utils.ErrorResponse(c, 500, "query dead letters error", fmt.Sprintf("Failed to query dead letters: %v", err))
+		return
+	}
+	utils.SuccessResponse(c, "Webhook dead letters retrieved successfully", deliveries)
+}
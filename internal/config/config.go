@@ -7,9 +7,38 @@ import (
 
 type AppConfig struct {
 	Server struct {
-		Port int `mapstructure:"port"`
+		Port           int      `mapstructure:"port"`
+		TrustedProxies []string `mapstructure:"trusted_proxies"`  // 可信代理（Nginx、CDN 回源）网段，为空表示不信任任何代理，直接使用连接地址
+		ClientIPHeader string   `mapstructure:"client_ip_header"` // 可信代理传递客户端 IP 的请求头：X-Forwarded-For、X-Real-IP、CF-Connecting-IP、Ali-CDN-Real-IP
+
+		ShutdownDelaySeconds   int `mapstructure:"shutdown_delay_seconds"`   // 收到退出信号后 /readyz 返回 503，等待负载均衡摘除实例的时长（秒）
+		ShutdownTimeoutSeconds int `mapstructure:"shutdown_timeout_seconds"` // 退出时等待进行中的请求和后台任务的时长（秒）
 	} `mapstructure:"server"`
 
+	Log struct {
+		Level      string `mapstructure:"level"`        // debug、info、warn、error
+		Format     string `mapstructure:"format"`       // text 或 json
+		Output     string `mapstructure:"output"`       // file、stdout 或 stderr
+		FilePath   string `mapstructure:"file_path"`    // output 为 file 时的日志文件路径
+		MaxSizeMB  int    `mapstructure:"max_size_mb"`  // 单个日志文件最大大小（MB）
+		MaxBackups int    `mapstructure:"max_backups"`  // 保留的备份文件数
+		MaxAgeDays int    `mapstructure:"max_age_days"` // 备份文件保留天数
+		TimeZone   string `mapstructure:"time_zone"`    // 日志时间的时区，为空时使用本地时区
+		BufferSize int    `mapstructure:"buffer_size"`  // 异步写入队列长度，0 表示同步写入
+	} `mapstructure:"log"`
+
+	AccessLog struct {
+		Enabled    bool     `mapstructure:"enabled"`      // 是否记录访问日志
+		Format     string   `mapstructure:"format"`       // common、combined 或 json
+		Output     string   `mapstructure:"output"`       // file、stdout 或 stderr
+		FilePath   string   `mapstructure:"file_path"`    // output 为 file 时的访问日志文件路径，需与应用日志不同
+		MaxSizeMB  int      `mapstructure:"max_size_mb"`  // 单个日志文件最大大小（MB）
+		MaxBackups int      `mapstructure:"max_backups"`  // 保留的备份文件数
+		MaxAgeDays int      `mapstructure:"max_age_days"` // 备份文件保留天数
+		BufferSize int      `mapstructure:"buffer_size"`  // 异步写入队列长度，0 表示同步写入
+		SkipPaths  []string `mapstructure:"skip_paths"`   // 不记录的路径，如健康检查和指标抓取
+	} `mapstructure:"access_log"`
+
 	Redis struct {
 		Addr         string `mapstructure:"addr"`
 		Password     string `mapstructure:"password"`
@@ -29,13 +58,139 @@ type AppConfig struct {
 		Bucket          string `mapstructure:"bucket"`
 	} `mapstructure:"oss"`
 
+	URLSigning struct {
+		Mode               string `mapstructure:"mode"`                 // 签名方式：空（不签名）、oss、cdn_a、cdn_b、cdn_c
+		TTLSeconds         int    `mapstructure:"ttl_seconds"`          // 签名 URL 有效期（秒）
+		CDNKey             string `mapstructure:"cdn_key"`              // CDN URL 鉴权主 KEY
+		CDNValiditySeconds int    `mapstructure:"cdn_validity_seconds"` // CDN 控制台配置的鉴权有效时长（秒）
+	} `mapstructure:"url_signing"`
+
+	Hotlink struct {
+		Enabled           bool     `mapstructure:"enabled"`
+		AllowEmptyReferer bool     `mapstructure:"allow_empty_referer"` // 是否允许没有 Referer/Origin 的请求
+		Allow             []string `mapstructure:"allow"`               // 允许嵌入的域名，为空表示不限制
+		Deny              []string `mapstructure:"deny"`                // 禁止嵌入的域名，优先于允许列表
+		Categories        map[string]struct {
+			Allow []string `mapstructure:"allow"`
+			Deny  []string `mapstructure:"deny"`
+		} `mapstructure:"categories"` // 按设备类型配置，允许列表非空时覆盖全局
+		FallbackImage string `mapstructure:"fallback_image"` // 不允许的来源跳转到的兜底图片，为空时返回 403
+	} `mapstructure:"hotlink"`
+
+	OSSEvent struct {
+		Secret string `mapstructure:"secret"` // S3 风格事件通知的共享密钥（MNS 推送使用证书验签）
+	} `mapstructure:"oss_event"`
+
 	INDEX struct {
-		Password string `mapstructure:"password"`
+		Password string `mapstructure:"password"` // 已废弃：明文密码，未配置 admin.password_hash 时启动时自动哈希
 	} `mapstructure:"index"`
+
+	Admin struct {
+		Username     string   `mapstructure:"username"`      // Basic 认证用户名
+		PasswordHash string   `mapstructure:"password_hash"` // bcrypt 密码哈希
+		Tokens       []string `mapstructure:"tokens"`        // API Token 的 SHA-256 十六进制摘要
+	} `mapstructure:"admin"`
+
+	RateLimit struct {
+		Driver     string                   `mapstructure:"driver"`      // 限流驱动：memory（进程内）或 redis（多实例共享），修改后需重启
+		BanSeconds int                      `mapstructure:"ban_seconds"` // 未单独配置时超出限流后的封禁时长（秒），0 表示不封禁
+		Groups     map[string]RateLimitRule `mapstructure:"groups"`      // 路由分组规则：wallpaper、select_images、auth、admin、default
+		APIKeys    map[string]RateLimitRule `mapstructure:"api_keys"`    // API Key ID -> 规则
+		CIDRs      []struct {
+			CIDR          string `mapstructure:"cidr"`
+			RateLimitRule `mapstructure:",squash"`
+		} `mapstructure:"cidrs"` // 网段规则，按顺序匹配，优先于分组规则
+		AllowCIDRs []string `mapstructure:"allow_cidrs"` // 不限流的网段（内网、监控）
+		DenyCIDRs  []string `mapstructure:"deny_cidrs"`  // 直接返回 403 的网段
+	} `mapstructure:"rate_limit"`
+
+	Session struct {
+		Secret       string `mapstructure:"secret"`        // 会话 JWT 的 HMAC 密钥，为空时启动时随机生成
+		TTLMinutes   int    `mapstructure:"ttl_minutes"`   // 会话有效期（分钟）
+		CookieSecure bool   `mapstructure:"cookie_secure"` // 会话 Cookie 是否只通过 HTTPS 发送
+	} `mapstructure:"session"`
+
+	OIDC struct {
+		Issuer       string   `mapstructure:"issuer"`        // OIDC 提供方地址，为空表示关闭
+		ClientID     string   `mapstructure:"client_id"`     // 客户端 ID
+		ClientSecret string   `mapstructure:"client_secret"` // 客户端密钥
+		RedirectURL  string   `mapstructure:"redirect_url"`  // 回调地址，如 https://example.com/auth/oidc/callback
+		Scopes       []string `mapstructure:"scopes"`        // 申请的 scope
+		// 允许登录的邮箱和邮箱域名，用户表中已登记的邮箱始终允许；都为空时只允许已登记的用户
+		AllowedEmails  []string `mapstructure:"allowed_emails"`
+		AllowedDomains []string `mapstructure:"allowed_domains"`
+	} `mapstructure:"oidc"`
+
+	RBAC struct {
+		DefaultRole string `mapstructure:"default_role"` // 未登记但在允许列表中的 OIDC 用户的角色，为空表示拒绝访问管理接口
+	} `mapstructure:"rbac"`
+
+	APIKey struct {
+		Required         bool `mapstructure:"required"`           // 公开接口是否必须携带 API Key
+		DefaultRateLimit int  `mapstructure:"default_rate_limit"` // 未单独配置的 Key 的默认速率（每秒请求数）
+	} `mapstructure:"api_key"`
+
+	Reconcile struct {
+		IntervalSeconds int `mapstructure:"interval_seconds"` // OSS 与 Redis 对账间隔（秒），0 表示关闭
+	} `mapstructure:"reconcile"`
+
+	Webhook struct {
+		Workers        int `mapstructure:"workers"`         // 并发投递协程数
+		MaxAttempts    int `mapstructure:"max_attempts"`    // 最大尝试次数，超过后进入死信
+		TimeoutSeconds int `mapstructure:"timeout_seconds"` // 单次请求超时（秒）
+		BackoffSeconds int `mapstructure:"backoff_seconds"` // 首次重试间隔（秒），之后指数增长
+	} `mapstructure:"webhook"`
+
+	Health struct {
+		TimeoutSeconds int      `mapstructure:"timeout_seconds"` // /readyz 单个依赖的检查超时（秒）
+		DetailCIDRs    []string `mapstructure:"detail_cidrs"`    // 可以查看 /readyz 各依赖详情的网段（内网），其余请求只返回状态
+	} `mapstructure:"health"`
+
+	Audit struct {
+		MaxLen        int64 `mapstructure:"max_len"`        // 审计日志最多保留条数，0 表示不限制
+		RetentionDays int   `mapstructure:"retention_days"` // 审计日志保留天数，0 表示不限制
+	} `mapstructure:"audit"`
+
+	Metrics struct {
+		Enabled    bool     `mapstructure:"enabled"`     // 是否开启 /metrics
+		Token      string   `mapstructure:"token"`       // 抓取时需携带的 Bearer Token
+		AllowCIDRs []string `mapstructure:"allow_cidrs"` // 不需要 Token 即可抓取的网段（内网）
+	} `mapstructure:"metrics"`
+
+	Tracing struct {
+		Enabled     bool              `mapstructure:"enabled"`      // 是否开启 OpenTelemetry 链路追踪
+		ServiceName string            `mapstructure:"service_name"` // 上报的服务名
+		Endpoint    string            `mapstructure:"endpoint"`     // OTLP/HTTP 地址（host:port），为空时使用 OTEL_EXPORTER_OTLP_ENDPOINT 或 localhost:4318
+		Insecure    bool              `mapstructure:"insecure"`     // 使用 HTTP 连接 Collector
+		Headers     map[string]string `mapstructure:"headers"`      // 导出时附加的请求头
+		SampleRatio float64           `mapstructure:"sample_ratio"` // 根 Span 采样率（0-1）
+	} `mapstructure:"tracing"`
+}
+
+// InternalCIDRs 本机和内网网段，作为内部接口的默认允许网段
+var InternalCIDRs = []string{"127.0.0.0/8", "::1", "10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "fc00::/7"}
+
+// RateLimitRule 限流规则
+type RateLimitRule struct {
+	Rate       int  `mapstructure:"rate"`        // 每秒请求数
+	Burst      int  `mapstructure:"burst"`       // 突发请求数，0 表示等于 rate
+	BanSeconds *int `mapstructure:"ban_seconds"` // 封禁时长（秒），未配置时使用 rate_limit.ban_seconds
 }
 
-// LoadConfig 加载配置文件，并支持从环境变量读取
+// LoadConfig 加载配置文件，并支持从环境变量读取，失败时退出
 func LoadConfig() *AppConfig {
+	cfg, err := Load()
+	if err != nil {
+		logrus.Fatalf("unable to decode config into struct: %s", err)
+	}
+
+	logrus.Info("Loaded config successfully!")
+
+	return cfg
+}
+
+// Load 加载配置文件和环境变量，用于启动和运行时重新加载
+func Load() (*AppConfig, error) {
 	v := viper.New()
 
 	// 设置配置文件的名称和类型
@@ -46,6 +201,63 @@ func LoadConfig() *AppConfig {
 	v.AddConfigPath("./configs")
 	v.AddConfigPath("/app/configs/")
 
+	// 默认值
+	v.SetDefault("server.client_ip_header", "X-Forwarded-For")
+	v.SetDefault("server.shutdown_timeout_seconds", 30)
+	v.SetDefault("log.level", "info")
+	v.SetDefault("log.format", "text")
+	v.SetDefault("log.output", "file")
+	v.SetDefault("log.file_path", "logs/application.log")
+	v.SetDefault("log.max_size_mb", 10)
+	v.SetDefault("log.max_backups", 3)
+	v.SetDefault("log.max_age_days", 28)
+	v.SetDefault("log.time_zone", "Asia/Shanghai")
+	v.SetDefault("log.buffer_size", 1024)
+	v.SetDefault("access_log.enabled", true)
+	v.SetDefault("access_log.format", "combined")
+	v.SetDefault("access_log.output", "file")
+	v.SetDefault("access_log.file_path", "logs/access.log")
+	v.SetDefault("access_log.max_size_mb", 50)
+	v.SetDefault("access_log.max_backups", 5)
+	v.SetDefault("access_log.max_age_days", 28)
+	v.SetDefault("access_log.buffer_size", 1024)
+	v.SetDefault("access_log.skip_paths", []string{"/health", "/livez", "/readyz", "/metrics"})
+	v.SetDefault("url_signing.ttl_seconds", 3600)
+	v.SetDefault("url_signing.cdn_validity_seconds", 1800)
+	v.SetDefault("hotlink.enabled", false)
+	v.SetDefault("hotlink.allow_empty_referer", true)
+	v.SetDefault("admin.username", "admin")
+	v.SetDefault("rate_limit.driver", "memory")
+	v.SetDefault("rate_limit.ban_seconds", 120)
+	v.SetDefault("rate_limit.groups.wallpaper.rate", 5)
+	v.SetDefault("rate_limit.groups.select_images.rate", 2)
+	v.SetDefault("rate_limit.groups.auth.rate", 2)
+	v.SetDefault("rate_limit.groups.admin.rate", 2)
+	v.SetDefault("rate_limit.groups.health.rate", 10)
+	v.SetDefault("rate_limit.groups.health.ban_seconds", 0)
+	v.SetDefault("rate_limit.groups.default.rate", 2)
+	v.SetDefault("session.ttl_minutes", 720)
+	v.SetDefault("session.cookie_secure", false)
+	v.SetDefault("oidc.scopes", []string{"openid", "profile", "email"})
+	v.SetDefault("rbac.default_role", "viewer")
+	v.SetDefault("api_key.required", false)
+	v.SetDefault("api_key.default_rate_limit", 10)
+	v.SetDefault("reconcile.interval_seconds", 600)
+	v.SetDefault("webhook.workers", 2)
+	v.SetDefault("webhook.max_attempts", 5)
+	v.SetDefault("webhook.timeout_seconds", 10)
+	v.SetDefault("webhook.backoff_seconds", 5)
+	v.SetDefault("health.timeout_seconds", 2)
+	v.SetDefault("health.detail_cidrs", InternalCIDRs)
+	v.SetDefault("audit.max_len", 100000)
+	v.SetDefault("audit.retention_days", 180)
+	v.SetDefault("metrics.enabled", false)
+	v.SetDefault("metrics.allow_cidrs", InternalCIDRs)
+	v.SetDefault("tracing.enabled", false)
+	v.SetDefault("tracing.service_name", "wallpaper-api")
+	v.SetDefault("tracing.insecure", true)
+	v.SetDefault("tracing.sample_ratio", 1.0)
+
 	// 尝试读取配置文件
 	err := v.ReadInConfig()
 	if err != nil {
@@ -59,6 +271,19 @@ func LoadConfig() *AppConfig {
 
 	// 配置环境变量与配置文件键名对应
 	v.BindEnv("server.port", "SERVER_PORT")
+	v.BindEnv("server.trusted_proxies", "TRUSTED_PROXIES")
+	v.BindEnv("server.client_ip_header", "CLIENT_IP_HEADER")
+	v.BindEnv("server.shutdown_delay_seconds", "SHUTDOWN_DELAY_SECONDS")
+	v.BindEnv("server.shutdown_timeout_seconds", "SHUTDOWN_TIMEOUT_SECONDS")
+	v.BindEnv("log.level", "LOG_LEVEL")
+	v.BindEnv("log.format", "LOG_FORMAT")
+	v.BindEnv("log.output", "LOG_OUTPUT")
+	v.BindEnv("log.file_path", "LOG_FILE_PATH")
+	v.BindEnv("log.time_zone", "LOG_TIME_ZONE")
+	v.BindEnv("access_log.enabled", "ACCESS_LOG_ENABLED")
+	v.BindEnv("access_log.format", "ACCESS_LOG_FORMAT")
+	v.BindEnv("access_log.output", "ACCESS_LOG_OUTPUT")
+	v.BindEnv("access_log.file_path", "ACCESS_LOG_FILE_PATH")
 	v.BindEnv("redis.addr", "REDIS_ADDR")
 	v.BindEnv("redis.password", "REDIS_PASSWORD")
 	v.BindEnv("redis.db", "REDIS_DB")
@@ -69,15 +294,59 @@ func LoadConfig() *AppConfig {
 	v.BindEnv("oss.access_key_id", "OSS_ACCESS_KEY_ID")
 	v.BindEnv("oss.access_key_secret", "OSS_ACCESS_KEY_SECRET")
 	v.BindEnv("oss.bucket", "OSS_BUCKET")
+	v.BindEnv("url_signing.mode", "URL_SIGNING_MODE")
+	v.BindEnv("url_signing.ttl_seconds", "URL_SIGNING_TTL_SECONDS")
+	v.BindEnv("url_signing.cdn_key", "URL_SIGNING_CDN_KEY")
+	v.BindEnv("url_signing.cdn_validity_seconds", "URL_SIGNING_CDN_VALIDITY_SECONDS")
+	v.BindEnv("hotlink.enabled", "HOTLINK_ENABLED")
+	v.BindEnv("hotlink.allow_empty_referer", "HOTLINK_ALLOW_EMPTY_REFERER")
+	v.BindEnv("hotlink.allow", "HOTLINK_ALLOW")
+	v.BindEnv("hotlink.deny", "HOTLINK_DENY")
+	v.BindEnv("hotlink.fallback_image", "HOTLINK_FALLBACK_IMAGE")
+	v.BindEnv("oss_event.secret", "OSS_EVENT_SECRET")
 	v.BindEnv("index.password", "PASSWORD")
+	v.BindEnv("admin.username", "ADMIN_USERNAME")
+	v.BindEnv("admin.password_hash", "ADMIN_PASSWORD_HASH")
+	v.BindEnv("admin.tokens", "ADMIN_TOKENS")
+	v.BindEnv("rate_limit.driver", "RATE_LIMIT_DRIVER")
+	v.BindEnv("rate_limit.ban_seconds", "RATE_LIMIT_BAN_SECONDS")
+	v.BindEnv("rate_limit.allow_cidrs", "RATE_LIMIT_ALLOW_CIDRS")
+	v.BindEnv("rate_limit.deny_cidrs", "RATE_LIMIT_DENY_CIDRS")
+	v.BindEnv("session.secret", "SESSION_SECRET")
+	v.BindEnv("session.ttl_minutes", "SESSION_TTL_MINUTES")
+	v.BindEnv("session.cookie_secure", "SESSION_COOKIE_SECURE")
+	v.BindEnv("oidc.issuer", "OIDC_ISSUER")
+	v.BindEnv("oidc.client_id", "OIDC_CLIENT_ID")
+	v.BindEnv("oidc.client_secret", "OIDC_CLIENT_SECRET")
+	v.BindEnv("oidc.redirect_url", "OIDC_REDIRECT_URL")
+	v.BindEnv("oidc.allowed_emails", "OIDC_ALLOWED_EMAILS")
+	v.BindEnv("oidc.allowed_domains", "OIDC_ALLOWED_DOMAINS")
+	v.BindEnv("rbac.default_role", "RBAC_DEFAULT_ROLE")
+	v.BindEnv("api_key.required", "API_KEY_REQUIRED")
+	v.BindEnv("api_key.default_rate_limit", "API_KEY_DEFAULT_RATE_LIMIT")
+	v.BindEnv("reconcile.interval_seconds", "RECONCILE_INTERVAL_SECONDS")
+	v.BindEnv("webhook.workers", "WEBHOOK_WORKERS")
+	v.BindEnv("webhook.max_attempts", "WEBHOOK_MAX_ATTEMPTS")
+	v.BindEnv("webhook.timeout_seconds", "WEBHOOK_TIMEOUT_SECONDS")
+	v.BindEnv("webhook.backoff_seconds", "WEBHOOK_BACKOFF_SECONDS")
+	v.BindEnv("health.timeout_seconds", "HEALTH_TIMEOUT_SECONDS")
+	v.BindEnv("health.detail_cidrs", "HEALTH_DETAIL_CIDRS")
+	v.BindEnv("audit.max_len", "AUDIT_MAX_LEN")
+	v.BindEnv("audit.retention_days", "AUDIT_RETENTION_DAYS")
+	v.BindEnv("metrics.enabled", "METRICS_ENABLED")
+	v.BindEnv("metrics.token", "METRICS_TOKEN")
+	v.BindEnv("metrics.allow_cidrs", "METRICS_ALLOW_CIDRS")
+	v.BindEnv("tracing.enabled", "TRACING_ENABLED")
+	v.BindEnv("tracing.service_name", "TRACING_SERVICE_NAME")
+	v.BindEnv("tracing.endpoint", "TRACING_ENDPOINT")
+	v.BindEnv("tracing.insecure", "TRACING_INSECURE")
+	v.BindEnv("tracing.sample_ratio", "TRACING_SAMPLE_RATIO")
 
 	// 将配置文件内容反序列化到结构体
 	var cfg AppConfig
 	if err := v.Unmarshal(&cfg); err != nil {
-		logrus.Fatalf("unable to decode config into struct: %s", err)
+		return nil, err
 	}
 
-	logrus.Info("Loaded config successfully!")
-
-	return &cfg
+	return &cfg, nil
 }
@@ -0,0 +1,77 @@
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/TXM983/wallpaper-api-v1/internal/logger"
+)
+
+// Manager 管理后台任务（限流器清理、对账、Webhook 投递、事件总线等）：统一通过 context 通知退出，并等待全部结束
+type Manager struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	mu      sync.Mutex
+	running map[string]int
+}
+
+// New 创建后台任务管理器
+func New() *Manager {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Manager{ctx: ctx, cancel: cancel, running: make(map[string]int)}
+}
+
+// Context 退出时取消的 context
+func (m *Manager) Context() context.Context {
+	return m.ctx
+}
+
+// Go 启动后台任务，fn 需在 ctx 结束后尽快返回
+func (m *Manager) Go(name string, fn func(ctx context.Context)) {
+	m.mu.Lock()
+	m.running[name]++
+	m.mu.Unlock()
+
+	m.wg.Add(1)
+	go func() {
+		defer func() {
+			m.mu.Lock()
+			if m.running[name]--; m.running[name] == 0 {
+				delete(m.running, name)
+			}
+			m.mu.Unlock()
+			m.wg.Done()
+			logger.LogInfo("Background task %s stopped", name)
+		}()
+		fn(m.ctx)
+	}()
+}
+
+// Stop 通知全部后台任务退出并等待结束，ctx 到期时返回仍未结束的任务
+func (m *Manager) Stop(ctx context.Context) error {
+	m.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		m.mu.Lock()
+		names := make([]string, 0, len(m.running))
+		for name := range m.running {
+			names = append(names, name)
+		}
+		m.mu.Unlock()
+		sort.Strings(names)
+		return fmt.Errorf("background tasks did not stop in time: %s", strings.Join(names, ", "))
+	}
+}
@@ -1,81 +1,277 @@
 package logger
 
 import (
+	"context"
 	"fmt"
-	"github.com/natefinch/lumberjack"
-	"github.com/sirupsen/logrus"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"time"
+
+	"github.com/natefinch/lumberjack"
+	"github.com/sirupsen/logrus"
 )
 
-// Log 全局日志实例
-var Log *logrus.Logger
+// 结构化日志字段名
+const (
+	FieldRequestID  = "request_id"
+	FieldTraceID    = "trace_id"
+	FieldRoute      = "route"
+	FieldMethod     = "method"
+	FieldDeviceType = "device_type"
+	FieldClientIP   = "client_ip"
+	FieldLatency    = "latency_ms"
+	FieldError      = "error"
+)
+
+// 日志输出
+const (
+	OutputFile   = "file"
+	OutputStdout = "stdout"
+	OutputStderr = "stderr"
+)
 
-// Init 初始化日志配置
-func Init() {
-	Log = logrus.New()
+// 日志格式
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
 
-	// 获取日志文件路径，优先从环境变量中获取，若没有则使用默认路径
-	logFilePath := os.Getenv("LOG_FILE_PATH")
-	if logFilePath == "" {
-		logFilePath = "logs/application.log" // 默认日志文件路径
+// Options 日志配置
+type Options struct {
+	Level      string // debug、info、warn、error
+	Format     string // text 或 json
+	Output     string // file、stdout 或 stderr（容器部署建议 stdout）
+	FilePath   string // Output 为 file 时的日志文件路径
+	MaxSizeMB  int    // 单个日志文件最大大小（MB）
+	MaxBackups int    // 保留的备份文件数
+	MaxAgeDays int    // 备份文件保留天数
+	Compress   bool   // 是否压缩备份文件
+	TimeZone   string // 日志时间的时区，如 Asia/Shanghai、UTC，为空时使用本地时区
+	BufferSize int    // 异步写入队列长度，0 表示同步写入
+}
+
+// DefaultOptions 默认配置：写入 logs/application.log，text 格式，北京时间
+func DefaultOptions() Options {
+	return Options{
+		Level:      "info",
+		Format:     FormatText,
+		Output:     OutputFile,
+		FilePath:   "logs/application.log",
+		MaxSizeMB:  10,
+		MaxBackups: 3,
+		MaxAgeDays: 28,
+		Compress:   true,
+		TimeZone:   "Asia/Shanghai",
+		BufferSize: 1024,
 	}
+}
+
+// Log 全局日志实例，未初始化时输出到标准错误
+var Log = logrus.New()
 
-	// 创建日志文件目录
-	err := os.MkdirAll(filepath.Dir(logFilePath), 0755)
+// 当前的日志输出，退出时需要写完队列并关闭
+var output io.WriteCloser
+
+// Init 按配置初始化日志
+func Init(opts Options) error {
+	level, err := logrus.ParseLevel(strings.ToLower(opts.Level))
 	if err != nil {
-		fmt.Printf("Failed to create log directory: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("invalid log level '%s'", opts.Level)
 	}
 
-	// 设置日志轮转
-	Log.SetOutput(&lumberjack.Logger{
-		Filename:   logFilePath, // 日志文件路径
-		MaxSize:    10,          // 每个日志文件的最大大小（MB）
-		MaxBackups: 3,           // 保留3个备份日志文件
-		MaxAge:     28,          // 日志文件保留28天
-		Compress:   true,        // 启用日志压缩
-	})
+	var formatter logrus.Formatter
+	switch opts.Format {
+	case FormatJSON:
+		formatter = &logrus.JSONFormatter{
+			TimestampFormat: time.RFC3339Nano,
+			FieldMap:        logrus.FieldMap{logrus.FieldKeyMsg: "message"},
+		}
+	case FormatText, "":
+		formatter = &logrus.TextFormatter{
+			FullTimestamp:   true,                      // 启用完整时间戳
+			TimestampFormat: "2006-01-02 15:04:05.000", // 时间格式化
+			DisableColors:   opts.Output == OutputFile,
+		}
+	default:
+		return fmt.Errorf("unsupported log format '%s'", opts.Format)
+	}
 
-	// 设置日志格式
-	Log.SetFormatter(&logrus.TextFormatter{
-		FullTimestamp:   true,                  // 启用完整时间戳
-		TimestampFormat: "2006-01-02 15:04:05", // 时间格式化
-	})
+	var loc *time.Location
+	if opts.TimeZone != "" {
+		if loc, err = time.LoadLocation(opts.TimeZone); err != nil {
+			return fmt.Errorf("invalid log time zone '%s': %v", opts.TimeZone, err)
+		}
+	}
 
-	// 设置日志级别，默认 DebugLevel
-	Log.SetLevel(logrus.DebugLevel)
+	out, err := NewWriter(opts)
+	if err != nil {
+		return err
+	}
+
+	log := logrus.New()
+	log.SetLevel(level)
+	log.SetFormatter(formatter)
+	if loc != nil {
+		log.AddHook(&timeZoneHook{loc: loc})
+	}
+
+	log.SetOutput(out)
 
-	// 使用 Hook 让 logrus 使用北京时间
-	Log.AddHook(NewLocalTimeHook())
+	previous := output
+	Log, output = log, out
+	if previous != nil {
+		previous.Close()
+	}
+	return nil
 }
 
-// LocalTimeHook 自定义 Hook，强制转换日志时间为北京时间
-type LocalTimeHook struct {
-	loc *time.Location
+// 按配置打开输出：标准输出、标准错误或按大小轮转的文件
+func openOutput(opts Options) (io.Writer, error) {
+	switch opts.Output {
+	case OutputStdout:
+		return os.Stdout, nil
+	case OutputStderr:
+		return os.Stderr, nil
+	case OutputFile, "":
+		// 创建日志文件目录
+		if err := os.MkdirAll(filepath.Dir(opts.FilePath), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create log directory: %v", err)
+		}
+		// 设置日志轮转
+		return &lumberjack.Logger{
+			Filename:   opts.FilePath,
+			MaxSize:    opts.MaxSizeMB,
+			MaxBackups: opts.MaxBackups,
+			MaxAge:     opts.MaxAgeDays,
+			Compress:   opts.Compress,
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported log output '%s'", opts.Output)
 }
 
-// NewLocalTimeHook 创建并返回一个新的 LocalTimeHook 实例
-func NewLocalTimeHook() *LocalTimeHook {
-	loc, err := time.LoadLocation("Asia/Shanghai")
+// NewWriter 按配置创建独立的日志输出（如访问日志），使用 Output、FilePath、轮转参数和 BufferSize
+// 返回的写入器并发安全、保持写入顺序，Close 时写完队列并关闭文件
+func NewWriter(opts Options) (io.WriteCloser, error) {
+	out, err := openOutput(opts)
 	if err != nil {
-		// 使用固定时区
-		loc = time.FixedZone("CST", 8*60*60)
+		return nil, err
+	}
+	var w io.Writer = out
+	var async *asyncWriter
+	if opts.BufferSize > 0 {
+		async = newAsyncWriter(out, opts.BufferSize)
+		w = async
+	}
+	return &closingWriter{Writer: w, async: async, out: out}, nil
+}
+
+// 关闭时先写完异步队列，再关闭文件
+type closingWriter struct {
+	io.Writer
+	async *asyncWriter
+	out   io.Writer
+}
+
+func (w *closingWriter) Close() error {
+	if w.async != nil {
+		w.async.Close()
+	}
+	if closer, ok := w.out.(io.Closer); ok && w.out != os.Stdout && w.out != os.Stderr {
+		return closer.Close()
+	}
+	return nil
+}
+
+// Close 写完队列中的日志并关闭日志文件，退出前调用
+func Close() {
+	if output != nil {
+		output.Close()
 	}
-	return &LocalTimeHook{loc: loc}
 }
 
-func (h *LocalTimeHook) Levels() []logrus.Level {
+// 转换日志时间的时区
+type timeZoneHook struct {
+	loc *time.Location
+}
+
+func (h *timeZoneHook) Levels() []logrus.Level {
 	return logrus.AllLevels
 }
 
-func (h *LocalTimeHook) Fire(entry *logrus.Entry) error {
-	// 使用缓存的时区进行时间转换
+func (h *timeZoneHook) Fire(entry *logrus.Entry) error {
 	entry.Time = entry.Time.In(h.loc)
 	return nil
 }
 
+// 有界异步写入器：logrus 在锁内格式化并调用 Write，单个协程按顺序写出，队列满时阻塞调用方而不丢日志
+type asyncWriter struct {
+	out    io.Writer
+	queue  chan []byte
+	done   chan struct{}
+	mu     sync.RWMutex
+	closed bool
+}
+
+func newAsyncWriter(out io.Writer, size int) *asyncWriter {
+	w := &asyncWriter{out: out, queue: make(chan []byte, size), done: make(chan struct{})}
+	go w.run()
+	return w
+}
+
+func (w *asyncWriter) run() {
+	defer close(w.done)
+	for line := range w.queue {
+		if _, err := w.out.Write(line); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to write log: %v\n", err)
+		}
+	}
+}
+
+func (w *asyncWriter) Write(p []byte) (int, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		// 关闭后的日志直接写出
+		return w.out.Write(p)
+	}
+	// logrus 会复用缓冲区，需要复制
+	w.queue <- append([]byte(nil), p...)
+	return len(p), nil
+}
+
+// Close 停止接收并等待队列写完
+func (w *asyncWriter) Close() {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return
+	}
+	w.closed = true
+	close(w.queue)
+	w.mu.Unlock()
+	<-w.done
+}
+
+type entryKey struct{}
+
+// NewContext 将带字段的日志条目放入上下文
+func NewContext(ctx context.Context, entry *logrus.Entry) context.Context {
+	return context.WithValue(ctx, entryKey{}, entry)
+}
+
+// FromContext 获取上下文中的日志条目（包含 request_id 等字段），没有时返回不带字段的条目
+func FromContext(ctx context.Context) *logrus.Entry {
+	if ctx != nil {
+		if entry, ok := ctx.Value(entryKey{}).(*logrus.Entry); ok {
+			return entry
+		}
+	}
+	return logrus.NewEntry(Log)
+}
+
 // LogError 记录错误级别日志
 func LogError(format string, args ...interface{}) {
 	Log.Errorf(format, args...)
@@ -90,26 +286,3 @@ func LogInfo(format string, args ...interface{}) {
 func LogDebug(format string, args ...interface{}) {
 	Log.Debugf(format, args...)
 }
-
-// asyncLogWriter 异步日志记录函数
-func asyncLogWriter(entry *logrus.Entry) {
-	// 将日志记录操作放入 goroutine 中
-	go func() {
-		Log.WithFields(entry.Data).Log(entry.Level, entry.Message)
-	}()
-}
-
-// LogErrorAsync 异步记录错误级别日志
-func LogErrorAsync(format string, args ...interface{}) {
-	go Log.Errorf(format, args...)
-}
-
-// LogInfoAsync 异步记录信息级别日志
-func LogInfoAsync(format string, args ...interface{}) {
-	go Log.Infof(format, args...)
-}
-
-// LogDebugAsync 异步记录调试级别日志
-func LogDebugAsync(format string, args ...interface{}) {
-	go Log.Debugf(format, args...)
-}
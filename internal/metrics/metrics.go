@@ -0,0 +1,261 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "wallpaper"
+
+// 缓存填充的触发方式
+const (
+	RefillTriggerEmpty   = "empty"   // 随机缓存取空后按需填充
+	RefillTriggerRebuild = "rebuild" // 从 OSS 全量重建
+)
+
+// Registry 本服务的指标注册表，包含 Go 运行时和进程指标
+var Registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by method, route and status.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method and route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	randomPops = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "random_pops_total",
+		Help:      "Random wallpapers popped from the cache by category and result.",
+	}, []string{"device_type", "result"})
+
+	cacheRefills = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_refills_total",
+		Help:      "Random wallpaper cache refills by category, trigger and result.",
+	}, []string{"device_type", "trigger", "result"})
+
+	cacheRefillDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "cache_refill_duration_seconds",
+		Help:      "Random wallpaper cache refill duration by category and trigger.",
+		Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"device_type", "trigger"})
+
+	redisDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "redis_command_duration_seconds",
+		Help:      "Redis command latency by command.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"command"})
+
+	redisErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "redis_errors_total",
+		Help:      "Redis command errors by command (nil replies are not counted).",
+	}, []string{"command"})
+
+	storageDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "storage_operation_duration_seconds",
+		Help:      "Object storage call latency by operation.",
+		Buckets:   []float64{.01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+	}, []string{"operation"})
+
+	storageErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "storage_errors_total",
+		Help:      "Object storage call errors by operation.",
+	}, []string{"operation"})
+
+	rateLimitRejections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limit_rejections_total",
+		Help:      "Requests rejected by the rate limiter by group and reason.",
+	}, []string{"group", "reason"})
+
+	uploadBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upload_bytes_total",
+		Help:      "Bytes of wallpapers uploaded by category.",
+	}, []string{"device_type"})
+
+	uploadFiles = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upload_files_total",
+		Help:      "Wallpapers uploaded by category.",
+	}, []string{"device_type"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests, httpDuration, randomPops, cacheRefills, cacheRefillDuration,
+		redisDuration, redisErrors, storageDuration, storageErrors,
+		rateLimitRejections, uploadBytes, uploadFiles,
+	)
+}
+
+// Handler /metrics 接口
+func Handler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry}))
+}
+
+// Middleware 按路由模板统计请求数和耗时，未匹配路由统一记为 unmatched，避免标签基数失控
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		method := c.Request.Method
+		httpRequests.WithLabelValues(method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		httpDuration.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+	}
+}
+
+// 成功或失败的结果标签
+func result(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "ok"
+}
+
+// ObserveRandomPop 记录一次随机壁纸出队
+func ObserveRandomPop(deviceType string, err error) {
+	randomPops.WithLabelValues(deviceType, result(err)).Inc()
+}
+
+// ObserveCacheRefill 记录一次随机缓存填充及耗时
+func ObserveCacheRefill(deviceType string, trigger string, start time.Time, err error) {
+	cacheRefills.WithLabelValues(deviceType, trigger, result(err)).Inc()
+	cacheRefillDuration.WithLabelValues(deviceType, trigger).Observe(time.Since(start).Seconds())
+}
+
+// ObserveStorage 记录一次对象存储调用的耗时和错误
+func ObserveStorage(operation string, start time.Time, err error) {
+	storageDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	if err != nil {
+		storageErrors.WithLabelValues(operation).Inc()
+	}
+}
+
+// ObserveRateLimitRejection 记录一次限流拒绝，reason 为 limited（超出速率）或 banned（封禁中）
+func ObserveRateLimitRejection(group string, banned bool) {
+	reason := "limited"
+	if banned {
+		reason = "banned"
+	}
+	rateLimitRejections.WithLabelValues(group, reason).Inc()
+}
+
+// ObserveUpload 记录一次上传的文件大小
+func ObserveUpload(deviceType string, size int64) {
+	uploadFiles.WithLabelValues(deviceType).Inc()
+	uploadBytes.WithLabelValues(deviceType).Add(float64(size))
+}
+
+// RegisterCacheSize 注册各分类随机缓存和壁纸列表长度的指标，抓取时从 Redis 读取
+func RegisterCacheSize(rdb *redis.Client, deviceTypes []string) {
+	Registry.MustRegister(&cacheSizeCollector{rdb: rdb, deviceTypes: deviceTypes})
+}
+
+var (
+	cacheSizeDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "cache_size"),
+		"Wallpapers left in the random cache by category.", []string{"device_type"}, nil)
+	librarySizeDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "library_size"),
+		"Wallpapers in the library list by category.", []string{"device_type"}, nil)
+)
+
+type cacheSizeCollector struct {
+	rdb         *redis.Client
+	deviceTypes []string
+}
+
+func (c *cacheSizeCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- cacheSizeDesc
+	ch <- librarySizeDesc
+}
+
+func (c *cacheSizeCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	pipe := c.rdb.Pipeline()
+	caches := make([]*redis.IntCmd, len(c.deviceTypes))
+	libraries := make([]*redis.IntCmd, len(c.deviceTypes))
+	for i, deviceType := range c.deviceTypes {
+		caches[i] = pipe.LLen(ctx, "wallpaper:cache:"+deviceType)
+		libraries[i] = pipe.LLen(ctx, "wallpaper:"+deviceType)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		// Redis 不可用时不输出，由 redis_errors_total 体现
+		return
+	}
+	for i, deviceType := range c.deviceTypes {
+		ch <- prometheus.MustNewConstMetric(cacheSizeDesc, prometheus.GaugeValue, float64(caches[i].Val()), deviceType)
+		ch <- prometheus.MustNewConstMetric(librarySizeDesc, prometheus.GaugeValue, float64(libraries[i].Val()), deviceType)
+	}
+}
+
+// RedisHook go-redis 钩子，统计每条命令的耗时和错误（redis.Nil 不计为错误）
+type RedisHook struct{}
+
+type redisStartKey struct{}
+
+func (RedisHook) BeforeProcess(ctx context.Context, _ redis.Cmder) (context.Context, error) {
+	return context.WithValue(ctx, redisStartKey{}, time.Now()), nil
+}
+
+func (RedisHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	if start, ok := ctx.Value(redisStartKey{}).(time.Time); ok {
+		observeRedis(cmd, time.Since(start))
+	}
+	return nil
+}
+
+func (RedisHook) BeforeProcessPipeline(ctx context.Context, _ []redis.Cmder) (context.Context, error) {
+	return context.WithValue(ctx, redisStartKey{}, time.Now()), nil
+}
+
+func (RedisHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	start, ok := ctx.Value(redisStartKey{}).(time.Time)
+	if !ok {
+		return nil
+	}
+	elapsed := time.Since(start)
+	redisDuration.WithLabelValues("pipeline").Observe(elapsed.Seconds())
+	for _, cmd := range cmds {
+		if err := cmd.Err(); err != nil && !errors.Is(err, redis.Nil) {
+			redisErrors.WithLabelValues(cmd.Name()).Inc()
+		}
+	}
+	return nil
+}
+
+func observeRedis(cmd redis.Cmder, elapsed time.Duration) {
+	redisDuration.WithLabelValues(cmd.Name()).Observe(elapsed.Seconds())
+	if err := cmd.Err(); err != nil && !errors.Is(err, redis.Nil) {
+		redisErrors.WithLabelValues(cmd.Name()).Inc()
+	}
+}
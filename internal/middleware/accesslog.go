@@ -0,0 +1,185 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TXM983/wallpaper-api-v1/internal/logger"
+	"github.com/gin-gonic/gin"
+)
+
+// 访问日志格式
+const (
+	AccessLogCommon   = "common"   // Apache Common Log Format
+	AccessLogCombined = "combined" // Combined Log Format，末尾附加耗时、API Key ID、壁纸和请求 ID
+	AccessLogJSON     = "json"     // 每行一个 JSON 对象
+)
+
+// 上下文中保存本次返回的壁纸的键
+const contextWallpaper = "wallpaper"
+
+// Common/Combined 格式的时间格式
+const clfTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+// 查询参数中的凭据（API Key、OIDC 授权码和 state），写入日志前替换为 REDACTED
+var redactedQueryParams = map[string]bool{APIKeyQueryParam: true, "code": true, "state": true}
+
+// SetWallpaper 记录本次请求返回的壁纸，写入访问日志
+func SetWallpaper(c *gin.Context, deviceType string, filename string) {
+	c.Set(contextWallpaper, deviceType+"/"+filename)
+}
+
+// JSON 格式的访问日志
+type accessLogEntry struct {
+	Time      string  `json:"time"`
+	RequestID string  `json:"request_id,omitempty"`
+	ClientIP  string  `json:"client_ip"`
+	User      string  `json:"user,omitempty"`
+	Method    string  `json:"method"`
+	Path      string  `json:"path"`
+	Query     string  `json:"query,omitempty"`
+	Route     string  `json:"route,omitempty"`
+	Protocol  string  `json:"protocol"`
+	Status    int     `json:"status"`
+	Bytes     int     `json:"bytes"`
+	Latency   float64 `json:"latency_ms"`
+	Referer   string  `json:"referer,omitempty"`
+	UserAgent string  `json:"user_agent,omitempty"`
+	APIKeyID  string  `json:"api_key_id,omitempty"`
+	Wallpaper string  `json:"wallpaper,omitempty"`
+}
+
+// AccessLog 每个请求写一行访问日志到 w，skipPaths 中的路径（如 /health、/metrics）不记录
+// 需放在 RequestID 之后，以便记录请求 ID
+func AccessLog(w io.Writer, format string, loc *time.Location, skipPaths []string) (gin.HandlerFunc, error) {
+	switch format {
+	case AccessLogCommon, AccessLogCombined, AccessLogJSON:
+	default:
+		return nil, fmt.Errorf("unsupported access log format '%s'", format)
+	}
+	if loc == nil {
+		loc = time.Local
+	}
+	skip := make(map[string]bool, len(skipPaths))
+	for _, path := range skipPaths {
+		skip[path] = true
+	}
+
+	return func(c *gin.Context) {
+		start := time.Now()
+		path := c.Request.URL.Path
+		query := redactQuery(c.Request.URL.RawQuery)
+		c.Next()
+
+		if skip[path] {
+			return
+		}
+
+		entry := accessLogEntry{
+			Time:      start.In(loc).Format(time.RFC3339Nano),
+			RequestID: RequestIDFromContext(c),
+			ClientIP:  c.ClientIP(),
+			Method:    c.Request.Method,
+			Path:      path,
+			Query:     query,
+			Route:     c.FullPath(),
+			Protocol:  c.Request.Proto,
+			Status:    c.Writer.Status(),
+			Bytes:     c.Writer.Size(),
+			Latency:   float64(time.Since(start).Microseconds()) / 1000,
+			Referer:   c.Request.Referer(),
+			UserAgent: c.Request.UserAgent(),
+			Wallpaper: c.GetString(contextWallpaper),
+		}
+		if entry.Bytes < 0 {
+			entry.Bytes = 0
+		}
+		if principal := PrincipalFromContext(c); principal != nil {
+			entry.User = principal.DisplayName()
+		}
+		if key := APIKeyFromContext(c); key != nil {
+			entry.APIKeyID = key.ID
+		}
+
+		var line []byte
+		if format == AccessLogJSON {
+			data, err := json.Marshal(entry)
+			if err != nil {
+				logger.LogError("Failed to encode access log: %v", err)
+				return
+			}
+			line = append(data, '\n')
+		} else {
+			uri := c.Request.URL.EscapedPath()
+			if query != "" {
+				uri += "?" + query
+			}
+			line = []byte(formatCLF(entry, start.In(loc), format == AccessLogCombined, uri))
+		}
+		if _, err := w.Write(line); err != nil {
+			logger.LogError("Failed to write access log: %v", err)
+		}
+	}, nil
+}
+
+// 替换查询参数中凭据的值，保持其余参数的顺序和编码不变
+func redactQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+	params := strings.Split(rawQuery, "&")
+	for i, param := range params {
+		key, _, _ := strings.Cut(param, "=")
+		name, err := url.QueryUnescape(key)
+		if err != nil {
+			name = key
+		}
+		if redactedQueryParams[strings.ToLower(name)] {
+			params[i] = key + "=REDACTED"
+		}
+	}
+	return strings.Join(params, "&")
+}
+
+// 按 Common/Combined 格式输出一行：host ident user [time] "request" status bytes ["referer" "user-agent" 附加字段]
+func formatCLF(entry accessLogEntry, t time.Time, combined bool, uri string) string {
+	bytes := "-"
+	if entry.Bytes > 0 {
+		bytes = strconv.Itoa(entry.Bytes)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s - %s [%s] %s %d %s",
+		entry.ClientIP, clfToken(entry.User), t.Format(clfTimeFormat),
+		strconv.Quote(entry.Method+" "+uri+" "+entry.Protocol), entry.Status, bytes)
+	if combined {
+		fmt.Fprintf(&b, " %s %s %.3f %s %s %s",
+			clfQuote(entry.Referer), clfQuote(entry.UserAgent), entry.Latency,
+			clfToken(entry.APIKeyID), clfToken(entry.Wallpaper), clfToken(entry.RequestID))
+	}
+	b.WriteByte('\n')
+	return b.String()
+}
+
+// 带引号的字段，为空时输出 "-"，转义引号和控制字符避免日志注入
+func clfQuote(s string) string {
+	if s == "" {
+		return `"-"`
+	}
+	return strconv.Quote(s)
+}
+
+// 不带引号的字段，为空时输出 -，含空白或引号时加引号
+func clfToken(s string) string {
+	if s == "" {
+		return "-"
+	}
+	if strings.Contains(s, " ") || strconv.Quote(s) != `"`+s+`"` {
+		return strconv.Quote(s)
+	}
+	return s
+}
@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRedactQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"", ""},
+		{"type=pc&dataType=json", "type=pc&dataType=json"},
+		{"type=pc&api_key=wpk_secret", "type=pc&api_key=REDACTED"},
+		{"code=abc&state=xyz", "code=REDACTED&state=REDACTED"},
+		{"api%5Fkey=wpk_secret&type=pc", "api%5Fkey=REDACTED&type=pc"},
+		{"API_KEY=wpk_secret", "API_KEY=REDACTED"},
+		{"api_key", "api_key=REDACTED"},
+		{"api_key=a&api_key=b", "api_key=REDACTED&api_key=REDACTED"},
+		{"keycode=1&statement=2", "keycode=1&statement=2"},
+	}
+	for _, tt := range tests {
+		if got := redactQuery(tt.query); got != tt.want {
+			t.Errorf("redactQuery(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestAccessLogRedactsCredentials(t *testing.T) {
+	const target = "/auth/oidc/callback?code=secret-code&state=secret-state&api_key=wpk_secret&type=pc"
+
+	for _, format := range []string{AccessLogCommon, AccessLogCombined, AccessLogJSON} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			accessLog, err := AccessLog(&buf, format, time.UTC, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := gin.New()
+			r.Use(accessLog)
+			r.GET("/auth/oidc/callback", func(c *gin.Context) { c.Status(http.StatusOK) })
+			r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+
+			line := buf.String()
+			for _, secret := range []string{"secret-code", "secret-state", "wpk_secret"} {
+				if strings.Contains(line, secret) {
+					t.Errorf("access log contains %q: %s", secret, line)
+				}
+			}
+			want := "code=REDACTED&state=REDACTED&api_key=REDACTED&type=pc"
+			if format == AccessLogJSON {
+				var entry accessLogEntry
+				if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+					t.Fatal(err)
+				}
+				if entry.Query != want {
+					t.Errorf("query = %q, want %q", entry.Query, want)
+				}
+			} else if !strings.Contains(line, `"GET /auth/oidc/callback?`+want+` HTTP/1.1"`) {
+				t.Errorf("request line not redacted: %s", line)
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/TXM983/wallpaper-api-v1/internal/metrics"
+	"github.com/TXM983/wallpaper-api-v1/internal/service"
+	utils "github.com/TXM983/wallpaper-api-v1/internal/util"
+	"github.com/gin-gonic/gin"
+)
+
+// API Key 的请求头和查询参数名
+const (
+	APIKeyHeader     = "X-API-Key"
+	APIKeyQueryParam = "api_key"
+)
+
+// 上下文中保存认证结果的键
+const (
+	contextAPIKey = "apiKey"
+	contextRole   = "role"
+)
+
+// DefaultAPIKeyRateLimit 未单独配置的 API Key 的默认速率（每秒请求数）
+var DefaultAPIKeyRateLimit = 10
+
+// APIKeyFromContext 获取当前请求使用的 API Key，未使用时返回 nil
+func APIKeyFromContext(c *gin.Context) *service.APIKey {
+	if value, exists := c.Get(contextAPIKey); exists {
+		return value.(*service.APIKey)
+	}
+	return nil
+}
+
+// RoleFromContext 当前登录用户的角色，API Key 请求或未登录时为空
+func RoleFromContext(c *gin.Context) string {
+	return c.GetString(contextRole)
+}
+
+// HasPermission 当前请求的角色或 API Key 是否拥有权限
+func HasPermission(c *gin.Context, permission string) bool {
+	if role := RoleFromContext(c); role != "" && service.RoleHasPermission(role, permission) {
+		return true
+	}
+	if key := APIKeyFromContext(c); key != nil && key.HasPermission(permission) {
+		return true
+	}
+	return false
+}
+
+// 从请求头或查询参数中读取 API Key
+func rawAPIKey(c *gin.Context) string {
+	if key := strings.TrimSpace(c.GetHeader(APIKeyHeader)); key != "" {
+		return key
+	}
+	return strings.TrimSpace(c.Query(APIKeyQueryParam))
+}
+
+// 校验 API Key、权限范围、速率和每日配额，成功时写入上下文；失败时已写入响应并中止
+// 禁止网段和权限范围在限流和计入配额之前校验，被拒绝的请求不消耗配额；scope 为空时不校验权限范围
+func resolveAPIKey(c *gin.Context, keys *service.APIKeyService, raw string, scope string) bool {
+	policy := CurrentRateLimitPolicy()
+	if policy.Denied(c.ClientIP()) {
+		abortDenied(c)
+		return false
+	}
+
+	key, err := keys.Authenticate(context.Background(), raw)
+	if errors.Is(err, service.ErrAPIKeyInvalid) {
+		Log(c).WithError(err).Warn("API key authentication failed")
+		utils.ErrorResponse(c, 401, "invalid api key", "The API key is invalid or has been revoked.")
+		c.Abort()
+		return false
+	}
+	if err != nil {
+		// Redis 不可用或超时，不能当作无效的 Key
+		Log(c).WithError(err).Error("Error authenticating API key")
+		utils.ErrorResponse(c, 503, "api key error", "Failed to verify the API key, please retry later.")
+		c.Abort()
+		return false
+	}
+	if scope != "" && !key.HasScope(scope) {
+		Log(c).WithField("api_key_id", key.ID).Warnf("API key lacks the %s scope", scope)
+		utils.ErrorResponse(c, 403, "forbidden", fmt.Sprintf("The API key does not have the '%s' scope.", scope))
+		c.Abort()
+		return false
+	}
+
+	// 按 Key 限流（允许网段内不限流）
+	if !policy.Bypassed(c.ClientIP()) {
+		rule := policy.RuleForAPIKey(key.ID, key.RateLimit)
+		result, err := RateLimiter.Allow(context.Background(), "apikey:"+key.ID, rule)
+		if err != nil {
+			// 限流存储不可用时放行
+			Log(c).WithError(err).WithField("api_key_id", key.ID).Error("Rate limiter error")
+		} else {
+			setRateLimitHeaders(c, result)
+			if !result.Allowed {
+				metrics.ObserveRateLimitRejection("apikey", result.Banned)
+				Log(c).WithField("api_key_id", key.ID).Warnf("Rate limit exceeded (Rate: %d/s)", rule.Rate)
+				abortRateLimited(c, result, fmt.Sprintf("Rate limit of %d requests per second exceeded for this API key.", rule.Rate))
+				return false
+			}
+		}
+	}
+
+	// 每日配额
+	used, allowed, err := keys.ConsumeQuota(context.Background(), key)
+	if err != nil {
+		Log(c).WithError(err).WithField("api_key_id", key.ID).Error("Error consuming quota")
+		utils.ErrorResponse(c, 500, "quota error", "Failed to check API key quota.")
+		c.Abort()
+		return false
+	}
+	if !allowed {
+		Log(c).WithField("api_key_id", key.ID).Warnf("Daily quota exceeded (%d/%d)", used, key.DailyQuota)
+		utils.ErrorResponse(c, 429, "quota exceeded", fmt.Sprintf("Daily quota of %d requests exceeded for this API key.", key.DailyQuota))
+		c.Abort()
+		return false
+	}
+
+	c.Set(contextAPIKey, key)
+	c.Set(contextPrincipal, &service.Principal{Subject: "apikey:" + key.ID, Name: key.Name, Method: service.AuthMethodAPIKey})
+	return true
+}
+
+// APIKeyAuth 公开接口的 API Key 中间件
+// 携带 Key 时校验权限、按 Key 限流并计入配额；required 为 false 时允许匿名访问
+func APIKeyAuth(keys *service.APIKeyService, scope string, required bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		raw := rawAPIKey(c)
+		if raw == "" {
+			if required {
+				utils.ErrorResponse(c, 401, "api key required", fmt.Sprintf("An API key is required. Pass it via the %s header or the %s query parameter.", APIKeyHeader, APIKeyQueryParam))
+				c.Abort()
+				return
+			}
+			c.Next()
+			return
+		}
+
+		if !resolveAPIKey(c, keys, raw, scope) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// PermissionDenied 权限不足被拒绝后的回调（写出 403 之后调用），用于记录审计日志，可为空
+var PermissionDenied func(c *gin.Context, permissions []string)
+
+// RequirePermission 校验角色或 API Key 是否拥有任一权限，需放在 AdminAuth 之后
+func RequirePermission(permissions ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, permission := range permissions {
+			if HasPermission(c, permission) {
+				c.Next()
+				return
+			}
+		}
+		utils.ErrorResponse(c, 403, "forbidden", fmt.Sprintf("The '%s' permission is required for this operation.", permissions[0]))
+		c.Abort()
+		if PermissionDenied != nil {
+			PermissionDenied(c, permissions)
+		}
+	}
+}
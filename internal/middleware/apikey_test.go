@@ -0,0 +1,164 @@
+package middleware
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/TXM983/wallpaper-api-v1/internal/service"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func newTestRedis(t *testing.T) *redis.Client {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	return rdb
+}
+
+// 替换限流策略，测试结束后恢复
+func withPolicy(t *testing.T, policy *RateLimitPolicy) {
+	t.Helper()
+	previous := currentRateLimitPolicy.Load()
+	SetRateLimitPolicy(policy)
+	t.Cleanup(func() { currentRateLimitPolicy.Store(previous) })
+}
+
+func TestAPIKeyAuthRejectsBeforeConsumingQuota(t *testing.T) {
+	rdb := newTestRedis(t)
+	keys := service.NewAPIKeyService(rdb)
+	ctx := context.Background()
+
+	uploadKey, uploadMeta, err := keys.Create(ctx, service.APIKey{Name: "upload", Scopes: []string{service.ScopeUpload}, DailyQuota: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	readKey, readMeta, err := keys.Create(ctx, service.APIKey{Name: "read", Scopes: []string{service.ScopeRead}, DailyQuota: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, denied, _ := net.ParseCIDR("203.0.113.0/24")
+	withPolicy(t, &RateLimitPolicy{Groups: defaultRateLimitPolicy.Groups, Deny: []*net.IPNet{denied}})
+
+	r := gin.New()
+	r.GET("/wallpaper", APIKeyAuth(keys, service.ScopeRead, false), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	tests := []struct {
+		name       string
+		key        string
+		remoteAddr string
+		wantStatus int
+	}{
+		{"missing scope", uploadKey, "192.0.2.1:1234", http.StatusForbidden},
+		{"denied network", readKey, "203.0.113.7:1234", http.StatusForbidden},
+		{"invalid key", "wpk_invalid", "192.0.2.1:1234", http.StatusUnauthorized},
+		{"allowed", readKey, "192.0.2.1:1234", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < 3; i++ {
+				w := httptest.NewRecorder()
+				req := httptest.NewRequest(http.MethodGet, "/wallpaper", nil)
+				req.RemoteAddr = tt.remoteAddr
+				req.Header.Set(APIKeyHeader, tt.key)
+				r.ServeHTTP(w, req)
+				if w.Code != tt.wantStatus {
+					t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+				}
+			}
+		})
+	}
+
+	// 只有通过校验的 3 次请求计入配额
+	today := time.Now().UTC().Format("20060102")
+	if used, _ := rdb.Get(ctx, "apikey:quota:"+uploadMeta.ID+":"+today).Int(); used != 0 {
+		t.Errorf("upload key quota used = %d, want 0", used)
+	}
+	if used, _ := rdb.Get(ctx, "apikey:quota:"+readMeta.ID+":"+today).Int(); used != 3 {
+		t.Errorf("read key quota used = %d, want 3", used)
+	}
+}
+
+func TestAPIKeyAuthStoreErrors(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr(), MaxRetries: -1})
+	t.Cleanup(func() { rdb.Close() })
+	keys := service.NewAPIKeyService(rdb)
+	ctx := context.Background()
+
+	revokedKey, revokedMeta, err := keys.Create(ctx, service.APIKey{Name: "revoked", Scopes: []string{service.ScopeRead}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := keys.Revoke(ctx, revokedMeta.ID); err != nil {
+		t.Fatal(err)
+	}
+	readKey, _, err := keys.Create(ctx, service.APIKey{Name: "read", Scopes: []string{service.ScopeRead}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	withPolicy(t, &RateLimitPolicy{Groups: defaultRateLimitPolicy.Groups})
+
+	r := gin.New()
+	r.GET("/wallpaper", APIKeyAuth(keys, service.ScopeRead, false), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	request := func(key string) int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/wallpaper", nil)
+		req.Header.Set(APIKeyHeader, key)
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := request(revokedKey); code != http.StatusUnauthorized {
+		t.Errorf("revoked key status = %d, want %d", code, http.StatusUnauthorized)
+	}
+
+	// Redis 不可用时不能把有效的 Key 当作无效
+	mr.Close()
+	if code := request(readKey); code != http.StatusServiceUnavailable {
+		t.Errorf("store outage status = %d, want %d", code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestRequirePermissionReportsDenied(t *testing.T) {
+	var denied []string
+	PermissionDenied = func(c *gin.Context, permissions []string) {
+		if c.Writer.Status() != http.StatusForbidden {
+			t.Errorf("status when reporting = %d, want %d", c.Writer.Status(), http.StatusForbidden)
+		}
+		denied = permissions
+	}
+	t.Cleanup(func() { PermissionDenied = nil })
+
+	r := gin.New()
+	r.DELETE("/admin/wallpapers", func(c *gin.Context) {
+		c.Set(contextRole, service.RoleViewer)
+	}, RequirePermission(service.PermWallpaperDeleteAny, service.PermWallpaperDeleteOwn), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/admin/wallpapers", nil))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+	if want := []string{service.PermWallpaperDeleteAny, service.PermWallpaperDeleteOwn}; !reflect.DeepEqual(denied, want) {
+		t.Errorf("denied permissions = %v, want %v", denied, want)
+	}
+}
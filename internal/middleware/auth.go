@@ -0,0 +1,176 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"strings"
+
+	"github.com/TXM983/wallpaper-api-v1/internal/logger"
+	"github.com/TXM983/wallpaper-api-v1/internal/service"
+	utils "github.com/TXM983/wallpaper-api-v1/internal/util"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// AdminPasswordHeader 仅携带密码的请求头（管理页面使用）
+const AdminPasswordHeader = "X-Password"
+
+// AdminAuthenticator 管理员凭据：bcrypt 密码哈希和 API Token 的 SHA-256 摘要
+type AdminAuthenticator struct {
+	username     string
+	passwordHash []byte
+	tokenHashes  [][]byte
+}
+
+// NewAdminAuthenticator 创建管理员认证器
+// passwordHash 为 bcrypt 哈希，tokenHashes 为 API Token 的 SHA-256 十六进制摘要
+func NewAdminAuthenticator(username string, passwordHash string, tokenHashes []string) *AdminAuthenticator {
+	a := &AdminAuthenticator{
+		username:     username,
+		passwordHash: []byte(passwordHash),
+	}
+	for _, h := range tokenHashes {
+		h = strings.ToLower(strings.TrimSpace(h))
+		if decoded, err := hex.DecodeString(h); err == nil && len(decoded) == sha256.Size {
+			a.tokenHashes = append(a.tokenHashes, decoded)
+		} else if h != "" {
+			logger.LogError("Ignoring invalid admin token hash: %s", h)
+		}
+	}
+	return a
+}
+
+// HashAdminPassword 生成 bcrypt 密码哈希
+func HashAdminPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// Enabled 是否配置了任何管理员凭据
+func (a *AdminAuthenticator) Enabled() bool {
+	return len(a.passwordHash) > 0 || len(a.tokenHashes) > 0
+}
+
+// VerifyPassword 校验用户名和密码（Basic 认证），用户名必须匹配
+func (a *AdminAuthenticator) VerifyPassword(username string, password string) bool {
+	if subtle.ConstantTimeCompare([]byte(username), []byte(a.username)) != 1 {
+		return false
+	}
+	return a.VerifyPasswordOnly(password)
+}
+
+// VerifyPasswordOnly 只校验密码，用于管理页面表单和 X-Admin-Password 请求头
+func (a *AdminAuthenticator) VerifyPasswordOnly(password string) bool {
+	if len(a.passwordHash) == 0 || password == "" {
+		return false
+	}
+	return bcrypt.CompareHashAndPassword(a.passwordHash, []byte(password)) == nil
+}
+
+// VerifyToken 校验 API Token
+func (a *AdminAuthenticator) VerifyToken(token string) bool {
+	if token == "" {
+		return false
+	}
+	sum := sha256.Sum256([]byte(token))
+	matched := 0
+	// 遍历全部摘要，耗时与命中位置无关
+	for _, h := range a.tokenHashes {
+		matched |= subtle.ConstantTimeCompare(sum[:], h)
+	}
+	return matched == 1
+}
+
+// Username 管理员用户名
+func (a *AdminAuthenticator) Username() string {
+	return a.username
+}
+
+// IsConfiguredAdmin 是否为配置文件中的管理员账号
+func (a *AdminAuthenticator) IsConfiguredAdmin(principal *service.Principal) bool {
+	return len(a.passwordHash) > 0 && principal.Method == service.AuthMethodPassword && principal.Subject == a.username
+}
+
+// 查询登录用户的角色，配置文件中的管理员固定为 admin
+func resolveRole(auth *AdminAuthenticator, users *service.UserService, principal *service.Principal) (string, error) {
+	if auth.IsConfiguredAdmin(principal) {
+		return service.RoleAdmin, nil
+	}
+	return users.ResolveRole(context.Background(), principal)
+}
+
+// AdminAuth 管理接口认证中间件
+// 支持登录会话 Cookie、Authorization: Bearer <token>、Authorization: Basic <user:pass>、X-Password 请求头，
+// 以及 API Key；管理员凭据拥有 admin 角色，登录用户按用户表中的角色授权（由 RequirePermission 校验）
+func AdminAuth(auth *AdminAuthenticator, keys *service.APIKeyService, sessions *service.SessionManager, users *service.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if raw := rawAPIKey(c); raw != "" {
+			// 管理接口的权限由 RequirePermission 按路由校验
+			if resolveAPIKey(c, keys, raw, "") {
+				c.Next()
+			}
+			return
+		}
+
+		if resolveSession(c, sessions) {
+			role, err := resolveRole(auth, users, PrincipalFromContext(c))
+			if err != nil {
+				Log(c).WithError(err).Errorf("Error resolving role for %s", PrincipalFromContext(c).Subject)
+				utils.ErrorResponse(c, 500, "server error", "Failed to resolve user role.")
+				c.Abort()
+				return
+			}
+			if role == "" {
+				utils.ErrorResponse(c, 403, "forbidden", "Your account has not been granted any role.")
+				c.Abort()
+				return
+			}
+			c.Set(contextRole, role)
+			c.Next()
+			return
+		}
+
+		if !auth.Enabled() {
+			utils.ErrorResponse(c, 403, "admin disabled", "Administrative access is disabled. Configure admin credentials to enable it.")
+			c.Abort()
+			return
+		}
+
+		authorization := c.GetHeader("Authorization")
+		password := c.GetHeader(AdminPasswordHeader)
+
+		var ok bool
+		principal := &service.Principal{Subject: auth.username, Name: auth.username, Method: service.AuthMethodPassword}
+		switch {
+		case strings.HasPrefix(authorization, "Bearer "):
+			ok = auth.VerifyToken(strings.TrimPrefix(authorization, "Bearer "))
+			principal = &service.Principal{Subject: "token", Name: "admin token", Method: service.AuthMethodToken}
+		case strings.HasPrefix(authorization, "Basic "):
+			username, pass, hasBasic := c.Request.BasicAuth()
+			ok = hasBasic && auth.VerifyPassword(username, pass)
+		case password != "":
+			ok = auth.VerifyPasswordOnly(password)
+		default:
+			c.Header("WWW-Authenticate", `Basic realm="wallpaper-api admin"`)
+			utils.ErrorResponse(c, 401, "unauthorized", "Authentication required.")
+			c.Abort()
+			return
+		}
+
+		if !ok {
+			Log(c).Warnf("Admin authentication failed, path: %s", c.Request.URL.Path)
+			utils.ErrorResponse(c, 401, "unauthorized", "Authentication failed. Invalid credentials.")
+			c.Abort()
+			return
+		}
+
+		c.Set(contextRole, service.RoleAdmin)
+		c.Set(contextPrincipal, principal)
+		c.Next()
+	}
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestAdminAuthPassword(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := NewAdminAuthenticator("admin", string(hash), nil)
+
+	r := gin.New()
+	r.GET("/admin", AdminAuth(auth, nil, nil, nil), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	basic := func(credentials string) string {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(credentials))
+	}
+	tests := []struct {
+		name       string
+		header     string
+		value      string
+		wantStatus int
+	}{
+		{"basic", "Authorization", basic("admin:secret"), http.StatusOK},
+		{"basic empty username", "Authorization", basic(":secret"), http.StatusUnauthorized},
+		{"basic wrong username", "Authorization", basic("root:secret"), http.StatusUnauthorized},
+		{"basic wrong password", "Authorization", basic("admin:wrong"), http.StatusUnauthorized},
+		{"password header", AdminPasswordHeader, "secret", http.StatusOK},
+		{"wrong password header", AdminPasswordHeader, "wrong", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+			req.Header.Set(tt.header, tt.value)
+			r.ServeHTTP(w, req)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/TXM983/wallpaper-api-v1/internal/service"
+	utils "github.com/TXM983/wallpaper-api-v1/internal/util"
+	"github.com/gin-gonic/gin"
+)
+
+// Hotlink 防盗链中间件，需放在 APIKeyAuth 之后（按 Key 的域名列表校验）
+// 不允许的来源：fallbackImage 非空且请求默认的 302 跳转时跳转到兜底图片，否则返回 403
+func Hotlink(policy *service.HotlinkPolicy, fallbackImage string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !policy.Enabled() {
+			c.Next()
+			return
+		}
+		c.Writer.Header().Add("Vary", "Referer, Origin")
+
+		category := c.Query("type")
+		if service.IsAutoDeviceType(category) {
+			category = service.DetectDeviceType(c.Request.Header)
+		}
+
+		allowed, host := policy.Allow(c.Request.Header, category, APIKeyFromContext(c), requestHostname(c.Request))
+		if allowed {
+			c.Next()
+			return
+		}
+
+		Log(c).Warnf("Hotlink blocked for referer host: %s, path: %s", host, c.Request.URL.Path)
+
+		if fallbackImage != "" && c.Query("dataType") == "" {
+			c.Header("Cache-Control", "no-store")
+			c.Redirect(http.StatusFound, fallbackImage)
+			c.Abort()
+			return
+		}
+		utils.ErrorResponse(c, 403, "hotlink forbidden", fmt.Sprintf("Embedding wallpapers from '%s' is not allowed.", host))
+		c.Abort()
+	}
+}
+
+// 本站域名（同源页面不受允许列表限制，但仍受禁止列表约束）
+func requestHostname(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		host = r.Host
+	}
+	return strings.ToLower(host)
+}
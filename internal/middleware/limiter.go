@@ -0,0 +1,109 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+)
+
+// 限流驱动
+const (
+	LimiterDriverMemory = "memory" // 进程内限流，多实例时各自计数
+	LimiterDriverRedis  = "redis"  // Redis 集中限流（GCRA），多实例共享配额和封禁
+)
+
+// DefaultBanDuration 超出限流后的默认封禁时长
+const DefaultBanDuration = 2 * time.Minute
+
+// RateLimitRule 限流规则
+type RateLimitRule struct {
+	Rate  int           // 每秒请求数
+	Burst int           // 突发请求数，0 表示等于 Rate
+	Ban   time.Duration // 超出后的封禁时长，0 表示不封禁
+}
+
+// 突发请求数，未配置时等于每秒请求数
+func (r RateLimitRule) burst() int {
+	if r.Burst > 0 {
+		return r.Burst
+	}
+	if r.Rate < 1 {
+		return 1
+	}
+	return r.Rate
+}
+
+// LimitResult 一次限流判断的结果
+type LimitResult struct {
+	Allowed    bool
+	Limit      int           // 突发请求数（窗口内最多可用的请求数）
+	Remaining  int           // 当前剩余可用请求数
+	ResetAfter time.Duration // 配额完全恢复所需时间
+	RetryAfter time.Duration // 被拒绝时距离下次允许请求的时间（封禁时为剩余封禁时长）
+	Banned     bool          // 是否处于封禁中
+}
+
+// Limiter 限流驱动，key 为限流对象（IP 或 API Key）
+type Limiter interface {
+	Allow(ctx context.Context, key string, rule RateLimitRule) (LimitResult, error)
+	// Driver 驱动名称（memory、redis），启动时确定，重新加载策略不会切换
+	Driver() string
+}
+
+// RateLimiter 当前使用的限流驱动，默认进程内限流
+var RateLimiter Limiter = NewMemoryLimiter()
+
+// 进程内限流驱动，基于 golang.org/x/time/rate
+type memoryLimiter struct{}
+
+// NewMemoryLimiter 创建进程内限流驱动
+func NewMemoryLimiter() Limiter {
+	return memoryLimiter{}
+}
+
+func (memoryLimiter) Driver() string {
+	return LimiterDriverMemory
+}
+
+func (memoryLimiter) Allow(_ context.Context, key string, rule RateLimitRule) (LimitResult, error) {
+	perSecond, ban := rule.Rate, rule.Ban
+	if perSecond <= 0 {
+		return LimitResult{}, fmt.Errorf("invalid rate limit %d", perSecond)
+	}
+
+	lk := limiterKey{IP: key, Rate: perSecond, Burst: rule.burst()}
+	now := time.Now()
+	result := LimitResult{Limit: lk.Burst}
+
+	// 检查封禁状态
+	if blockedUntil, exists := ipBlockedUntil.Load(lk); exists {
+		if until := blockedUntil.(time.Time); now.Before(until) {
+			result.Banned = true
+			result.RetryAfter = until.Sub(now)
+			result.ResetAfter = result.RetryAfter
+			return result, nil
+		}
+		ipBlockedUntil.Delete(lk)
+	}
+
+	limiter := getLimiter(lk)
+	ipLastAccess.Store(lk, now)
+
+	result.Allowed = limiter.AllowN(now, 1)
+	tokens := limiter.TokensAt(now)
+	result.Remaining = int(math.Max(0, math.Floor(tokens)))
+	result.ResetAfter = time.Duration((float64(limiter.Burst()) - tokens) / float64(perSecond) * float64(time.Second))
+
+	if !result.Allowed {
+		if ban > 0 {
+			ipBlockedUntil.Store(lk, now.Add(ban))
+			result.Banned = true
+			result.RetryAfter = ban
+			result.ResetAfter = ban
+		} else {
+			result.RetryAfter = time.Duration((1 - tokens) / float64(perSecond) * float64(time.Second))
+		}
+	}
+	return result, nil
+}
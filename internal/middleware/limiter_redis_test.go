@@ -0,0 +1,118 @@
+package middleware
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+func TestRedisLimiterGCRA(t *testing.T) {
+	// 每秒 10 次：每个请求间隔 100ms
+	const interval = 100 * time.Millisecond
+
+	type step struct {
+		advance time.Duration // 请求前推进的服务器时间
+		want    LimitResult
+	}
+	tests := []struct {
+		name  string
+		rule  RateLimitRule
+		steps []step
+	}{
+		{
+			name: "burst then reject",
+			rule: RateLimitRule{Rate: 10, Burst: 3},
+			steps: []step{
+				{want: LimitResult{Allowed: true, Limit: 3, Remaining: 2, ResetAfter: interval}},
+				{want: LimitResult{Allowed: true, Limit: 3, Remaining: 1, ResetAfter: 2 * interval}},
+				{want: LimitResult{Allowed: true, Limit: 3, Remaining: 0, ResetAfter: 3 * interval}},
+				{want: LimitResult{Limit: 3, ResetAfter: 3 * interval, RetryAfter: interval}},
+				{advance: 40 * time.Millisecond, want: LimitResult{Limit: 3, ResetAfter: 260 * time.Millisecond, RetryAfter: 60 * time.Millisecond}},
+				// 一个间隔后恢复一个请求
+				{advance: 60 * time.Millisecond, want: LimitResult{Allowed: true, Limit: 3, Remaining: 0, ResetAfter: 3 * interval}},
+			},
+		},
+		{
+			name: "burst defaults to rate",
+			rule: RateLimitRule{Rate: 2},
+			steps: []step{
+				{want: LimitResult{Allowed: true, Limit: 2, Remaining: 1, ResetAfter: 500 * time.Millisecond}},
+				{want: LimitResult{Allowed: true, Limit: 2, Remaining: 0, ResetAfter: time.Second}},
+				{want: LimitResult{Limit: 2, ResetAfter: time.Second, RetryAfter: 500 * time.Millisecond}},
+			},
+		},
+		{
+			name: "quota recovers after idle",
+			rule: RateLimitRule{Rate: 10, Burst: 2},
+			steps: []step{
+				{want: LimitResult{Allowed: true, Limit: 2, Remaining: 1, ResetAfter: interval}},
+				{want: LimitResult{Allowed: true, Limit: 2, Remaining: 0, ResetAfter: 2 * interval}},
+				{advance: time.Minute, want: LimitResult{Allowed: true, Limit: 2, Remaining: 1, ResetAfter: interval}},
+			},
+		},
+		{
+			name: "ban after exceeding",
+			rule: RateLimitRule{Rate: 10, Burst: 1, Ban: 2 * time.Second},
+			steps: []step{
+				{want: LimitResult{Allowed: true, Limit: 1, Remaining: 0, ResetAfter: interval}},
+				{want: LimitResult{Limit: 1, ResetAfter: 2 * time.Second, RetryAfter: 2 * time.Second, Banned: true}},
+				// 封禁期间即使配额已恢复也拒绝
+				{advance: time.Second, want: LimitResult{Limit: 1, ResetAfter: time.Second, RetryAfter: time.Second, Banned: true}},
+				{advance: time.Second, want: LimitResult{Allowed: true, Limit: 1, Remaining: 0, ResetAfter: interval}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mr := miniredis.RunT(t)
+			rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+			t.Cleanup(func() { rdb.Close() })
+			limiter := NewRedisLimiter(rdb)
+
+			now := time.Unix(1700000000, 0)
+			mr.SetTime(now)
+			for i, s := range tt.steps {
+				if s.advance > 0 {
+					now = now.Add(s.advance)
+					mr.SetTime(now)
+					mr.FastForward(s.advance) // 封禁 Key 的 TTL
+				}
+				got, err := limiter.Allow(context.Background(), "192.0.2.1", tt.rule)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got != s.want {
+					t.Errorf("step %d: got %+v, want %+v", i, got, s.want)
+				}
+			}
+		})
+	}
+}
+
+func TestRedisLimiterSeparatesKeysAndRules(t *testing.T) {
+	rdb := newTestRedis(t)
+	limiter := NewRedisLimiter(rdb)
+	ctx := context.Background()
+	rule := RateLimitRule{Rate: 1}
+
+	if got, _ := limiter.Allow(ctx, "a", rule); !got.Allowed {
+		t.Fatal("first request for a rejected")
+	}
+	if got, _ := limiter.Allow(ctx, "a", rule); got.Allowed {
+		t.Fatal("second request for a allowed")
+	}
+	if got, _ := limiter.Allow(ctx, "b", rule); !got.Allowed {
+		t.Error("key b shares the quota of a")
+	}
+	// 规则变化后使用新的计数
+	if got, _ := limiter.Allow(ctx, "a", RateLimitRule{Rate: 5}); !got.Allowed {
+		t.Error("new rule shares the quota of the old rule")
+	}
+	if _, err := limiter.Allow(ctx, "a", RateLimitRule{}); err == nil {
+		t.Error("zero rate accepted")
+	}
+}
@@ -0,0 +1,96 @@
+package middleware
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRateLimitRuleBurst(t *testing.T) {
+	tests := []struct {
+		rule RateLimitRule
+		want int
+	}{
+		{RateLimitRule{Rate: 5}, 5},
+		{RateLimitRule{Rate: 5, Burst: 10}, 10},
+		{RateLimitRule{Rate: 0}, 1},
+		{RateLimitRule{Rate: -1, Burst: 0}, 1},
+	}
+	for _, tt := range tests {
+		if got := tt.rule.burst(); got != tt.want {
+			t.Errorf("%+v.burst() = %d, want %d", tt.rule, got, tt.want)
+		}
+	}
+}
+
+func TestMemoryLimiterAllow(t *testing.T) {
+	tests := []struct {
+		name string
+		rule RateLimitRule
+		want []LimitResult // 连续请求的结果，忽略时间相关字段
+	}{
+		{
+			name: "burst then reject",
+			rule: RateLimitRule{Rate: 1, Burst: 3},
+			want: []LimitResult{
+				{Allowed: true, Limit: 3, Remaining: 2},
+				{Allowed: true, Limit: 3, Remaining: 1},
+				{Allowed: true, Limit: 3, Remaining: 0},
+				{Limit: 3},
+			},
+		},
+		{
+			name: "ban after exceeding",
+			rule: RateLimitRule{Rate: 1, Ban: time.Minute},
+			want: []LimitResult{
+				{Allowed: true, Limit: 1},
+				{Limit: 1, Banned: true},
+				{Limit: 1, Banned: true},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := "memory-test:" + t.Name()
+			t.Cleanup(func() { cleanupMemoryLimiter(key, tt.rule) })
+
+			limiter := NewMemoryLimiter()
+			for i, want := range tt.want {
+				got, err := limiter.Allow(context.Background(), key, tt.rule)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got.Allowed != want.Allowed || got.Limit != want.Limit || got.Remaining != want.Remaining || got.Banned != want.Banned {
+					t.Errorf("request %d: got %+v, want %+v", i, got, want)
+				}
+				switch {
+				case got.Banned:
+					if got.RetryAfter <= 0 || got.RetryAfter > tt.rule.Ban {
+						t.Errorf("request %d: retry after %v, want (0, %v]", i, got.RetryAfter, tt.rule.Ban)
+					}
+				case !got.Allowed:
+					if got.RetryAfter <= 0 || got.RetryAfter > time.Second {
+						t.Errorf("request %d: retry after %v, want (0, 1s]", i, got.RetryAfter)
+					}
+				case got.ResetAfter <= 0:
+					t.Errorf("request %d: reset after %v, want > 0", i, got.ResetAfter)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryLimiterInvalidRate(t *testing.T) {
+	if _, err := NewMemoryLimiter().Allow(context.Background(), "memory-test:invalid", RateLimitRule{}); err == nil {
+		t.Error("zero rate accepted")
+	}
+}
+
+// 清理进程内限流器中测试使用的 Key
+func cleanupMemoryLimiter(key string, rule RateLimitRule) {
+	lk := limiterKey{IP: key, Rate: rule.Rate, Burst: rule.burst()}
+	ipLimiters.Delete(lk)
+	ipLastAccess.Delete(lk)
+	ipBlockedUntil.Delete(lk)
+}
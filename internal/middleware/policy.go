@@ -0,0 +1,156 @@
+package middleware
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync/atomic"
+)
+
+// 路由分组名
+const (
+	RateLimitGroupWallpaper    = "wallpaper"     // /wallpaper
+	RateLimitGroupSelectImages = "select_images" // /selectImages
+	RateLimitGroupAuth         = "auth"          // /auth
+	RateLimitGroupAdmin        = "admin"         // /admin
+	RateLimitGroupHealth       = "health"        // /readyz
+	RateLimitGroupDefault      = "default"       // 未单独配置的分组
+)
+
+// CIDRRule 网段限流规则
+type CIDRRule struct {
+	Network *net.IPNet
+	Rule    RateLimitRule
+}
+
+// RateLimitPolicy 限流策略：分组规则、API Key 规则、网段规则以及允许/禁止网段
+type RateLimitPolicy struct {
+	Groups  map[string]RateLimitRule
+	APIKeys map[string]RateLimitRule // API Key ID -> 规则，优先于 Key 自身的 rateLimit
+	CIDRs   []CIDRRule               // 按顺序匹配，优先于分组规则
+	Allow   []*net.IPNet             // 不限流的网段
+	Deny    []*net.IPNet             // 直接拒绝的网段
+}
+
+// 默认策略：与原硬编码配置一致
+var defaultRateLimitPolicy = &RateLimitPolicy{
+	Groups: map[string]RateLimitRule{
+		RateLimitGroupWallpaper:    {Rate: 5, Ban: DefaultBanDuration},
+		RateLimitGroupSelectImages: {Rate: 2, Ban: DefaultBanDuration},
+		RateLimitGroupAuth:         {Rate: 2, Ban: DefaultBanDuration},
+		RateLimitGroupAdmin:        {Rate: 2, Ban: DefaultBanDuration},
+		RateLimitGroupHealth:       {Rate: 10},
+		RateLimitGroupDefault:      {Rate: 2, Ban: DefaultBanDuration},
+	},
+}
+
+var currentRateLimitPolicy atomic.Pointer[RateLimitPolicy]
+
+// CurrentRateLimitPolicy 当前生效的限流策略
+func CurrentRateLimitPolicy() *RateLimitPolicy {
+	if policy := currentRateLimitPolicy.Load(); policy != nil {
+		return policy
+	}
+	return defaultRateLimitPolicy
+}
+
+// SetRateLimitPolicy 替换限流策略，对之后的请求立即生效
+func SetRateLimitPolicy(policy *RateLimitPolicy) {
+	currentRateLimitPolicy.Store(policy)
+}
+
+// ParseCIDRs 解析网段列表，单个 IP 视为 /32 或 /128
+func ParseCIDRs(values []string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, value := range values {
+		network, err := parseCIDR(value)
+		if err != nil {
+			return nil, err
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+func parseCIDR(value string) (*net.IPNet, error) {
+	value = strings.TrimSpace(value)
+	if !strings.Contains(value, "/") {
+		ip := net.ParseIP(value)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid ip '%s'", value)
+		}
+		bits := 128
+		if ip.To4() != nil {
+			ip, bits = ip.To4(), 32
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	_, network, err := net.ParseCIDR(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cidr '%s': %v", value, err)
+	}
+	return network, nil
+}
+
+// NewCIDRRule 创建网段限流规则
+func NewCIDRRule(cidr string, rule RateLimitRule) (CIDRRule, error) {
+	network, err := parseCIDR(cidr)
+	if err != nil {
+		return CIDRRule{}, err
+	}
+	return CIDRRule{Network: network, Rule: rule}, nil
+}
+
+func containsIP(networks []*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// IPInNetworks IP 是否在任一网段中，IP 无效时返回 false
+func IPInNetworks(ip string, networks []*net.IPNet) bool {
+	parsed := net.ParseIP(ip)
+	return parsed != nil && containsIP(networks, parsed)
+}
+
+// Denied IP 是否在禁止网段中
+func (p *RateLimitPolicy) Denied(ip string) bool {
+	return IPInNetworks(ip, p.Deny)
+}
+
+// Bypassed IP 是否在不限流的网段中
+func (p *RateLimitPolicy) Bypassed(ip string) bool {
+	return IPInNetworks(ip, p.Allow)
+}
+
+// RuleForIP 按 IP 限流的规则：匹配的网段规则 > 分组规则 > default 分组
+func (p *RateLimitPolicy) RuleForIP(group string, ip string) RateLimitRule {
+	if parsed := net.ParseIP(ip); parsed != nil {
+		for _, cidr := range p.CIDRs {
+			if cidr.Network.Contains(parsed) {
+				return cidr.Rule
+			}
+		}
+	}
+	if rule, ok := p.Groups[group]; ok {
+		return rule
+	}
+	if rule, ok := p.Groups[RateLimitGroupDefault]; ok {
+		return rule
+	}
+	return defaultRateLimitPolicy.Groups[RateLimitGroupDefault]
+}
+
+// RuleForAPIKey 按 API Key 限流的规则：策略中的 Key 规则 > Key 自身的 rateLimit > 默认速率，不封禁
+func (p *RateLimitPolicy) RuleForAPIKey(id string, keyRate int) RateLimitRule {
+	if rule, ok := p.APIKeys[id]; ok {
+		return rule
+	}
+	if keyRate <= 0 {
+		keyRate = DefaultAPIKeyRateLimit
+	}
+	return RateLimitRule{Rate: keyRate}
+}
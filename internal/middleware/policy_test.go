@@ -0,0 +1,146 @@
+package middleware
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func mustCIDRs(t *testing.T, values ...string) []*net.IPNet {
+	t.Helper()
+	networks, err := ParseCIDRs(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return networks
+}
+
+func mustCIDRRule(t *testing.T, cidr string, rule RateLimitRule) CIDRRule {
+	t.Helper()
+	r, err := NewCIDRRule(cidr, rule)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestParseCIDRs(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{value: "10.0.0.0/8", want: "10.0.0.0/8"},
+		{value: " 192.168.1.7/24 ", want: "192.168.1.0/24"},
+		{value: "203.0.113.5", want: "203.0.113.5/32"},
+		{value: "2001:db8::1", want: "2001:db8::1/128"},
+		{value: "2001:db8::/32", want: "2001:db8::/32"},
+		{value: "10.0.0.0/33", wantErr: true},
+		{value: "not-an-ip", wantErr: true},
+		{value: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		networks, err := ParseCIDRs([]string{tt.value})
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseCIDRs(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if err == nil && networks[0].String() != tt.want {
+			t.Errorf("ParseCIDRs(%q) = %s, want %s", tt.value, networks[0], tt.want)
+		}
+	}
+}
+
+func TestRateLimitPolicyNetworks(t *testing.T) {
+	policy := &RateLimitPolicy{
+		Allow: mustCIDRs(t, "10.0.0.0/8", "2001:db8::/32"),
+		Deny:  mustCIDRs(t, "203.0.113.0/24", "198.51.100.9"),
+	}
+	tests := []struct {
+		ip           string
+		wantDenied   bool
+		wantBypassed bool
+	}{
+		{ip: "10.1.2.3", wantBypassed: true},
+		{ip: "2001:db8::42", wantBypassed: true},
+		{ip: "203.0.113.200", wantDenied: true},
+		{ip: "198.51.100.9", wantDenied: true},
+		{ip: "198.51.100.10"},
+		{ip: "11.0.0.1"},
+		{ip: "not-an-ip"},
+		{ip: ""},
+	}
+	for _, tt := range tests {
+		if got := policy.Denied(tt.ip); got != tt.wantDenied {
+			t.Errorf("Denied(%q) = %v, want %v", tt.ip, got, tt.wantDenied)
+		}
+		if got := policy.Bypassed(tt.ip); got != tt.wantBypassed {
+			t.Errorf("Bypassed(%q) = %v, want %v", tt.ip, got, tt.wantBypassed)
+		}
+	}
+}
+
+func TestRateLimitPolicyRuleForIP(t *testing.T) {
+	office := RateLimitRule{Rate: 50}
+	partner := RateLimitRule{Rate: 20, Ban: time.Minute}
+	wallpaper := RateLimitRule{Rate: 5, Ban: DefaultBanDuration}
+	fallback := RateLimitRule{Rate: 3}
+
+	policy := &RateLimitPolicy{
+		Groups: map[string]RateLimitRule{
+			RateLimitGroupWallpaper: wallpaper,
+			RateLimitGroupDefault:   fallback,
+		},
+		CIDRs: []CIDRRule{
+			mustCIDRRule(t, "192.168.1.0/24", office),
+			mustCIDRRule(t, "192.168.0.0/16", partner), // 前一条更具体，按顺序先匹配
+		},
+	}
+	tests := []struct {
+		name  string
+		group string
+		ip    string
+		want  RateLimitRule
+	}{
+		{"cidr rule wins over group", RateLimitGroupWallpaper, "192.168.1.10", office},
+		{"first matching cidr", RateLimitGroupWallpaper, "192.168.2.10", partner},
+		{"group rule", RateLimitGroupWallpaper, "8.8.8.8", wallpaper},
+		{"unconfigured group falls back to default", RateLimitGroupAdmin, "8.8.8.8", fallback},
+		{"invalid ip uses group rule", RateLimitGroupWallpaper, "bogus", wallpaper},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := policy.RuleForIP(tt.group, tt.ip); got != tt.want {
+				t.Errorf("RuleForIP(%q, %q) = %+v, want %+v", tt.group, tt.ip, got, tt.want)
+			}
+		})
+	}
+
+	// 策略中没有 default 分组时使用内置默认规则
+	empty := &RateLimitPolicy{}
+	if got, want := empty.RuleForIP(RateLimitGroupAdmin, "8.8.8.8"), defaultRateLimitPolicy.Groups[RateLimitGroupDefault]; got != want {
+		t.Errorf("empty policy rule = %+v, want %+v", got, want)
+	}
+}
+
+func TestRateLimitPolicyRuleForAPIKey(t *testing.T) {
+	override := RateLimitRule{Rate: 100, Burst: 200}
+	policy := &RateLimitPolicy{APIKeys: map[string]RateLimitRule{"key-1": override}}
+	tests := []struct {
+		name    string
+		id      string
+		keyRate int
+		want    RateLimitRule
+	}{
+		{"policy overrides key rate", "key-1", 10, override},
+		{"key rate", "key-2", 10, RateLimitRule{Rate: 10}},
+		{"default rate", "key-2", 0, RateLimitRule{Rate: DefaultAPIKeyRateLimit}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := policy.RuleForAPIKey(tt.id, tt.keyRate); got != tt.want {
+				t.Errorf("RuleForAPIKey(%q, %d) = %+v, want %+v", tt.id, tt.keyRate, got, tt.want)
+			}
+		})
+	}
+}
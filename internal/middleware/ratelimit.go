@@ -1,8 +1,12 @@
 package middleware
 
 import (
+	"context"
 	"fmt"
 	"github.com/TXM983/wallpaper-api-v1/internal/logger"
+	"github.com/TXM983/wallpaper-api-v1/internal/metrics"
+	utils "github.com/TXM983/wallpaper-api-v1/internal/util"
+	"strconv"
 	"sync"
 	"time"
 
@@ -12,8 +16,9 @@ import (
 
 // 定义复合键结构，包含IP和速率
 type limiterKey struct {
-	IP   string
-	Rate int
+	IP    string
+	Rate  int
+	Burst int
 }
 
 // 存储限流器，键为limiterKey
@@ -25,79 +30,122 @@ var ipLastAccess sync.Map
 // 记录限流起始时间，键为limiterKey
 var ipBlockedUntil sync.Map
 
-// RateLimit 限流中间件
-func RateLimit(perSecond int) gin.HandlerFunc {
+// RateLimit 限流中间件，按当前策略中 group 的规则（或客户端所在网段的规则）按 IP 限流
+// 禁止网段直接返回 403，允许网段不限流；携带 API Key 的请求按 Key 限流
+func RateLimit(group string) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		policy := CurrentRateLimitPolicy()
 		ip := c.ClientIP()
-		if perSecond <= 0 {
-			c.AbortWithStatusJSON(500, gin.H{"error": "invalid rate limit configuration"})
+
+		if policy.Denied(ip) {
+			abortDenied(c)
+			return
+		}
+		if policy.Bypassed(ip) {
+			c.Next()
+			return
+		}
+
+		// 使用 API Key 的请求按 Key 限流，不再按 IP 限流
+		if APIKeyFromContext(c) != nil {
+			c.Next()
 			return
 		}
 
-		key := limiterKey{IP: ip, Rate: perSecond}
+		rule := policy.RuleForIP(group, ip)
+		if rule.Rate <= 0 {
+			Log(c).Errorf("No valid rate limit rule for group %s", group)
+			utils.ErrorResponse(c, 500, "rate limit error", "Invalid rate limit configuration.")
+			c.Abort()
+			return
+		}
 
-		// 确保时区正确加载
-		loc, err := time.LoadLocation("Asia/Shanghai")
+		result, err := RateLimiter.Allow(context.Background(), group+":"+ip, rule)
 		if err != nil {
-			// 兜底方案：如果无法加载 "Asia/Shanghai"，使用固定时区
-			loc = time.FixedZone("CST", 8*3600) // CST (China Standard Time) UTC+8
+			// 限流存储不可用时放行，避免影响正常请求
+			Log(c).WithError(err).Error("Rate limiter error")
+			c.Next()
+			return
 		}
 
-		now := time.Now().In(loc) // 确保 `now` 具有时区信息
-
-		// 检查封禁状态
-		if blockedUntil, exists := ipBlockedUntil.Load(key); exists {
-			blockTime := blockedUntil.(time.Time)
-			if blockTime.IsZero() {
-				blockTime = now
+		setRateLimitHeaders(c, result)
+		if !result.Allowed {
+			metrics.ObserveRateLimitRejection(group, result.Banned)
+			if result.Banned {
+				Log(c).Warnf("Rate limit exceeded (Group: %s, Rate: %d/s), blocked for %s", group, rule.Rate, result.RetryAfter)
 			} else {
-				blockTime = blockTime.In(loc) // 确保带有时区
+				Log(c).Warnf("Rate limit exceeded (Group: %s, Rate: %d/s)", group, rule.Rate)
 			}
+			abortRateLimited(c, result, fmt.Sprintf("Rate limit of %d requests per second exceeded, retry after %d seconds.", rule.Rate, ceilSeconds(result.RetryAfter)))
+			return
+		}
 
-			formattedTime := blockTime.Format("2006-01-02 15:04:05")
-
-			if now.Before(blockTime) {
-				logger.LogErrorAsync(fmt.Sprintf("IP %s is still blocked until %s", ip, formattedTime))
-				c.AbortWithStatusJSON(429, gin.H{"error": "too many requests, please wait until " + formattedTime})
-				return
-			}
+		Log(c).Debugf("Allowed access (Group: %s, Rate: %d/s)", group, rule.Rate)
+		c.Next()
+	}
+}
 
-			ipBlockedUntil.Delete(key)
-		}
+// 禁止网段的请求返回 403
+func abortDenied(c *gin.Context) {
+	Log(c).Warnf("Request from denied network, path: %s", c.Request.URL.Path)
+	utils.ErrorResponse(c, 403, "forbidden", "Access from your network is not allowed.")
+	c.Abort()
+}
 
-		limiter := getLimiter(key, perSecond)
+// RateLimitInfo 限流拒绝响应中的数据
+type RateLimitInfo struct {
+	Limit      int   `json:"limit"`      // 突发请求数
+	Remaining  int   `json:"remaining"`  // 剩余可用请求数
+	ResetAt    int64 `json:"resetAt"`    // 配额完全恢复的时间（Unix 秒）
+	RetryAfter int   `json:"retryAfter"` // 距离下次允许请求的秒数
+	RetryAt    int64 `json:"retryAt"`    // 下次允许请求的时间（Unix 秒）
+	Banned     bool  `json:"banned"`     // 是否处于封禁中
+}
 
-		if !limiter.Allow() {
-			blockTime := now.Add(2 * time.Minute) // 设置封禁时间
-			ipBlockedUntil.Store(key, blockTime)
+// 向上取整到秒，用于响应头
+func ceilSeconds(d time.Duration) int {
+	if d <= 0 {
+		return 0
+	}
+	return int((d + time.Second - 1) / time.Second)
+}
 
-			formattedTime := blockTime.Format("2006-01-02 15:04:05")
-			logger.LogErrorAsync(fmt.Sprintf("Rate limit exceeded for IP: %s (Rate: %d/s), blocked until %s", ip, perSecond, formattedTime))
-			c.AbortWithStatusJSON(429, gin.H{"error": "too many requests, please wait until " + formattedTime})
-			return
-		}
+// 写入 RateLimit-Limit、RateLimit-Remaining、RateLimit-Reset 响应头（IETF RateLimit 头草案，Reset 为剩余秒数）
+func setRateLimitHeaders(c *gin.Context, result LimitResult) {
+	header := c.Writer.Header()
+	header.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+	header.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	header.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.ResetAfter)))
+}
 
-		logger.LogInfoAsync(fmt.Sprintf("Allowed access for IP: %s (Rate: %d/s)", ip, perSecond))
-		ipLastAccess.Store(key, now) // 存入时确保 `now` 带时区
-		c.Next()
+// 返回 429 和 Retry-After 头，响应体中包含机器可读的恢复时间
+func abortRateLimited(c *gin.Context, result LimitResult, message string) {
+	retryAfter := ceilSeconds(result.RetryAfter)
+	if retryAfter < 1 {
+		retryAfter = 1
 	}
+	now := time.Now()
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	utils.ErrorResponseWithData(c, 429, "too many requests", message, RateLimitInfo{
+		Limit:      result.Limit,
+		Remaining:  result.Remaining,
+		ResetAt:    now.Add(result.ResetAfter).Unix(),
+		RetryAfter: retryAfter,
+		RetryAt:    now.Add(time.Duration(retryAfter) * time.Second).Unix(),
+		Banned:     result.Banned,
+	})
+	c.Abort()
 }
 
 // 获取或创建限流器（并发安全）
-func getLimiter(key limiterKey, perSecond int) *rate.Limiter {
+func getLimiter(key limiterKey) *rate.Limiter {
 	// 先尝试加载现有限流器
 	if limiter, exists := ipLimiters.Load(key); exists {
 		return limiter.(*rate.Limiter)
 	}
 
-	// 计算合理的突发值
-	burst := perSecond
-	if burst < 1 {
-		burst = 1
-	}
-
 	// 创建新限流器（此时可能有其他goroutine也在创建）
-	newLimiter := rate.NewLimiter(rate.Limit(perSecond), burst)
+	newLimiter := rate.NewLimiter(rate.Limit(key.Rate), key.Burst)
 
 	// 原子性存储或获取已存在的实例
 	limiter, loaded := ipLimiters.LoadOrStore(key, newLimiter)
@@ -109,35 +157,49 @@ func getLimiter(key limiterKey, perSecond int) *rate.Limiter {
 	return newLimiter
 }
 
-// InitRateLimiterCleanup 初始化清理任务
-func InitRateLimiterCleanup(interval time.Duration) {
-	go cleanupLimiters(interval)
-}
-
-// 定期清理过期限流器
-func cleanupLimiters(interval time.Duration) {
+// RunRateLimiterCleanup 定期清理过期限流器和封禁记录，直到 ctx 结束
+func RunRateLimiterCleanup(ctx context.Context, interval time.Duration) {
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
-	for range ticker.C {
-		logger.LogInfo(fmt.Sprintf("Starting rate limiter cleanup..."))
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			cleanupLimiters()
+		}
+	}
+}
+
+// 清理过期限流器
+func cleanupLimiters() {
+	logger.LogInfo("Starting rate limiter cleanup...")
 
-		var expiredKeys []limiterKey
-		ipLimiters.Range(func(key, value interface{}) bool {
-			k := key.(limiterKey)
-			if lastAccess, exists := ipLastAccess.Load(k); exists {
-				if time.Since(lastAccess.(time.Time)) > 15*time.Minute {
-					expiredKeys = append(expiredKeys, k)
-				}
-			} else {
+	var expiredKeys []limiterKey
+	ipLimiters.Range(func(key, value interface{}) bool {
+		k := key.(limiterKey)
+		if lastAccess, exists := ipLastAccess.Load(k); exists {
+			if time.Since(lastAccess.(time.Time)) > 15*time.Minute {
 				expiredKeys = append(expiredKeys, k)
 			}
-			return true
-		})
-		for _, k := range expiredKeys {
-			ipLimiters.Delete(k)
-			ipLastAccess.Delete(k)
-			logger.LogInfo(fmt.Sprintf("Batch cleaned up limiter: %v\n", k))
+		} else {
+			expiredKeys = append(expiredKeys, k)
 		}
+		return true
+	})
+	for _, k := range expiredKeys {
+		ipLimiters.Delete(k)
+		ipLastAccess.Delete(k)
+		logger.LogInfo("Batch cleaned up limiter: %v", k)
 	}
+
+	// 清理已过期的封禁记录
+	now := time.Now()
+	ipBlockedUntil.Range(func(key, value interface{}) bool {
+		if now.After(value.(time.Time)) {
+			ipBlockedUntil.Delete(key)
+		}
+		return true
+	})
 }
@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	utils "github.com/TXM983/wallpaper-api-v1/internal/util"
+	"github.com/gin-gonic/gin"
+)
+
+func TestRateLimitInvalidRuleUsesEnvelope(t *testing.T) {
+	withPolicy(t, &RateLimitPolicy{Groups: map[string]RateLimitRule{
+		RateLimitGroupDefault: {Rate: 0},
+	}})
+
+	r := gin.New()
+	r.GET("/wallpaper", RateLimit(RateLimitGroupDefault), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/wallpaper", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusInternalServerError)
+	}
+	var resp utils.ApiResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Code != http.StatusInternalServerError || resp.Status != "error" || resp.Error == "" {
+		t.Errorf("response = %+v, want the error envelope", resp)
+	}
+}
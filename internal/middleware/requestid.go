@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"time"
+
+	"github.com/TXM983/wallpaper-api-v1/internal/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RequestIDHeader 请求 ID 的请求头和响应头
+const RequestIDHeader = "X-Request-ID"
+
+// 上下文中保存请求 ID 和开始时间的键
+const (
+	contextRequestID    = "requestID"
+	contextRequestStart = "requestStart"
+)
+
+// 客户端传入的请求 ID 最大长度
+const maxRequestIDLength = 128
+
+// RequestID 读取或生成请求 ID 并写入响应头，同时在请求上下文中放入带 request_id、client_ip、route 等字段的日志条目
+// 需放在链路追踪中间件之后，以便记录 trace_id
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.New().String()
+		}
+		c.Set(contextRequestID, id)
+		c.Set(contextRequestStart, time.Now())
+		c.Header(RequestIDHeader, id)
+
+		fields := logrus.Fields{
+			logger.FieldRequestID: id,
+			logger.FieldMethod:    c.Request.Method,
+			logger.FieldRoute:     c.FullPath(),
+			logger.FieldClientIP:  c.ClientIP(),
+		}
+		ctx := c.Request.Context()
+		if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+			fields[logger.FieldTraceID] = spanContext.TraceID().String()
+		}
+		c.Request = c.Request.WithContext(logger.NewContext(ctx, logger.Log.WithFields(fields)))
+		c.Next()
+	}
+}
+
+// 只接受可打印 ASCII，避免日志注入
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// RequestIDFromContext 当前请求的 ID
+func RequestIDFromContext(c *gin.Context) string {
+	return c.GetString(contextRequestID)
+}
+
+// Log 当前请求的日志条目，包含 request_id、route、client_ip 和从请求开始到现在的耗时
+func Log(c *gin.Context) *logrus.Entry {
+	entry := logger.FromContext(c.Request.Context())
+	if start, ok := c.Get(contextRequestStart); ok {
+		entry = entry.WithField(logger.FieldLatency, float64(time.Since(start.(time.Time)).Microseconds())/1000)
+	}
+	return entry
+}
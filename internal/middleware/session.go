@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/TXM983/wallpaper-api-v1/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// SessionCookieName 登录会话 Cookie 名
+const SessionCookieName = "wallpaper_session"
+
+// 上下文中保存操作者身份的键
+const (
+	contextPrincipal = "principal"
+	contextSession   = "session"
+)
+
+// SessionCookieSecure 会话 Cookie 是否只通过 HTTPS 发送
+var SessionCookieSecure = false
+
+// PrincipalFromContext 获取当前请求的操作者，未认证时返回 nil
+func PrincipalFromContext(c *gin.Context) *service.Principal {
+	if value, exists := c.Get(contextPrincipal); exists {
+		return value.(*service.Principal)
+	}
+	return nil
+}
+
+// SessionFromContext 获取当前请求使用的会话，非会话认证时返回 nil
+func SessionFromContext(c *gin.Context) *service.SessionClaims {
+	if value, exists := c.Get(contextSession); exists {
+		return value.(*service.SessionClaims)
+	}
+	return nil
+}
+
+// SetSessionCookie 写入会话 Cookie（HttpOnly，SameSite=Lax，跨站的写请求不会携带）
+func SetSessionCookie(c *gin.Context, token string, maxAge int) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(SessionCookieName, token, maxAge, "/", "", SessionCookieSecure, true)
+}
+
+// ClearSessionCookie 清除会话 Cookie
+func ClearSessionCookie(c *gin.Context) {
+	SetSessionCookie(c, "", -1)
+}
+
+// 校验会话 Cookie，成功时写入上下文
+func resolveSession(c *gin.Context, sessions *service.SessionManager) bool {
+	token, err := c.Cookie(SessionCookieName)
+	if err != nil || token == "" {
+		return false
+	}
+
+	principal, claims, err := sessions.Parse(context.Background(), token)
+	if err != nil {
+		return false
+	}
+
+	c.Set(contextPrincipal, principal)
+	c.Set(contextSession, claims)
+	return true
+}
+
+// OptionalSession 存在有效会话时写入上下文（包括角色），不拦截未登录的请求
+func OptionalSession(auth *AdminAuthenticator, sessions *service.SessionManager, users *service.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if resolveSession(c, sessions) {
+			if role, err := resolveRole(auth, users, PrincipalFromContext(c)); err == nil {
+				c.Set(contextRole, role)
+			}
+		}
+		c.Next()
+	}
+}
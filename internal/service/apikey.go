@@ -0,0 +1,230 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+// API Key 权限范围
+const (
+	ScopeRead   = "read"   // 获取壁纸
+	ScopeUpload = "upload" // 上传壁纸
+	ScopeDelete = "delete" // 删除壁纸
+	ScopeAdmin  = "admin"  // 全部管理接口，包含以上所有权限
+)
+
+// API Key 相关 Redis Key
+const (
+	apiKeysKey         = "apikey:keys"   // Hash：id -> APIKey JSON
+	apiKeyHashPrefix   = "apikey:hash:"  // String：SHA-256(key) -> id
+	apiKeyQuotaPrefix  = "apikey:quota:" // String：apikey:quota:<id>:<yyyymmdd> -> 当日请求数
+	apiKeyPrefix       = "wpk_"          // 明文 Key 前缀
+	apiKeyQuotaTTL     = 48 * time.Hour  // 配额计数保留时间
+	apiKeyDisplayChars = 8               // 列表中展示的明文前缀长度
+)
+
+var (
+	// ErrAPIKeyNotFound API Key 不存在
+	ErrAPIKeyNotFound = errors.New("api key not found")
+	// ErrAPIKeyInvalid API Key 无效或已吊销
+	ErrAPIKeyInvalid = errors.New("invalid api key")
+)
+
+// APIKey API Key 元数据，明文只在创建时返回一次，Redis 中只保存哈希
+type APIKey struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	Prefix     string   `json:"prefix"` // 明文前缀，便于识别
+	Scopes     []string `json:"scopes"`
+	RateLimit  int      `json:"rateLimit"`  // 每秒请求数，0 表示使用默认值
+	DailyQuota int64    `json:"dailyQuota"` // 每日请求上限，0 表示不限制
+	// 防盗链：允许和禁止嵌入的 Referer/Origin 域名，允许列表非空时覆盖全局和分类配置
+	AllowedReferers []string `json:"allowedReferers,omitempty"`
+	DeniedReferers  []string `json:"deniedReferers,omitempty"`
+	CreatedAt       int64    `json:"createdAt"`
+	RevokedAt       int64    `json:"revokedAt,omitempty"`
+	UsageToday      int64    `json:"usageToday"` // 查询时填充
+}
+
+// HasScope 判断是否拥有权限，admin 拥有全部权限
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateScope 校验权限范围
+func ValidateScope(scope string) bool {
+	return scope == ScopeRead || scope == ScopeUpload || scope == ScopeDelete || scope == ScopeAdmin
+}
+
+// APIKeyService API Key 管理
+type APIKeyService struct {
+	rdb *redis.Client
+}
+
+// NewAPIKeyService 创建 API Key 服务
+func NewAPIKeyService(rdb *redis.Client) *APIKeyService {
+	return &APIKeyService{rdb: rdb}
+}
+
+// 计算明文 Key 的哈希
+func hashAPIKey(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+// Create 创建 API Key，spec 中的名称、权限、限额和防盗链配置生效，返回明文 Key 和元数据
+func (s *APIKeyService) Create(ctx context.Context, spec APIKey) (string, *APIKey, error) {
+	if len(spec.Scopes) == 0 {
+		return "", nil, fmt.Errorf("at least one scope is required")
+	}
+	for _, scope := range spec.Scopes {
+		if !ValidateScope(scope) {
+			return "", nil, fmt.Errorf("unsupported scope '%s'", scope)
+		}
+	}
+	if spec.RateLimit < 0 || spec.DailyQuota < 0 {
+		return "", nil, fmt.Errorf("rateLimit and dailyQuota must not be negative")
+	}
+
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", nil, fmt.Errorf("failed to generate api key: %v", err)
+	}
+	raw := apiKeyPrefix + hex.EncodeToString(buf)
+
+	key := &APIKey{
+		ID:              uuid.New().String(),
+		Name:            spec.Name,
+		Prefix:          raw[:len(apiKeyPrefix)+apiKeyDisplayChars],
+		Scopes:          spec.Scopes,
+		RateLimit:       spec.RateLimit,
+		DailyQuota:      spec.DailyQuota,
+		AllowedReferers: spec.AllowedReferers,
+		DeniedReferers:  spec.DeniedReferers,
+		CreatedAt:       time.Now().Unix(),
+	}
+	data, err := json.Marshal(key)
+	if err != nil {
+		return "", nil, err
+	}
+
+	tx := s.rdb.TxPipeline()
+	tx.HSet(ctx, apiKeysKey, key.ID, data)
+	tx.Set(ctx, apiKeyHashPrefix+hashAPIKey(raw), key.ID, 0)
+	if _, err := tx.Exec(ctx); err != nil {
+		return "", nil, fmt.Errorf("failed to save api key: %v", err)
+	}
+	return raw, key, nil
+}
+
+// Get 获取 API Key 元数据
+func (s *APIKeyService) Get(ctx context.Context, id string) (*APIKey, error) {
+	data, err := s.rdb.HGet(ctx, apiKeysKey, id).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var key APIKey
+	if err := json.Unmarshal([]byte(data), &key); err != nil {
+		return nil, fmt.Errorf("failed to decode api key %s: %v", id, err)
+	}
+	return &key, nil
+}
+
+// List 列出所有 API Key 及当日用量
+func (s *APIKeyService) List(ctx context.Context) ([]APIKey, error) {
+	values, err := s.rdb.HGetAll(ctx, apiKeysKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]APIKey, 0, len(values))
+	for _, data := range values {
+		var key APIKey
+		if err := json.Unmarshal([]byte(data), &key); err != nil {
+			continue
+		}
+		key.UsageToday, _ = s.rdb.Get(ctx, quotaKey(key.ID, time.Now())).Int64()
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// Revoke 吊销 API Key，保留元数据便于审计
+func (s *APIKeyService) Revoke(ctx context.Context, id string) error {
+	key, err := s.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if key.RevokedAt != 0 {
+		return nil
+	}
+
+	key.RevokedAt = time.Now().Unix()
+	data, err := json.Marshal(key)
+	if err != nil {
+		return err
+	}
+	return s.rdb.HSet(ctx, apiKeysKey, id, data).Err()
+}
+
+// Authenticate 校验明文 Key，返回元数据
+func (s *APIKeyService) Authenticate(ctx context.Context, raw string) (*APIKey, error) {
+	id, err := s.rdb.Get(ctx, apiKeyHashPrefix+hashAPIKey(raw)).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrAPIKeyInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := s.Get(ctx, id)
+	if errors.Is(err, ErrAPIKeyNotFound) {
+		return nil, ErrAPIKeyInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+	if key.RevokedAt != 0 {
+		return nil, ErrAPIKeyInvalid
+	}
+	return key, nil
+}
+
+// ConsumeQuota 记录一次请求并检查每日配额，返回当日已用次数和是否允许
+func (s *APIKeyService) ConsumeQuota(ctx context.Context, key *APIKey) (int64, bool, error) {
+	if key.DailyQuota <= 0 {
+		return 0, true, nil
+	}
+
+	k := quotaKey(key.ID, time.Now())
+	tx := s.rdb.TxPipeline()
+	used := tx.Incr(ctx, k)
+	tx.Expire(ctx, k, apiKeyQuotaTTL)
+	if _, err := tx.Exec(ctx); err != nil {
+		return 0, false, err
+	}
+	return used.Val(), used.Val() <= key.DailyQuota, nil
+}
+
+// 每日配额计数 Key（按 UTC 日期）
+func quotaKey(id string, now time.Time) string {
+	return apiKeyQuotaPrefix + id + ":" + now.UTC().Format("20060102")
+}
@@ -0,0 +1,198 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// 审计日志 Redis Stream，只追加，按条数和保留时长裁剪
+const auditStreamKey = "audit:log"
+
+// 审计查询每批读取的条数和单次查询最多扫描的条数
+const (
+	auditScanBatch = 200
+	auditScanLimit = 10000
+)
+
+// 审计操作
+const (
+	AuditWallpaperUpload  = "wallpaper.upload"        // 上传壁纸
+	AuditWallpaperDelete  = "wallpaper.delete"        // 删除壁纸
+	AuditTagsUpdate       = "wallpaper.tags.update"   // 修改壁纸标签
+	AuditCollectionSave   = "collection.save"         // 创建或修改壁纸合集
+	AuditCollectionDelete = "collection.delete"       // 删除壁纸合集
+	AuditCacheRebuild     = "cache.rebuild"           // 提交缓存重建任务
+	AuditRateLimitReload  = "config.ratelimit.reload" // 重新加载限流策略
+	AuditAPIKeyCreate     = "apikey.create"           // 创建 API Key
+	AuditAPIKeyRevoke     = "apikey.revoke"           // 吊销 API Key
+	AuditUserSave         = "user.save"               // 创建或修改用户
+	AuditUserDelete       = "user.delete"             // 删除用户
+	AuditWebhookCreate    = "webhook.create"          // 创建 Webhook 订阅
+	AuditWebhookDelete    = "webhook.delete"          // 删除 Webhook 订阅
+	AuditPermissionDenied = "permission.denied"       // 缺少路由要求的权限，对象为请求的路由
+)
+
+// 审计结果
+const (
+	AuditResultSuccess = "success"
+	AuditResultFailure = "failure"
+	AuditResultDenied  = "denied" // 权限不足被拒绝
+)
+
+// AuditEntry 一条审计记录
+type AuditEntry struct {
+	ID        string      `json:"id"`        // Stream ID，可作为分页游标
+	Timestamp int64       `json:"timestamp"` // 毫秒
+	Actor     string      `json:"actor"`     // 操作者名称（邮箱、用户名或 Key 名称）
+	Subject   string      `json:"subject,omitempty"`
+	Method    string      `json:"method,omitempty"` // 操作者的认证方式
+	Role      string      `json:"role,omitempty"`
+	IP        string      `json:"ip"`
+	RequestID string      `json:"requestId,omitempty"`
+	Action    string      `json:"action"`
+	Targets   []string    `json:"targets"` // 操作对象，如 pc/a.webp、apikey:<id>、user:<name>
+	Before    interface{} `json:"before,omitempty"`
+	After     interface{} `json:"after,omitempty"`
+	Result    string      `json:"result"`
+	Error     string      `json:"error,omitempty"`
+}
+
+// AuditQuery 审计日志查询条件，结果按时间倒序
+type AuditQuery struct {
+	Actor  string    // 操作者名称或唯一标识
+	Action string    // 操作，以 . 结尾时按前缀匹配，如 wallpaper.
+	Target string    // 操作对象包含该字符串
+	Result string    // success、failure 或 denied
+	Since  time.Time // 起始时间（含）
+	Until  time.Time // 结束时间（含）
+	Cursor string    // 上一页返回的游标，查询更早的记录
+	Limit  int
+}
+
+func (q *AuditQuery) match(entry *AuditEntry) bool {
+	if q.Actor != "" && entry.Actor != q.Actor && entry.Subject != q.Actor {
+		return false
+	}
+	if q.Action != "" {
+		if strings.HasSuffix(q.Action, ".") {
+			if !strings.HasPrefix(entry.Action, q.Action) {
+				return false
+			}
+		} else if entry.Action != q.Action {
+			return false
+		}
+	}
+	if q.Result != "" && entry.Result != q.Result {
+		return false
+	}
+	if q.Target != "" {
+		for _, target := range entry.Targets {
+			if strings.Contains(target, q.Target) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// AuditOptions 审计日志保留策略
+type AuditOptions struct {
+	MaxLen    int64         // 最多保留条数（近似裁剪），0 表示不限制
+	Retention time.Duration // 保留时长，0 表示不限制
+}
+
+// AuditLog 管理操作的审计日志
+type AuditLog struct {
+	rdb  *redis.Client
+	opts AuditOptions
+}
+
+// NewAuditLog 创建审计日志
+func NewAuditLog(rdb *redis.Client, opts AuditOptions) *AuditLog {
+	return &AuditLog{rdb: rdb, opts: opts}
+}
+
+// Record 追加一条审计记录，并按保留策略裁剪过期记录
+func (a *AuditLog) Record(ctx context.Context, entry *AuditEntry) error {
+	if entry.Timestamp == 0 {
+		entry.Timestamp = time.Now().UnixMilli()
+	}
+	if entry.Targets == nil {
+		entry.Targets = []string{}
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	pipe := a.rdb.Pipeline()
+	add := pipe.XAdd(ctx, &redis.XAddArgs{
+		Stream: auditStreamKey,
+		MaxLen: a.opts.MaxLen,
+		Approx: true,
+		// 冗余的字段便于在 redis-cli 中直接查看
+		Values: []interface{}{"action", entry.Action, "actor", entry.Actor, "result", entry.Result, "data", data},
+	})
+	if a.opts.Retention > 0 {
+		minID := strconv.FormatInt(time.Now().Add(-a.opts.Retention).UnixMilli(), 10)
+		pipe.XTrimMinIDApprox(ctx, auditStreamKey, minID, 0)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return err
+	}
+	entry.ID = add.Val()
+	return nil
+}
+
+// Query 按条件查询审计记录，返回记录和下一页游标（没有更多记录时为空）
+// 单次最多扫描 auditScanLimit 条，过滤条件很少命中时可能返回不满一页的结果和游标
+func (a *AuditLog) Query(ctx context.Context, q AuditQuery) ([]AuditEntry, string, error) {
+	end := "+"
+	switch {
+	case q.Cursor != "":
+		end = "(" + q.Cursor
+	case !q.Until.IsZero():
+		end = strconv.FormatInt(q.Until.UnixMilli(), 10)
+	}
+	start := "-"
+	if !q.Since.IsZero() {
+		start = strconv.FormatInt(q.Since.UnixMilli(), 10)
+	}
+
+	entries := make([]AuditEntry, 0, q.Limit)
+	scanned := 0
+	for scanned < auditScanLimit {
+		messages, err := a.rdb.XRevRangeN(ctx, auditStreamKey, end, start, auditScanBatch).Result()
+		if err != nil {
+			return nil, "", err
+		}
+		for _, message := range messages {
+			scanned++
+			end = "(" + message.ID
+
+			data, _ := message.Values["data"].(string)
+			var entry AuditEntry
+			if err := json.Unmarshal([]byte(data), &entry); err != nil {
+				continue
+			}
+			entry.ID = message.ID
+			if !q.match(&entry) {
+				continue
+			}
+			entries = append(entries, entry)
+			if len(entries) >= q.Limit {
+				return entries, message.ID, nil
+			}
+		}
+		if len(messages) < auditScanBatch {
+			return entries, "", nil
+		}
+	}
+	return entries, strings.TrimPrefix(end, "("), nil
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// 按顺序写入审计记录，每条间隔 1 秒（Stream ID 使用 miniredis 的时间）
+func recordAuditEntries(t *testing.T, log *AuditLog, setTime func(time.Time), start time.Time, actions ...string) []AuditEntry {
+	t.Helper()
+	entries := make([]AuditEntry, 0, len(actions))
+	for i, action := range actions {
+		at := start.Add(time.Duration(i) * time.Second)
+		setTime(at)
+		entry := &AuditEntry{Actor: "alice", Action: action, Targets: []string{"pc/a.webp"}, Result: AuditResultSuccess, Timestamp: at.UnixMilli()}
+		if err := log.Record(context.Background(), entry); err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, *entry)
+	}
+	return entries
+}
+
+func auditActions(entries []AuditEntry) []string {
+	actions := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		actions = append(actions, entry.Action)
+	}
+	return actions
+}
+
+func TestAuditLogQuery(t *testing.T) {
+	mr, rdb := newTestRedis(t)
+	log := NewAuditLog(rdb, AuditOptions{})
+	start := time.Now().Add(-time.Hour).Truncate(time.Second)
+	recorded := recordAuditEntries(t, log, mr.SetTime, start,
+		AuditWallpaperUpload, AuditTagsUpdate, AuditCacheRebuild, AuditWallpaperDelete, AuditUserSave)
+	at := func(i int) time.Time { return start.Add(time.Duration(i) * time.Second) }
+
+	tests := []struct {
+		name       string
+		query      AuditQuery
+		want       []string
+		wantCursor bool
+	}{
+		{"all newest first", AuditQuery{Limit: 10},
+			[]string{AuditUserSave, AuditWallpaperDelete, AuditCacheRebuild, AuditTagsUpdate, AuditWallpaperUpload}, false},
+		{"action prefix", AuditQuery{Action: "wallpaper.", Limit: 10},
+			[]string{AuditWallpaperDelete, AuditTagsUpdate, AuditWallpaperUpload}, false},
+		{"exact action is not a prefix", AuditQuery{Action: "wallpaper", Limit: 10}, []string{}, false},
+		{"since and until are inclusive", AuditQuery{Since: at(1), Until: at(3), Limit: 10},
+			[]string{AuditWallpaperDelete, AuditCacheRebuild, AuditTagsUpdate}, false},
+		{"since only", AuditQuery{Since: at(4), Limit: 10}, []string{AuditUserSave}, false},
+		{"until only", AuditQuery{Until: at(0), Limit: 10}, []string{AuditWallpaperUpload}, false},
+		{"limit returns cursor", AuditQuery{Limit: 2}, []string{AuditUserSave, AuditWallpaperDelete}, true},
+		{"cursor continues", AuditQuery{Cursor: recorded[3].ID, Limit: 10},
+			[]string{AuditCacheRebuild, AuditTagsUpdate, AuditWallpaperUpload}, false},
+		{"actor mismatch", AuditQuery{Actor: "bob", Limit: 10}, []string{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, cursor, err := log.Query(context.Background(), tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := auditActions(entries); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("actions = %v, want %v", got, tt.want)
+			}
+			if (cursor != "") != tt.wantCursor {
+				t.Errorf("cursor = %q, want cursor: %v", cursor, tt.wantCursor)
+			}
+		})
+	}
+}
+
+func TestAuditLogCursorPaging(t *testing.T) {
+	mr, rdb := newTestRedis(t)
+	log := NewAuditLog(rdb, AuditOptions{})
+	recorded := recordAuditEntries(t, log, mr.SetTime, time.Now().Add(-time.Hour),
+		"a.1", "a.2", "a.3", "a.4", "a.5")
+
+	var pages [][]string
+	cursor := ""
+	for i := 0; i < len(recorded); i++ {
+		entries, next, err := log.Query(context.Background(), AuditQuery{Cursor: cursor, Limit: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages = append(pages, auditActions(entries))
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+
+	want := [][]string{{"a.5", "a.4"}, {"a.3", "a.2"}, {"a.1"}}
+	if !reflect.DeepEqual(pages, want) {
+		t.Errorf("pages = %v, want %v", pages, want)
+	}
+}
+
+func TestAuditLogTrim(t *testing.T) {
+	t.Run("max len", func(t *testing.T) {
+		mr, rdb := newTestRedis(t)
+		log := NewAuditLog(rdb, AuditOptions{MaxLen: 3})
+		recordAuditEntries(t, log, mr.SetTime, time.Now().Add(-time.Hour), "a.1", "a.2", "a.3", "a.4", "a.5")
+
+		entries, _, err := log.Query(context.Background(), AuditQuery{Limit: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := auditActions(entries), []string{"a.5", "a.4", "a.3"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("actions = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("retention", func(t *testing.T) {
+		mr, rdb := newTestRedis(t)
+		log := NewAuditLog(rdb, AuditOptions{Retention: 24 * time.Hour})
+		now := time.Now()
+		recordAuditEntries(t, log, mr.SetTime, now.Add(-72*time.Hour), "old.1", "old.2")
+		recordAuditEntries(t, log, mr.SetTime, now.Add(-time.Hour), "new.1", "new.2")
+
+		entries, _, err := log.Query(context.Background(), AuditQuery{Limit: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := auditActions(entries), []string{"new.2", "new.1"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("actions = %v, want %v", got, want)
+		}
+	})
+}
@@ -0,0 +1,185 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// 壁纸合集 Hash（合集 ID -> JSON）
+const collectionsKey = "wallpaper:collections"
+
+// 单个合集的壁纸数量上限
+const maxCollectionWallpapers = 500
+
+// 合集 ID：小写字母、数字和连字符
+var collectionIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,63}$`)
+
+// ErrCollectionNotFound 合集不存在
+var ErrCollectionNotFound = errors.New("collection not found")
+
+// Collection 壁纸合集，由 curator 维护
+type Collection struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Wallpapers  []string `json:"wallpapers"` // 类型/文件名，如 pc/a.webp，按添加顺序
+	UpdatedBy   string   `json:"updatedBy,omitempty"`
+	CreatedAt   int64    `json:"createdAt"`
+	UpdatedAt   int64    `json:"updatedAt"`
+}
+
+// 解析合集中的壁纸：类型/文件名
+func splitCollectionWallpaper(wallpaper string) (string, string, bool) {
+	deviceType, fileName, ok := strings.Cut(wallpaper, "/")
+	if !ok || !ValidateDeviceType(deviceType) || fileName == "" || strings.Contains(fileName, "/") {
+		return "", "", false
+	}
+	return deviceType, fileName, true
+}
+
+// SaveCollection 创建或更新合集，壁纸去重并校验存在于壁纸列表中
+func SaveCollection(ctx context.Context, rdb *redis.Client, collection Collection) (*Collection, error) {
+	collection.ID = strings.TrimSpace(collection.ID)
+	collection.Name = strings.TrimSpace(collection.Name)
+	if !collectionIDPattern.MatchString(collection.ID) {
+		return nil, fmt.Errorf("invalid collection id '%s', use lowercase letters, digits and '-'", collection.ID)
+	}
+	if collection.Name == "" {
+		return nil, fmt.Errorf("collection name is required")
+	}
+
+	wallpapers := make([]string, 0, len(collection.Wallpapers))
+	seen := make(map[string]bool)
+	for _, wallpaper := range collection.Wallpapers {
+		wallpaper = strings.TrimSpace(wallpaper)
+		if seen[wallpaper] {
+			continue
+		}
+		deviceType, fileName, ok := splitCollectionWallpaper(wallpaper)
+		if !ok {
+			return nil, fmt.Errorf("invalid wallpaper '%s', expected <type>/<fileName>", wallpaper)
+		}
+		exists, err := WallpaperExists(ctx, rdb, deviceType, fileName)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, fmt.Errorf("wallpaper '%s' does not exist", wallpaper)
+		}
+		seen[wallpaper] = true
+		wallpapers = append(wallpapers, wallpaper)
+	}
+	if len(wallpapers) > maxCollectionWallpapers {
+		return nil, fmt.Errorf("at most %d wallpapers are allowed in a collection", maxCollectionWallpapers)
+	}
+	collection.Wallpapers = wallpapers
+
+	existing, err := GetCollection(ctx, rdb, collection.ID)
+	if err != nil && !errors.Is(err, ErrCollectionNotFound) {
+		return nil, err
+	}
+	now := time.Now().Unix()
+	collection.CreatedAt = now
+	if existing != nil {
+		collection.CreatedAt = existing.CreatedAt
+	}
+	collection.UpdatedAt = now
+
+	data, err := json.Marshal(collection)
+	if err != nil {
+		return nil, err
+	}
+	if err := rdb.HSet(ctx, collectionsKey, collection.ID, data).Err(); err != nil {
+		return nil, fmt.Errorf("failed to save collection '%s': %v", collection.ID, err)
+	}
+	return &collection, nil
+}
+
+// GetCollection 查询合集
+func GetCollection(ctx context.Context, rdb *redis.Client, id string) (*Collection, error) {
+	data, err := rdb.HGet(ctx, collectionsKey, id).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrCollectionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var collection Collection
+	if err := json.Unmarshal(data, &collection); err != nil {
+		return nil, err
+	}
+	return &collection, nil
+}
+
+// ListCollections 列出全部合集，按 ID 排序
+func ListCollections(ctx context.Context, rdb *redis.Client) ([]Collection, error) {
+	values, err := rdb.HGetAll(ctx, collectionsKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	collections := make([]Collection, 0, len(values))
+	for _, data := range values {
+		var collection Collection
+		if json.Unmarshal([]byte(data), &collection) == nil {
+			collections = append(collections, collection)
+		}
+	}
+	sort.Slice(collections, func(i, j int) bool { return collections[i].ID < collections[j].ID })
+	return collections, nil
+}
+
+// DeleteCollection 删除合集，不影响其中的壁纸
+func DeleteCollection(ctx context.Context, rdb *redis.Client, id string) error {
+	deleted, err := rdb.HDel(ctx, collectionsKey, id).Result()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return ErrCollectionNotFound
+	}
+	return nil
+}
+
+// RemoveFromCollections 壁纸删除后从所有合集中移除
+func RemoveFromCollections(ctx context.Context, rdb *redis.Client, deviceType string, fileNames ...string) error {
+	removed := make(map[string]bool, len(fileNames))
+	for _, fileName := range fileNames {
+		removed[deviceType+"/"+fileName] = true
+	}
+
+	collections, err := ListCollections(ctx, rdb)
+	if err != nil {
+		return err
+	}
+	for _, collection := range collections {
+		kept := collection.Wallpapers[:0]
+		for _, wallpaper := range collection.Wallpapers {
+			if !removed[wallpaper] {
+				kept = append(kept, wallpaper)
+			}
+		}
+		if len(kept) == len(collection.Wallpapers) {
+			continue
+		}
+		collection.Wallpapers = kept
+		collection.UpdatedAt = time.Now().Unix()
+		data, err := json.Marshal(collection)
+		if err != nil {
+			return err
+		}
+		if err := rdb.HSet(ctx, collectionsKey, collection.ID, data).Err(); err != nil {
+			return fmt.Errorf("failed to update collection '%s': %v", collection.ID, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+// 基于 miniredis 的测试 Redis
+func newTestRedis(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	return mr, rdb
+}
+
+func TestSaveCollection(t *testing.T) {
+	_, rdb := newTestRedis(t)
+	ctx := context.Background()
+	rdb.RPush(ctx, "wallpaper:pc", "a.webp", "b.webp")
+	rdb.RPush(ctx, "wallpaper:mobile", "c.webp")
+
+	tests := []struct {
+		name       string
+		collection Collection
+		want       []string
+		wantErr    bool
+	}{
+		{"valid with duplicates", Collection{ID: "summer", Name: "Summer", Wallpapers: []string{"pc/a.webp", "mobile/c.webp", "pc/a.webp"}}, []string{"pc/a.webp", "mobile/c.webp"}, false},
+		{"empty", Collection{ID: "empty", Name: "Empty"}, []string{}, false},
+		{"missing wallpaper", Collection{ID: "x", Name: "X", Wallpapers: []string{"pc/missing.webp"}}, nil, true},
+		{"invalid device type", Collection{ID: "x", Name: "X", Wallpapers: []string{"tv/a.webp"}}, nil, true},
+		{"invalid path", Collection{ID: "x", Name: "X", Wallpapers: []string{"pc/../a.webp"}}, nil, true},
+		{"invalid id", Collection{ID: "Summer 2024", Name: "X"}, nil, true},
+		{"missing name", Collection{ID: "x"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			saved, err := SaveCollection(ctx, rdb, tt.collection)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SaveCollection() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !reflect.DeepEqual(saved.Wallpapers, tt.want) {
+				t.Errorf("wallpapers = %v, want %v", saved.Wallpapers, tt.want)
+			}
+			got, err := GetCollection(ctx, rdb, tt.collection.ID)
+			if err != nil || !reflect.DeepEqual(got.Wallpapers, tt.want) {
+				t.Errorf("GetCollection() = %+v, %v", got, err)
+			}
+		})
+	}
+}
+
+func TestRemoveFromCollections(t *testing.T) {
+	_, rdb := newTestRedis(t)
+	ctx := context.Background()
+	rdb.RPush(ctx, "wallpaper:pc", "a.webp", "b.webp")
+	rdb.RPush(ctx, "wallpaper:mobile", "a.webp")
+
+	if _, err := SaveCollection(ctx, rdb, Collection{ID: "one", Name: "One", Wallpapers: []string{"pc/a.webp", "pc/b.webp", "mobile/a.webp"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SaveCollection(ctx, rdb, Collection{ID: "two", Name: "Two", Wallpapers: []string{"pc/b.webp"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RemoveFromCollections(ctx, rdb, "pc", "a.webp"); err != nil {
+		t.Fatal(err)
+	}
+	one, _ := GetCollection(ctx, rdb, "one")
+	if want := []string{"pc/b.webp", "mobile/a.webp"}; !reflect.DeepEqual(one.Wallpapers, want) {
+		t.Errorf("one = %v, want %v", one.Wallpapers, want)
+	}
+	two, _ := GetCollection(ctx, rdb, "two")
+	if want := []string{"pc/b.webp"}; !reflect.DeepEqual(two.Wallpapers, want) {
+		t.Errorf("two = %v, want %v", two.Wallpapers, want)
+	}
+
+	if err := DeleteCollection(ctx, rdb, "two"); err != nil {
+		t.Fatal(err)
+	}
+	if err := DeleteCollection(ctx, rdb, "two"); !errors.Is(err, ErrCollectionNotFound) {
+		t.Errorf("second delete error = %v, want ErrCollectionNotFound", err)
+	}
+}
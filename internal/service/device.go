@@ -0,0 +1,91 @@
+package service
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// DeviceTypeAuto 自动识别设备类型
+const DeviceTypeAuto = "auto"
+
+// 移动端视口宽度阈值（CSS 像素），小于该值视为移动端
+const mobileViewportWidth = 768
+
+// ClientHintHeaders 服务端声明需要的 Client Hints（用于 Accept-CH）
+var ClientHintHeaders = []string{"Sec-CH-UA-Mobile", "Sec-CH-Viewport-Width", "Sec-CH-Viewport-Height", "Viewport-Width"}
+
+// DeviceVaryHeaders 自动识别结果依赖的请求头（用于 Vary）
+var DeviceVaryHeaders = append([]string{"User-Agent"}, ClientHintHeaders...)
+
+// 常见移动端 User-Agent 关键字（平板按 PC 处理）
+var mobileUAKeywords = []string{
+	"mobi", "iphone", "ipod", "windows phone", "blackberry", "opera mini", "iemobile", "harmonyos",
+}
+
+// 平板 User-Agent 关键字，命中时按 PC 处理
+var tabletUAKeywords = []string{"ipad", "tablet"}
+
+// IsAutoDeviceType 判断是否需要自动识别设备类型（未传 type 或 type=auto）
+func IsAutoDeviceType(deviceType string) bool {
+	return deviceType == "" || deviceType == DeviceTypeAuto
+}
+
+// DetectDeviceType 根据 Client Hints 和 User-Agent 自动识别设备类型，返回 "pc" 或 "mobile"
+// 优先级：Sec-CH-UA-Mobile > 视口尺寸 > User-Agent，均无法判断时默认 pc
+func DetectDeviceType(header http.Header) string {
+	// Sec-CH-UA-Mobile 为结构化布尔值：?1 / ?0
+	switch strings.TrimSpace(header.Get("Sec-CH-UA-Mobile")) {
+	case "?1":
+		return "mobile"
+	case "?0":
+		return "pc"
+	}
+
+	// 视口尺寸：竖屏或宽度较小视为移动端
+	width := parseViewportHint(header, "Sec-CH-Viewport-Width", "Viewport-Width")
+	height := parseViewportHint(header, "Sec-CH-Viewport-Height")
+	if width > 0 {
+		if height > 0 && height > width {
+			return "mobile"
+		}
+		if width < mobileViewportWidth {
+			return "mobile"
+		}
+		return "pc"
+	}
+
+	// User-Agent 关键字匹配
+	ua := strings.ToLower(header.Get("User-Agent"))
+	for _, keyword := range tabletUAKeywords {
+		if strings.Contains(ua, keyword) {
+			return "pc"
+		}
+	}
+	// Android 手机的 User-Agent 带 Mobile，平板不带
+	if strings.Contains(ua, "android") && !strings.Contains(ua, "mobile") {
+		return "pc"
+	}
+	for _, keyword := range mobileUAKeywords {
+		if strings.Contains(ua, keyword) {
+			return "mobile"
+		}
+	}
+
+	return "pc"
+}
+
+// 按顺序读取第一个合法的视口尺寸提示
+func parseViewportHint(header http.Header, names ...string) int {
+	for _, name := range names {
+		value := strings.TrimSpace(header.Get(name))
+		if value == "" {
+			continue
+		}
+		// 视口尺寸可能为小数
+		if f, err := strconv.ParseFloat(value, 64); err == nil && f > 0 {
+			return int(f)
+		}
+	}
+	return 0
+}
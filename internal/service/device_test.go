@@ -0,0 +1,47 @@
+package service
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestDetectDeviceType(t *testing.T) {
+	const (
+		iphoneUA        = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Mobile/15E148 Safari/604.1"
+		ipadUA          = "Mozilla/5.0 (iPad; CPU OS 17_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Mobile/15E148 Safari/604.1"
+		androidPhoneUA  = "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Mobile Safari/537.36"
+		androidTabletUA = "Mozilla/5.0 (Linux; Android 13; SM-X700) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+		desktopUA       = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+	)
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    string
+	}{
+		{"empty", nil, "pc"},
+		{"client hint mobile", map[string]string{"Sec-CH-UA-Mobile": "?1", "User-Agent": desktopUA}, "mobile"},
+		{"client hint desktop", map[string]string{"Sec-CH-UA-Mobile": "?0", "User-Agent": androidPhoneUA}, "pc"},
+		{"client hint before viewport", map[string]string{"Sec-CH-UA-Mobile": "?0", "Sec-CH-Viewport-Width": "390"}, "pc"},
+		{"narrow viewport", map[string]string{"Sec-CH-Viewport-Width": "390", "User-Agent": desktopUA}, "mobile"},
+		{"portrait viewport", map[string]string{"Sec-CH-Viewport-Width": "1024", "Sec-CH-Viewport-Height": "1366"}, "mobile"},
+		{"wide viewport", map[string]string{"Viewport-Width": "1280.5", "User-Agent": iphoneUA}, "pc"},
+		{"invalid viewport falls back to ua", map[string]string{"Sec-CH-Viewport-Width": "abc", "User-Agent": iphoneUA}, "mobile"},
+		{"iphone", map[string]string{"User-Agent": iphoneUA}, "mobile"},
+		{"ipad", map[string]string{"User-Agent": ipadUA}, "pc"},
+		{"android phone", map[string]string{"User-Agent": androidPhoneUA}, "mobile"},
+		{"android tablet", map[string]string{"User-Agent": androidTabletUA}, "pc"},
+		{"desktop", map[string]string{"User-Agent": desktopUA}, "pc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			for name, value := range tt.headers {
+				header.Set(name, value)
+			}
+			if got := DetectDeviceType(header); got != tt.want {
+				t.Errorf("DetectDeviceType() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
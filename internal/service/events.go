@@ -0,0 +1,172 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/TXM983/wallpaper-api-v1/internal/logger"
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+// EventType 壁纸库事件类型
+type EventType string
+
+const (
+	EventWallpaperCreated EventType = "wallpaper.created" // 上传壁纸
+	EventWallpaperDeleted EventType = "wallpaper.deleted" // 删除壁纸
+	EventCacheRebuilt     EventType = "cache.rebuilt"     // 重建缓存
+)
+
+// LibraryEventChannel 壁纸库事件频道，所有实例共享
+const LibraryEventChannel = "wallpaper_events"
+
+// 订阅者默认缓冲区大小
+const defaultSubscriptionBuffer = 16
+
+// LibraryEvent 壁纸库变更事件
+type LibraryEvent struct {
+	ID         string    `json:"id"`
+	Type       EventType `json:"type"`
+	DeviceType string    `json:"deviceType"`
+	Files      []string  `json:"files,omitempty"`      // 涉及的文件名（上传/删除）
+	Count      int       `json:"count,omitempty"`      // 重建后的壁纸数量
+	Generation int64     `json:"generation,omitempty"` // 重建后的壁纸库版本号
+	Timestamp  int64     `json:"timestamp"`
+}
+
+// NewLibraryEvent 创建壁纸库事件
+func NewLibraryEvent(eventType EventType, deviceType string, files ...string) LibraryEvent {
+	return LibraryEvent{
+		ID:         uuid.New().String(),
+		Type:       eventType,
+		DeviceType: deviceType,
+		Files:      files,
+		Timestamp:  time.Now().Unix(),
+	}
+}
+
+// EventSubscription 进程内的事件订阅，事件从 C 中读取
+type EventSubscription struct {
+	C     <-chan LibraryEvent
+	ch    chan LibraryEvent
+	types map[EventType]bool
+	bus   *EventBus
+	once  sync.Once
+}
+
+// Close 取消订阅
+func (s *EventSubscription) Close() {
+	s.once.Do(func() {
+		s.bus.mu.Lock()
+		delete(s.bus.subscribers, s)
+		s.bus.mu.Unlock()
+		close(s.ch)
+	})
+}
+
+// 判断订阅者是否关心该事件类型
+func (s *EventSubscription) accepts(eventType EventType) bool {
+	return len(s.types) == 0 || s.types[eventType]
+}
+
+// EventBus 基于 Redis Pub/Sub 的壁纸库事件总线
+// 每个实例只持有一个 Redis 订阅连接，收到的事件再分发给进程内的订阅者
+type EventBus struct {
+	rdb         *redis.Client
+	mu          sync.RWMutex
+	subscribers map[*EventSubscription]struct{}
+}
+
+// NewEventBus 创建事件总线
+func NewEventBus(rdb *redis.Client) *EventBus {
+	return &EventBus{
+		rdb:         rdb,
+		subscribers: make(map[*EventSubscription]struct{}),
+	}
+}
+
+// Publish 发布事件到 Redis，所有实例（包括当前实例）的订阅者都会收到
+func (b *EventBus) Publish(ctx context.Context, event LibraryEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %v", err)
+	}
+
+	log := logger.FromContext(ctx).WithField(logger.FieldDeviceType, event.DeviceType)
+	if err := b.rdb.Publish(ctx, LibraryEventChannel, payload).Err(); err != nil {
+		log.WithError(err).Errorf("Error publishing event %s", event.Type)
+		return err
+	}
+
+	log.Infof("Published event %s (%s)", event.Type, event.ID)
+	return nil
+}
+
+// Subscribe 订阅指定类型的事件，不传类型则订阅全部；调用方负责 Close
+func (b *EventBus) Subscribe(types ...EventType) *EventSubscription {
+	ch := make(chan LibraryEvent, defaultSubscriptionBuffer)
+	sub := &EventSubscription{
+		C:     ch,
+		ch:    ch,
+		types: make(map[EventType]bool, len(types)),
+		bus:   b,
+	}
+	for _, t := range types {
+		sub.types[t] = true
+	}
+
+	b.mu.Lock()
+	b.subscribers[sub] = struct{}{}
+	b.mu.Unlock()
+
+	return sub
+}
+
+// Run 订阅 Redis 频道并分发事件，直到 ctx 结束
+func (b *EventBus) Run(ctx context.Context) {
+	pubsub := b.rdb.Subscribe(ctx, LibraryEventChannel)
+	defer pubsub.Close()
+
+	logger.LogInfo("Event bus subscribed to channel %s", LibraryEventChannel)
+
+	messages := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			logger.LogInfo("Event bus stopped")
+			return
+		case msg, ok := <-messages:
+			if !ok {
+				return
+			}
+
+			var event LibraryEvent
+			if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+				logger.LogError("Error decoding event from channel %s: %v", msg.Channel, err)
+				continue
+			}
+			b.dispatch(event)
+		}
+	}
+}
+
+// 分发事件给进程内订阅者，订阅者处理过慢时丢弃事件，避免阻塞总线
+func (b *EventBus) dispatch(event LibraryEvent) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for sub := range b.subscribers {
+		if !sub.accepts(event.Type) {
+			continue
+		}
+		select {
+		case sub.ch <- event:
+		default:
+			logger.LogError("Event subscriber buffer full, dropped event %s (%s)", event.Type, event.ID)
+		}
+	}
+}
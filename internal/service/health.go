@@ -0,0 +1,189 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+	"github.com/go-redis/redis/v8"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// 健康检查结果
+const (
+	HealthStatusOK   = "ok"
+	HealthStatusFail = "fail"
+	// 正在退出，不再接收新流量
+	HealthStatusDraining = "draining"
+)
+
+// 就绪检查的依赖
+const (
+	HealthCheckRedis   = "redis"
+	HealthCheckStorage = "storage"
+	HealthCheckCache   = "cache"
+)
+
+// 就绪检查结果的缓存时间，避免频繁请求 /readyz 时每次都访问 OSS 和 Redis
+const readinessCacheTTL = time.Second
+
+// DependencyStatus 单个依赖的检查结果
+type DependencyStatus struct {
+	Status    string      `json:"status"`
+	LatencyMs float64     `json:"latencyMs"`
+	Error     string      `json:"error,omitempty"`
+	Details   interface{} `json:"details,omitempty"`
+}
+
+// ReadinessReport 就绪检查结果，任一依赖失败时整体为 fail
+type ReadinessReport struct {
+	Status string                      `json:"status"`
+	Checks map[string]DependencyStatus `json:"checks"`
+}
+
+// CategoryCacheStatus 分类的缓存情况
+type CategoryCacheStatus struct {
+	Library int64 `json:"library"` // 壁纸列表数量，为 0 时该分类无法返回壁纸
+	Cached  int64 `json:"cached"`  // 随机缓存剩余数量，取空后按需填充
+}
+
+// HealthChecker 检查 Redis、对象存储和各分类缓存
+type HealthChecker struct {
+	rdb         *redis.Client
+	bucket      *oss.Bucket
+	deviceTypes []string
+	timeout     time.Duration
+	cacheTTL    time.Duration
+	checks      map[string]func(context.Context) (interface{}, error)
+
+	mu        sync.Mutex // 同一时间只执行一次检查，其余请求等待并使用其结果
+	last      *ReadinessReport
+	checkedAt time.Time
+}
+
+// NewHealthChecker 创建健康检查，timeout 为单个依赖的检查超时
+func NewHealthChecker(rdb *redis.Client, bucket *oss.Bucket, deviceTypes []string, timeout time.Duration) *HealthChecker {
+	if timeout <= 0 {
+		timeout = 2 * time.Second
+	}
+	h := &HealthChecker{rdb: rdb, bucket: bucket, deviceTypes: deviceTypes, timeout: timeout, cacheTTL: readinessCacheTTL}
+	h.checks = map[string]func(context.Context) (interface{}, error){
+		HealthCheckRedis:   h.checkRedis,
+		HealthCheckStorage: h.checkStorage,
+		HealthCheckCache:   h.checkCache,
+	}
+	return h
+}
+
+// Ready 返回就绪检查结果，cacheTTL 内的重复请求直接使用上一次的结果
+// 检查不随单个请求取消而中断，请求上下文只用于 Span
+func (h *HealthChecker) Ready(ctx context.Context) *ReadinessReport {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.last != nil && time.Since(h.checkedAt) < h.cacheTTL {
+		return h.last
+	}
+	h.last = h.check(trace.ContextWithSpan(context.Background(), trace.SpanFromContext(ctx)))
+	h.checkedAt = time.Now()
+	return h.last
+}
+
+// 并发检查全部依赖
+func (h *HealthChecker) check(ctx context.Context) *ReadinessReport {
+	report := &ReadinessReport{Status: HealthStatusOK, Checks: make(map[string]DependencyStatus, len(h.checks))}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range h.checks {
+		wg.Add(1)
+		go func(name string, check func(context.Context) (interface{}, error)) {
+			defer wg.Done()
+			status := h.run(ctx, check)
+			mu.Lock()
+			report.Checks[name] = status
+			if status.Status != HealthStatusOK {
+				report.Status = HealthStatusFail
+			}
+			mu.Unlock()
+		}(name, check)
+	}
+	wg.Wait()
+	return report
+}
+
+// 执行单个检查并计时，超时后不再等待（OSS SDK 不支持 context）
+func (h *HealthChecker) run(ctx context.Context, check func(context.Context) (interface{}, error)) DependencyStatus {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	type result struct {
+		details interface{}
+		err     error
+	}
+	done := make(chan result, 1)
+	start := time.Now()
+	go func() {
+		details, err := check(ctx)
+		done <- result{details, err}
+	}()
+
+	var r result
+	select {
+	case r = <-done:
+	case <-ctx.Done():
+		r.err = fmt.Errorf("timed out after %s", h.timeout)
+	}
+
+	status := DependencyStatus{
+		Status:    HealthStatusOK,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+		Details:   r.details,
+	}
+	if r.err != nil {
+		status.Status = HealthStatusFail
+		status.Error = r.err.Error()
+	}
+	return status
+}
+
+func (h *HealthChecker) checkRedis(ctx context.Context) (interface{}, error) {
+	return nil, h.rdb.Ping(ctx).Err()
+}
+
+// 列出一个对象，校验存储桶可访问和凭据有效
+func (h *HealthChecker) checkStorage(ctx context.Context) (interface{}, error) {
+	return nil, storageCall(ctx, "list_objects", h.bucket, "", func() error {
+		_, err := h.bucket.ListObjects(oss.MaxKeys(1))
+		return err
+	})
+}
+
+// 各分类的壁纸列表不能为空
+func (h *HealthChecker) checkCache(ctx context.Context) (interface{}, error) {
+	pipe := h.rdb.Pipeline()
+	libraries := make([]*redis.IntCmd, len(h.deviceTypes))
+	caches := make([]*redis.IntCmd, len(h.deviceTypes))
+	for i, deviceType := range h.deviceTypes {
+		libraries[i] = pipe.LLen(ctx, "wallpaper:"+deviceType)
+		caches[i] = pipe.LLen(ctx, "wallpaper:cache:"+deviceType)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	details := make(map[string]CategoryCacheStatus, len(h.deviceTypes))
+	var empty []string
+	for i, deviceType := range h.deviceTypes {
+		details[deviceType] = CategoryCacheStatus{Library: libraries[i].Val(), Cached: caches[i].Val()}
+		if libraries[i].Val() == 0 {
+			empty = append(empty, deviceType)
+		}
+	}
+	if len(empty) > 0 {
+		return details, errors.New("empty categories: " + strings.Join(empty, ", "))
+	}
+	return details, nil
+}
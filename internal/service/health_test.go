@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHealthCheckerCachesReport(t *testing.T) {
+	mr, rdb := newTestRedis(t)
+	mr.RPush("wallpaper:pc", "a.webp")
+
+	h := NewHealthChecker(rdb, nil, []string{"pc"}, time.Second)
+	var storageChecks atomic.Int32
+	h.checks[HealthCheckStorage] = func(context.Context) (interface{}, error) {
+		storageChecks.Add(1)
+		time.Sleep(10 * time.Millisecond)
+		return nil, errors.New("bucket unavailable")
+	}
+
+	// 并发请求只执行一次检查
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			report := h.Ready(context.Background())
+			if report.Status != HealthStatusFail || report.Checks[HealthCheckRedis].Status != HealthStatusOK {
+				t.Errorf("report = %+v", report)
+			}
+		}()
+	}
+	wg.Wait()
+	if got := storageChecks.Load(); got != 1 {
+		t.Fatalf("storage checked %d times, want 1", got)
+	}
+
+	// 请求取消不影响检查结果
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	h.checkedAt = time.Time{}
+	if report := h.Ready(ctx); report.Checks[HealthCheckRedis].Status != HealthStatusOK {
+		t.Errorf("redis check with canceled request = %+v", report.Checks[HealthCheckRedis])
+	}
+	if got := storageChecks.Load(); got != 2 {
+		t.Errorf("storage checked %d times after cache expired, want 2", got)
+	}
+}
+
+func TestHealthCheckerCache(t *testing.T) {
+	tests := []struct {
+		name       string
+		libraries  map[string]int
+		wantStatus string
+	}{
+		{name: "all categories", libraries: map[string]int{"pc": 2, "mobile": 1}, wantStatus: HealthStatusOK},
+		{name: "empty category", libraries: map[string]int{"pc": 2}, wantStatus: HealthStatusFail},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mr, rdb := newTestRedis(t)
+			for deviceType, count := range tt.libraries {
+				for i := 0; i < count; i++ {
+					mr.RPush("wallpaper:"+deviceType, "a.webp")
+				}
+			}
+			h := NewHealthChecker(rdb, nil, []string{"pc", "mobile"}, time.Second)
+			h.checks[HealthCheckStorage] = func(context.Context) (interface{}, error) { return nil, nil }
+
+			report := h.Ready(context.Background())
+			if report.Status != tt.wantStatus || report.Checks[HealthCheckCache].Status != tt.wantStatus {
+				t.Errorf("report = %+v, want %s", report, tt.wantStatus)
+			}
+			details := report.Checks[HealthCheckCache].Details.(map[string]CategoryCacheStatus)
+			if details["pc"].Library != 2 {
+				t.Errorf("pc details = %+v", details["pc"])
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// HotlinkRules 一组允许和禁止嵌入的域名
+// 域名规则：example.com 只匹配该域名，*.example.com 匹配其子域名，* 匹配全部
+type HotlinkRules struct {
+	Allow []string
+	Deny  []string
+}
+
+// HotlinkOptions 防盗链配置
+type HotlinkOptions struct {
+	Enabled           bool
+	AllowEmptyReferer bool // 是否允许没有 Referer/Origin 的请求（浏览器直接访问、App、curl 等）
+	Global            HotlinkRules
+	Categories        map[string]HotlinkRules // 按设备类型覆盖
+}
+
+// HotlinkPolicy 根据 Referer/Origin 判断是否允许嵌入
+type HotlinkPolicy struct {
+	opts HotlinkOptions
+}
+
+// NewHotlinkPolicy 创建防盗链策略，域名统一转为小写
+func NewHotlinkPolicy(opts HotlinkOptions) *HotlinkPolicy {
+	opts.Global = normalizeHotlinkRules(opts.Global)
+	categories := make(map[string]HotlinkRules, len(opts.Categories))
+	for category, rules := range opts.Categories {
+		categories[category] = normalizeHotlinkRules(rules)
+	}
+	opts.Categories = categories
+	return &HotlinkPolicy{opts: opts}
+}
+
+func normalizeHotlinkRules(rules HotlinkRules) HotlinkRules {
+	return HotlinkRules{Allow: normalizeDomains(rules.Allow), Deny: normalizeDomains(rules.Deny)}
+}
+
+func normalizeDomains(domains []string) []string {
+	var result []string
+	for _, d := range domains {
+		if d = strings.ToLower(strings.TrimSpace(d)); d != "" {
+			result = append(result, d)
+		}
+	}
+	return result
+}
+
+// Enabled 是否开启防盗链
+func (p *HotlinkPolicy) Enabled() bool {
+	return p.opts.Enabled
+}
+
+// RefererHost 从 Referer 或 Origin 中解析来源域名，均不存在时返回空字符串
+func RefererHost(header http.Header) string {
+	for _, name := range []string{"Referer", "Origin"} {
+		value := strings.TrimSpace(header.Get(name))
+		if value == "" || value == "null" {
+			continue
+		}
+		if u, err := url.Parse(value); err == nil && u.Hostname() != "" {
+			return strings.ToLower(u.Hostname())
+		}
+	}
+	return ""
+}
+
+// Allow 判断来源是否允许访问指定分类的壁纸，key 为当前请求使用的 API Key（可为 nil），selfHost 为本站域名
+// 任一禁止列表命中即拒绝；之后本站页面始终允许；允许列表按 API Key > 分类 > 全局 取第一个非空的生效，均为空时允许
+func (p *HotlinkPolicy) Allow(header http.Header, category string, key *APIKey, selfHost string) (bool, string) {
+	host := RefererHost(header)
+	if !p.opts.Enabled {
+		return true, host
+	}
+	if host == "" {
+		return p.opts.AllowEmptyReferer, host
+	}
+
+	categoryRules := p.opts.Categories[category]
+	var keyRules HotlinkRules
+	if key != nil {
+		keyRules = normalizeHotlinkRules(HotlinkRules{Allow: key.AllowedReferers, Deny: key.DeniedReferers})
+	}
+
+	for _, deny := range [][]string{p.opts.Global.Deny, categoryRules.Deny, keyRules.Deny} {
+		if matchDomain(host, deny) {
+			return false, host
+		}
+	}
+	if selfHost != "" && host == strings.ToLower(selfHost) {
+		return true, host
+	}
+
+	for _, allow := range [][]string{keyRules.Allow, categoryRules.Allow, p.opts.Global.Allow} {
+		if len(allow) > 0 {
+			return matchDomain(host, allow), host
+		}
+	}
+	return true, host
+}
+
+// 域名是否匹配任一规则
+func matchDomain(host string, patterns []string) bool {
+	for _, pattern := range patterns {
+		switch {
+		case pattern == "*":
+			return true
+		case strings.HasPrefix(pattern, "*."):
+			if strings.HasSuffix(host, pattern[1:]) {
+				return true
+			}
+		case host == pattern:
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestMatchDomain(t *testing.T) {
+	tests := []struct {
+		host     string
+		patterns []string
+		want     bool
+	}{
+		{"example.com", []string{"example.com"}, true},
+		{"www.example.com", []string{"example.com"}, false},
+		{"www.example.com", []string{"*.example.com"}, true},
+		{"a.b.example.com", []string{"*.example.com"}, true},
+		{"example.com", []string{"*.example.com"}, false},
+		{"evilexample.com", []string{"*.example.com"}, false},
+		{"example.com.evil.net", []string{"*.example.com", "example.com"}, false},
+		{"anything.net", []string{"*"}, true},
+		{"example.org", []string{"example.com", "example.org"}, true},
+		{"example.com", nil, false},
+	}
+	for _, tt := range tests {
+		if got := matchDomain(tt.host, tt.patterns); got != tt.want {
+			t.Errorf("matchDomain(%q, %v) = %v, want %v", tt.host, tt.patterns, got, tt.want)
+		}
+	}
+}
+
+func TestRefererHost(t *testing.T) {
+	tests := []struct {
+		referer string
+		origin  string
+		want    string
+	}{
+		{referer: "https://Blog.Example.com/post?id=1", want: "blog.example.com"},
+		{referer: "https://example.com:8443/", want: "example.com"},
+		{origin: "https://app.example.net", want: "app.example.net"},
+		{referer: "https://a.example.com/", origin: "https://b.example.com", want: "a.example.com"},
+		{origin: "null", want: ""},
+		{referer: "not a url", want: ""},
+		{want: ""},
+	}
+	for _, tt := range tests {
+		header := http.Header{}
+		if tt.referer != "" {
+			header.Set("Referer", tt.referer)
+		}
+		if tt.origin != "" {
+			header.Set("Origin", tt.origin)
+		}
+		if got := RefererHost(header); got != tt.want {
+			t.Errorf("RefererHost(referer=%q, origin=%q) = %q, want %q", tt.referer, tt.origin, got, tt.want)
+		}
+	}
+}
+
+func TestHotlinkPolicyAllow(t *testing.T) {
+	policy := NewHotlinkPolicy(HotlinkOptions{
+		Enabled:           true,
+		AllowEmptyReferer: false,
+		Global:            HotlinkRules{Allow: []string{" Example.com ", "*.example.com"}, Deny: []string{"bad.example.com"}},
+		Categories: map[string]HotlinkRules{
+			"mobile": {Allow: []string{"m.partner.net"}},
+			"pc":     {Deny: []string{"*.example.com"}},
+		},
+	})
+	keyed := &APIKey{AllowedReferers: []string{"*.customer.io"}, DeniedReferers: []string{"api.example.com"}}
+
+	tests := []struct {
+		name     string
+		referer  string
+		category string
+		key      *APIKey
+		selfHost string
+		want     bool
+	}{
+		{name: "global allow", referer: "https://example.com/", want: true},
+		{name: "global allow subdomain", referer: "https://www.example.com/", want: true},
+		{name: "not in global allow", referer: "https://other.net/", want: false},
+		{name: "global deny", referer: "https://bad.example.com/", want: false},
+		{name: "empty referer", referer: "", want: false},
+		{name: "category allow replaces global", referer: "https://m.partner.net/", category: "mobile", want: true},
+		{name: "category allow excludes global", referer: "https://example.com/", category: "mobile", want: false},
+		{name: "category deny", referer: "https://www.example.com/", category: "pc", want: false},
+		{name: "key allow replaces category", referer: "https://app.customer.io/", category: "mobile", key: keyed, want: true},
+		{name: "key deny", referer: "https://api.example.com/", key: keyed, want: false},
+		{name: "self host bypasses allow list", referer: "https://wallpaper.local/", selfHost: "Wallpaper.Local", want: true},
+		{name: "deny applies to self host", referer: "https://bad.example.com/", selfHost: "bad.example.com", want: false},
+		{name: "category deny applies to self host", referer: "https://img.example.com/", category: "pc", selfHost: "img.example.com", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			if tt.referer != "" {
+				header.Set("Referer", tt.referer)
+			}
+			if got, host := policy.Allow(header, tt.category, tt.key, tt.selfHost); got != tt.want {
+				t.Errorf("Allow() = %v (host %q), want %v", got, host, tt.want)
+			}
+		})
+	}
+}
+
+func TestHotlinkPolicyDefaults(t *testing.T) {
+	header := http.Header{}
+	header.Set("Referer", "https://anywhere.net/")
+
+	disabled := NewHotlinkPolicy(HotlinkOptions{Global: HotlinkRules{Deny: []string{"*"}}})
+	if allowed, _ := disabled.Allow(header, "pc", nil, ""); !allowed {
+		t.Error("disabled policy rejected request")
+	}
+
+	open := NewHotlinkPolicy(HotlinkOptions{Enabled: true, AllowEmptyReferer: true})
+	if allowed, _ := open.Allow(header, "pc", nil, ""); !allowed {
+		t.Error("policy without allow lists rejected request")
+	}
+	if allowed, _ := open.Allow(http.Header{}, "pc", nil, ""); !allowed {
+		t.Error("empty referer rejected with allow_empty_referer")
+	}
+}
@@ -0,0 +1,423 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TXM983/wallpaper-api-v1/internal/logger"
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+// JobStatus 任务状态
+type JobStatus string
+
+const (
+	JobQueued    JobStatus = "queued"
+	JobRunning   JobStatus = "running"
+	JobSucceeded JobStatus = "succeeded"
+	JobFailed    JobStatus = "failed"
+)
+
+// 任务相关 Redis Key 与参数
+const (
+	jobKeyPrefix         = "job:"           // 任务详情
+	rebuildLockKeyPrefix = "lock:rebuild:"  // 每个设备类型的重建锁，值为任务 ID
+	jobTTL               = 24 * time.Hour   // 任务详情保留时间
+	rebuildLockTTL       = 2 * time.Minute  // 重建锁过期时间，任务执行中持续续期
+	rebuildLockRefresh   = 30 * time.Second // 重建锁续期间隔
+)
+
+// ErrJobNotFound 任务不存在或已过期
+var ErrJobNotFound = errors.New("job not found")
+
+// ErrJobManagerStopped 服务正在退出，不再接收新任务
+var ErrJobManagerStopped = errors.New("job manager is stopped")
+
+// 退出时被中断的任务记录的错误
+var errJobInterrupted = errors.New("interrupted by shutdown")
+
+// RebuildInProgressError 该设备类型已有重建任务在执行
+type RebuildInProgressError struct {
+	DeviceType string
+	JobID      string
+}
+
+func (e *RebuildInProgressError) Error() string {
+	return fmt.Sprintf("rebuild for device type %s is already in progress (job %s)", e.DeviceType, e.JobID)
+}
+
+// 续期锁：只有持有者才能续期
+var refreshLockScript = redis.NewScript(`
+    if redis.call("GET", KEYS[1]) == ARGV[1] then
+        return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+    else
+        return 0
+    end
+`)
+
+// 获取设备类型的重建锁并持续续期，供需要与重建任务互斥的操作（如对账）使用
+// 锁已被占用时返回 *RebuildInProgressError；返回的函数停止续期并释放锁
+func acquireRebuildLock(ctx context.Context, rdb *redis.Client, deviceType string, owner string) (func(), error) {
+	key := rebuildLockKeyPrefix + deviceType
+	acquired, err := rdb.SetNX(ctx, key, owner, rebuildLockTTL).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire rebuild lock for %s: %v", deviceType, err)
+	}
+	if !acquired {
+		running, _ := rdb.Get(ctx, key).Result()
+		return nil, &RebuildInProgressError{DeviceType: deviceType, JobID: running}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(rebuildLockRefresh)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				refreshLockScript.Run(ctx, rdb, []string{key}, owner, rebuildLockTTL.Milliseconds())
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		unlockScript.Run(context.Background(), rdb, []string{key}, owner)
+	}, nil
+}
+
+// JobProgress 单个设备类型的重建进度
+type JobProgress struct {
+	Status     JobStatus `json:"status"`
+	Listed     int       `json:"listed"`               // 已从 OSS 列举的壁纸数量
+	Count      int       `json:"count"`                // 重建后的壁纸数量
+	Generation int64     `json:"generation,omitempty"` // 重建后的壁纸库版本号
+	Error      string    `json:"error,omitempty"`
+}
+
+// RebuildJob 缓存重建任务
+type RebuildJob struct {
+	ID          string                  `json:"id"`
+	Status      JobStatus               `json:"status"`
+	DeviceTypes []string                `json:"deviceTypes"`
+	Progress    map[string]*JobProgress `json:"progress"`
+	Error       string                  `json:"error,omitempty"`
+	CreatedAt   int64                   `json:"createdAt"`
+	StartedAt   int64                   `json:"startedAt,omitempty"`
+	UpdatedAt   int64                   `json:"updatedAt"`
+	FinishedAt  int64                   `json:"finishedAt,omitempty"`
+}
+
+// 复制任务，返回给调用方的副本不受后台执行影响
+func (j *RebuildJob) clone() *RebuildJob {
+	copied := *j
+	copied.DeviceTypes = append([]string(nil), j.DeviceTypes...)
+	copied.Progress = make(map[string]*JobProgress, len(j.Progress))
+	for deviceType, progress := range j.Progress {
+		p := *progress
+		copied.Progress[deviceType] = &p
+	}
+	return &copied
+}
+
+// 执行中的任务，mu 保护 job，进度回调、续期协程和 Stop 并发访问
+type jobRun struct {
+	mu          sync.Mutex
+	job         *RebuildJob
+	interrupted bool // Stop 已将任务标记为失败，之后的更新不再保存
+}
+
+// JobManager 管理后台缓存重建任务
+type JobManager struct {
+	rdb     *redis.Client
+	notify  func(result *RebuildResult) // 每个设备类型重建成功后的回调
+	rebuild func(ctx context.Context, deviceType string, onProgress func(listed int)) (*RebuildResult, error)
+
+	ctx     context.Context // Stop 时取消，通知进行中的任务退出
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	mu      sync.Mutex
+	running map[string]*jobRun
+}
+
+// NewJobManager 创建任务管理器，notify 可为空
+func NewJobManager(rdb *redis.Client, bucket *oss.Bucket, notify func(result *RebuildResult)) *JobManager {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &JobManager{
+		rdb:    rdb,
+		notify: notify,
+		rebuild: func(ctx context.Context, deviceType string, onProgress func(listed int)) (*RebuildResult, error) {
+			return RebuildWallpaperCache(ctx, rdb, bucket, deviceType, onProgress)
+		},
+		ctx:     ctx,
+		cancel:  cancel,
+		running: make(map[string]*jobRun),
+	}
+}
+
+// Stop 通知进行中的任务退出并等待结束，需在关闭 Redis 之前调用
+// ctx 到期时仍未结束的任务直接标记为失败并释放重建锁，返回这些任务的 ID
+func (m *JobManager) Stop(ctx context.Context) error {
+	m.mu.Lock()
+	m.cancel()
+	m.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+
+	m.mu.Lock()
+	runs := make([]*jobRun, 0, len(m.running))
+	for _, run := range m.running {
+		runs = append(runs, run)
+	}
+	m.mu.Unlock()
+
+	ids := make([]string, 0, len(runs))
+	for _, run := range runs {
+		m.interrupt(run)
+		ids = append(ids, run.job.ID)
+	}
+	sort.Strings(ids)
+	return fmt.Errorf("rebuild jobs did not stop in time: %s", strings.Join(ids, ", "))
+}
+
+// 将未完成的设备类型标记为失败并释放其重建锁，之后后台执行的更新不再保存
+func (m *JobManager) interrupt(run *jobRun) {
+	run.mu.Lock()
+	defer run.mu.Unlock()
+	if run.interrupted {
+		return
+	}
+	run.interrupted = true
+
+	job := run.job
+	for _, deviceType := range job.DeviceTypes {
+		progress := job.Progress[deviceType]
+		if progress.Status == JobQueued || progress.Status == JobRunning {
+			progress.Status = JobFailed
+			progress.Error = errJobInterrupted.Error()
+			m.releaseLocks(context.Background(), job.ID, []string{deviceType})
+		}
+	}
+	job.Status = JobFailed
+	job.Error = errJobInterrupted.Error()
+	job.FinishedAt = time.Now().Unix()
+	if err := m.save(context.Background(), job); err != nil {
+		logger.LogError("Error saving interrupted job %s: %v", job.ID, err)
+	}
+	logger.LogInfo("Rebuild job %s interrupted by shutdown", job.ID)
+}
+
+// SubmitRebuild 提交重建任务，立即返回提交时的任务副本；任一设备类型已有任务在执行时返回 *RebuildInProgressError
+func (m *JobManager) SubmitRebuild(ctx context.Context, deviceTypes []string) (*RebuildJob, error) {
+	if m.ctx.Err() != nil {
+		return nil, ErrJobManagerStopped
+	}
+
+	now := time.Now().Unix()
+	job := &RebuildJob{
+		ID:          uuid.New().String(),
+		Status:      JobQueued,
+		DeviceTypes: deviceTypes,
+		Progress:    make(map[string]*JobProgress, len(deviceTypes)),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	for _, deviceType := range deviceTypes {
+		job.Progress[deviceType] = &JobProgress{Status: JobQueued}
+	}
+
+	// 为每个设备类型加锁，保证多实例下同一类型同时只有一个重建任务
+	var locked []string
+	for _, deviceType := range deviceTypes {
+		acquired, err := m.rdb.SetNX(ctx, rebuildLockKeyPrefix+deviceType, job.ID, rebuildLockTTL).Result()
+		if err != nil || !acquired {
+			m.releaseLocks(ctx, job.ID, locked)
+			if err != nil {
+				return nil, fmt.Errorf("failed to acquire rebuild lock for %s: %v", deviceType, err)
+			}
+			running, _ := m.rdb.Get(ctx, rebuildLockKeyPrefix+deviceType).Result()
+			return nil, &RebuildInProgressError{DeviceType: deviceType, JobID: running}
+		}
+		locked = append(locked, deviceType)
+	}
+
+	if err := m.save(ctx, job); err != nil {
+		m.releaseLocks(ctx, job.ID, locked)
+		return nil, err
+	}
+
+	// 在 Stop 之后提交的任务不再执行，直接释放锁
+	m.mu.Lock()
+	if m.ctx.Err() != nil {
+		m.mu.Unlock()
+		m.releaseLocks(context.Background(), job.ID, locked)
+		job.Status = JobFailed
+		job.Error = errJobInterrupted.Error()
+		job.FinishedAt = time.Now().Unix()
+		m.save(context.Background(), job)
+		return nil, ErrJobManagerStopped
+	}
+	run := &jobRun{job: job}
+	m.running[job.ID] = run
+	m.wg.Add(1)
+	m.mu.Unlock()
+
+	// 后台执行会修改 job，返回副本避免与调用方序列化响应并发读写
+	submitted := job.clone()
+	go func() {
+		defer func() {
+			m.mu.Lock()
+			delete(m.running, job.ID)
+			m.mu.Unlock()
+			m.wg.Done()
+		}()
+		m.run(m.ctx, run)
+	}()
+	return submitted, nil
+}
+
+// GetJob 查询任务
+func (m *JobManager) GetJob(ctx context.Context, id string) (*RebuildJob, error) {
+	data, err := m.rdb.Get(ctx, jobKeyPrefix+id).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrJobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var job RebuildJob
+	if err := json.Unmarshal([]byte(data), &job); err != nil {
+		return nil, fmt.Errorf("failed to decode job %s: %v", id, err)
+	}
+	return &job, nil
+}
+
+// 保存任务详情
+func (m *JobManager) save(ctx context.Context, job *RebuildJob) error {
+	job.UpdatedAt = time.Now().Unix()
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	if err := m.rdb.Set(ctx, jobKeyPrefix+job.ID, data, jobTTL).Err(); err != nil {
+		return fmt.Errorf("failed to save job %s: %v", job.ID, err)
+	}
+	return nil
+}
+
+// 释放本任务持有的锁
+func (m *JobManager) releaseLocks(ctx context.Context, jobID string, deviceTypes []string) {
+	for _, deviceType := range deviceTypes {
+		unlockScript.Run(ctx, m.rdb, []string{rebuildLockKeyPrefix + deviceType}, jobID)
+	}
+}
+
+// 依次重建各设备类型，ctx 取消时未完成的设备类型标记为失败
+// 任务状态和锁使用独立的 context 保存和释放，退出时也能写入最终状态
+func (m *JobManager) run(ctx context.Context, run *jobRun) {
+	job := run.job
+	update := func(fn func()) {
+		run.mu.Lock()
+		defer run.mu.Unlock()
+		if run.interrupted {
+			return
+		}
+		fn()
+		if err := m.save(context.Background(), job); err != nil {
+			logger.LogError("Error saving job %s: %v", job.ID, err)
+		}
+	}
+
+	// 任务执行期间持续续期重建锁
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(rebuildLockRefresh)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				for _, deviceType := range job.DeviceTypes {
+					refreshLockScript.Run(context.Background(), m.rdb, []string{rebuildLockKeyPrefix + deviceType}, job.ID, rebuildLockTTL.Milliseconds())
+				}
+			}
+		}
+	}()
+
+	update(func() {
+		job.Status = JobRunning
+		job.StartedAt = time.Now().Unix()
+	})
+	logger.LogInfo("Rebuild job %s started for %v", job.ID, job.DeviceTypes)
+
+	for _, deviceType := range job.DeviceTypes {
+		progress := job.Progress[deviceType]
+
+		var (
+			result *RebuildResult
+			err    error
+		)
+		if err = ctx.Err(); err == nil {
+			update(func() { progress.Status = JobRunning })
+			result, err = m.rebuild(ctx, deviceType, func(listed int) {
+				update(func() { progress.Listed = listed })
+			})
+		}
+
+		// 当前类型完成后立即释放锁，不阻塞该类型的新任务
+		m.releaseLocks(context.Background(), job.ID, []string{deviceType})
+
+		if err != nil {
+			if ctx.Err() != nil {
+				err = errJobInterrupted
+			}
+			logger.LogError("Rebuild job %s failed for %s: %v", job.ID, deviceType, err)
+			update(func() {
+				progress.Status = JobFailed
+				progress.Error = err.Error()
+				job.Status = JobFailed
+				job.Error = fmt.Sprintf("%s: %v", deviceType, err)
+			})
+			continue
+		}
+
+		update(func() {
+			progress.Status = JobSucceeded
+			progress.Count = result.Count
+			progress.Generation = result.Generation
+		})
+		if m.notify != nil {
+			m.notify(result)
+		}
+	}
+
+	update(func() {
+		if job.Status != JobFailed {
+			job.Status = JobSucceeded
+		}
+		job.FinishedAt = time.Now().Unix()
+		logger.LogInfo("Rebuild job %s finished with status %s", job.ID, job.Status)
+	})
+}
@@ -0,0 +1,169 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+// 等待任务结束
+func waitJob(t *testing.T, m *JobManager, id string) *RebuildJob {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		job, err := m.GetJob(context.Background(), id)
+		if err == nil && job.FinishedAt != 0 {
+			return job
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("job %s did not finish", id)
+	return nil
+}
+
+// 提交后立即序列化返回的任务，与后台执行并发，需配合 -race 运行
+func TestSubmitRebuildReturnsSnapshot(t *testing.T) {
+	_, rdb := newTestRedis(t)
+	m := NewJobManager(rdb, nil, nil)
+	m.rebuild = func(ctx context.Context, deviceType string, onProgress func(listed int)) (*RebuildResult, error) {
+		for listed := 1000; listed <= 5000; listed += 1000 {
+			onProgress(listed)
+		}
+		return &RebuildResult{DeviceType: deviceType, Count: 5000, Generation: 1}, nil
+	}
+
+	job, err := m.SubmitRebuild(context.Background(), []string{"pc", "mobile"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		if _, err := json.Marshal(job); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if job.Status != JobQueued || job.Progress["pc"].Status != JobQueued {
+		t.Errorf("returned job status = %s/%s, want queued", job.Status, job.Progress["pc"].Status)
+	}
+
+	finished := waitJob(t, m, job.ID)
+	if finished.Status != JobSucceeded {
+		t.Errorf("job status = %s, want %s", finished.Status, JobSucceeded)
+	}
+	for _, deviceType := range []string{"pc", "mobile"} {
+		progress := finished.Progress[deviceType]
+		if progress.Status != JobSucceeded || progress.Listed != 5000 || progress.Count != 5000 {
+			t.Errorf("%s progress = %+v", deviceType, progress)
+		}
+	}
+}
+
+func TestSubmitRebuildInProgress(t *testing.T) {
+	_, rdb := newTestRedis(t)
+	m := NewJobManager(rdb, nil, nil)
+	release := make(chan struct{})
+	m.rebuild = func(ctx context.Context, deviceType string, onProgress func(listed int)) (*RebuildResult, error) {
+		<-release
+		return &RebuildResult{DeviceType: deviceType}, nil
+	}
+
+	job, err := m.SubmitRebuild(context.Background(), []string{"pc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = m.SubmitRebuild(context.Background(), []string{"mobile", "pc"})
+	inProgress, ok := err.(*RebuildInProgressError)
+	if !ok || inProgress.DeviceType != "pc" || inProgress.JobID != job.ID {
+		t.Fatalf("second submit error = %v, want RebuildInProgressError for pc", err)
+	}
+	// 失败时释放已获取的其他类型的锁
+	if exists := rdb.Exists(context.Background(), rebuildLockKeyPrefix+"mobile").Val(); exists != 0 {
+		t.Errorf("mobile rebuild lock not released")
+	}
+
+	close(release)
+	waitJob(t, m, job.ID)
+	if exists := rdb.Exists(context.Background(), rebuildLockKeyPrefix+"pc").Val(); exists != 0 {
+		t.Errorf("pc rebuild lock not released after job finished")
+	}
+}
+
+func TestJobManagerStop(t *testing.T) {
+	tests := []struct {
+		name    string
+		rebuild func(ctx context.Context, release <-chan struct{}) error
+		timeout time.Duration
+		wantErr bool
+	}{
+		{
+			name: "running job stops on cancel",
+			rebuild: func(ctx context.Context, release <-chan struct{}) error {
+				<-ctx.Done()
+				return ctx.Err()
+			},
+			timeout: 5 * time.Second,
+		},
+		{
+			name: "job ignoring cancel is interrupted",
+			rebuild: func(ctx context.Context, release <-chan struct{}) error {
+				<-release
+				return nil
+			},
+			timeout: 50 * time.Millisecond,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, rdb := newTestRedis(t)
+			m := NewJobManager(rdb, nil, nil)
+			started := make(chan struct{}, 1)
+			release := make(chan struct{})
+			m.rebuild = func(ctx context.Context, deviceType string, onProgress func(listed int)) (*RebuildResult, error) {
+				started <- struct{}{}
+				if err := tt.rebuild(ctx, release); err != nil {
+					return nil, err
+				}
+				return &RebuildResult{DeviceType: deviceType, Count: 1}, nil
+			}
+
+			job, err := m.SubmitRebuild(context.Background(), []string{"pc", "mobile"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			<-started
+
+			ctx, cancel := context.WithTimeout(context.Background(), tt.timeout)
+			defer cancel()
+			if err := m.Stop(ctx); (err != nil) != tt.wantErr {
+				t.Fatalf("Stop() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			// 未按时结束的任务在后台完成后也不能覆盖失败状态
+			close(release)
+			m.wg.Wait()
+
+			stopped, err := m.GetJob(context.Background(), job.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stopped.Status != JobFailed || stopped.FinishedAt == 0 {
+				t.Errorf("job = %+v, want failed", stopped)
+			}
+			for _, deviceType := range []string{"pc", "mobile"} {
+				if progress := stopped.Progress[deviceType]; progress.Status != JobFailed || progress.Error != errJobInterrupted.Error() {
+					t.Errorf("%s progress = %+v, want interrupted", deviceType, progress)
+				}
+				if rdb.Exists(context.Background(), rebuildLockKeyPrefix+deviceType).Val() != 0 {
+					t.Errorf("%s rebuild lock not released", deviceType)
+				}
+			}
+
+			if _, err := m.SubmitRebuild(context.Background(), []string{"pc"}); !errors.Is(err, ErrJobManagerStopped) {
+				t.Errorf("submit after stop error = %v, want ErrJobManagerStopped", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// 壁纸元数据 Hash（文件名 -> JSON）
+const wallpaperMetaPrefix = "wallpaper:meta:"
+
+// OSS 对象上记录上传者的自定义元数据名（x-oss-meta-uploader）
+const ossMetaUploader = "uploader"
+
+// WallpaperMeta 壁纸元数据
+type WallpaperMeta struct {
+	Uploader        string   `json:"uploader"`        // 上传者名称（邮箱、用户名或 Key 名称）
+	UploaderSubject string   `json:"uploaderSubject"` // 上传者唯一标识
+	UploaderMethod  string   `json:"uploaderMethod"`  // 上传者的认证方式
+	UploadedAt      int64    `json:"uploadedAt"`
+	Tags            []string `json:"tags,omitempty"`
+}
+
+// 单张壁纸的标签上限
+const maxWallpaperTags = 20
+
+// NewWallpaperMeta 根据操作者生成元数据，principal 为空时记为匿名
+func NewWallpaperMeta(principal *Principal) WallpaperMeta {
+	meta := WallpaperMeta{Uploader: "anonymous", UploadedAt: time.Now().Unix()}
+	if principal != nil {
+		meta.Uploader = principal.DisplayName()
+		meta.UploaderSubject = principal.Subject
+		meta.UploaderMethod = principal.Method
+	}
+	return meta
+}
+
+// SaveWallpaperMeta 保存壁纸元数据
+func SaveWallpaperMeta(ctx context.Context, rdb *redis.Client, deviceType string, fileName string, meta WallpaperMeta) error {
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	if err := rdb.HSet(ctx, wallpaperMetaPrefix+deviceType, fileName, data).Err(); err != nil {
+		return fmt.Errorf("failed to save wallpaper meta for '%s': %v", fileName, err)
+	}
+	return nil
+}
+
+// GetWallpaperMeta 查询壁纸元数据，未记录时返回 nil
+func GetWallpaperMeta(ctx context.Context, rdb *redis.Client, deviceType string, fileName string) (*WallpaperMeta, error) {
+	data, err := rdb.HGet(ctx, wallpaperMetaPrefix+deviceType, fileName).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var meta WallpaperMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, err
+	}
+	return &meta, nil
+}
+
+// ListWallpaperMeta 查询指定类型全部壁纸的元数据
+func ListWallpaperMeta(ctx context.Context, rdb *redis.Client, deviceType string) (map[string]WallpaperMeta, error) {
+	values, err := rdb.HGetAll(ctx, wallpaperMetaPrefix+deviceType).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	metas := make(map[string]WallpaperMeta, len(values))
+	for fileName, data := range values {
+		var meta WallpaperMeta
+		if json.Unmarshal([]byte(data), &meta) == nil {
+			metas[fileName] = meta
+		}
+	}
+	return metas, nil
+}
+
+// IsUploadedBy 是否由该操作者上传
+func (m *WallpaperMeta) IsUploadedBy(principal *Principal) bool {
+	return principal != nil && m.UploaderSubject != "" && m.UploaderSubject == principal.Subject && m.UploaderMethod == principal.Method
+}
+
+// SetWallpaperTags 设置壁纸标签（去重、转小写），未记录元数据的壁纸会新建记录
+func SetWallpaperTags(ctx context.Context, rdb *redis.Client, deviceType string, fileName string, tags []string) (*WallpaperMeta, error) {
+	var normalized []string
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	if len(normalized) > maxWallpaperTags {
+		return nil, fmt.Errorf("at most %d tags are allowed", maxWallpaperTags)
+	}
+
+	meta, err := GetWallpaperMeta(ctx, rdb, deviceType, fileName)
+	if err != nil {
+		return nil, err
+	}
+	if meta == nil {
+		meta = &WallpaperMeta{Uploader: "unknown"}
+	}
+	meta.Tags = normalized
+
+	if err := SaveWallpaperMeta(ctx, rdb, deviceType, fileName, *meta); err != nil {
+		return nil, err
+	}
+	return meta, nil
+}
+
+// DeleteWallpaperMeta 删除壁纸元数据，rdb 可以是事务管道
+func DeleteWallpaperMeta(ctx context.Context, rdb redis.Cmdable, deviceType string, fileNames ...string) error {
+	if len(fileNames) == 0 {
+		return nil
+	}
+	return rdb.HDel(ctx, wallpaperMetaPrefix+deviceType, fileNames...).Err()
+}
@@ -0,0 +1,197 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/go-redis/redis/v8"
+	"golang.org/x/oauth2"
+)
+
+// OIDC 登录状态 Key 与有效期
+const (
+	oidcStatePrefix = "oidc:state:"
+	oidcStateTTL    = 10 * time.Minute
+)
+
+var (
+	// ErrInvalidOIDCState 登录状态无效或已过期
+	ErrInvalidOIDCState = errors.New("invalid or expired oidc state")
+	// ErrOIDCNotAllowed 登录用户不在允许列表中
+	ErrOIDCNotAllowed = errors.New("oidc account is not allowed to sign in")
+)
+
+// OIDCOptions OIDC 提供方配置
+type OIDCOptions struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	// 允许登录的邮箱和邮箱域名（不含 @），只匹配已验证的邮箱
+	AllowedEmails  []string
+	AllowedDomains []string
+}
+
+// OIDCClient OIDC 授权码登录，首次使用时才读取提供方的发现文档
+type OIDCClient struct {
+	rdb  *redis.Client
+	opts OIDCOptions
+
+	mu       sync.Mutex
+	verifier *oidc.IDTokenVerifier
+	oauth    *oauth2.Config
+}
+
+// NewOIDCClient 创建 OIDC 客户端
+func NewOIDCClient(rdb *redis.Client, opts OIDCOptions) *OIDCClient {
+	if len(opts.Scopes) == 0 {
+		opts.Scopes = []string{oidc.ScopeOpenID, "profile", "email"}
+	}
+	return &OIDCClient{rdb: rdb, opts: opts}
+}
+
+// Enabled 是否配置了 OIDC
+func (c *OIDCClient) Enabled() bool {
+	return c.opts.Issuer != "" && c.opts.ClientID != ""
+}
+
+// Allowed 登录用户的邮箱是否在允许列表中，邮箱未验证时为空，不匹配任何规则
+func (c *OIDCClient) Allowed(principal *Principal) bool {
+	email := normalizeEmail(principal.Email)
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+	for _, allowed := range c.opts.AllowedEmails {
+		if normalizeEmail(allowed) == email {
+			return true
+		}
+	}
+	domain := email[at+1:]
+	for _, allowed := range c.opts.AllowedDomains {
+		if strings.ToLower(strings.TrimPrefix(strings.TrimSpace(allowed), "@")) == domain {
+			return true
+		}
+	}
+	return false
+}
+
+// 读取发现文档并初始化，失败时下次调用重试
+func (c *OIDCClient) init(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.oauth != nil {
+		return nil
+	}
+
+	provider, err := oidc.NewProvider(ctx, c.opts.Issuer)
+	if err != nil {
+		return fmt.Errorf("failed to discover oidc provider %s: %v", c.opts.Issuer, err)
+	}
+	c.verifier = provider.Verifier(&oidc.Config{ClientID: c.opts.ClientID})
+	c.oauth = &oauth2.Config{
+		ClientID:     c.opts.ClientID,
+		ClientSecret: c.opts.ClientSecret,
+		RedirectURL:  c.opts.RedirectURL,
+		Endpoint:     provider.Endpoint(),
+		Scopes:       c.opts.Scopes,
+	}
+	return nil
+}
+
+// 生成随机字符串
+func randomToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// AuthURL 生成跳转到提供方的登录地址，state 和 nonce 保存在 Redis 中
+func (c *OIDCClient) AuthURL(ctx context.Context) (string, error) {
+	if err := c.init(ctx); err != nil {
+		return "", err
+	}
+
+	state, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+	nonce, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+	if err := c.rdb.Set(ctx, oidcStatePrefix+state, nonce, oidcStateTTL).Err(); err != nil {
+		return "", fmt.Errorf("failed to save oidc state: %v", err)
+	}
+
+	return c.oauth.AuthCodeURL(state, oidc.Nonce(nonce)), nil
+}
+
+// Exchange 用授权码换取并校验 ID Token，返回登录用户
+func (c *OIDCClient) Exchange(ctx context.Context, state string, code string) (*Principal, error) {
+	if err := c.init(ctx); err != nil {
+		return nil, err
+	}
+
+	// state 只能使用一次
+	nonce, err := c.rdb.GetDel(ctx, oidcStatePrefix+state).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrInvalidOIDCState
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := c.oauth.Exchange(ctx, code)
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange authorization code: %v", err)
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, fmt.Errorf("no id_token in token response")
+	}
+	idToken, err := c.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify id_token: %v", err)
+	}
+	if idToken.Nonce != nonce {
+		return nil, fmt.Errorf("id_token nonce mismatch")
+	}
+
+	var claims struct {
+		Name              string `json:"name"`
+		PreferredUsername string `json:"preferred_username"`
+		Email             string `json:"email"`
+		EmailVerified     *bool  `json:"email_verified"`
+	}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("failed to decode id_token claims: %v", err)
+	}
+
+	// 未验证的邮箱不能用于匹配用户
+	if claims.EmailVerified != nil && !*claims.EmailVerified {
+		claims.Email = ""
+	}
+
+	name := claims.Name
+	if name == "" {
+		name = claims.PreferredUsername
+	}
+	return &Principal{
+		Subject: idToken.Subject,
+		Name:    name,
+		Email:   claims.Email,
+		Method:  AuthMethodOIDC,
+	}, nil
+}
@@ -0,0 +1,260 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// 本地 mock issuer：发现文档、JWKS 和令牌端点，签发的 ID Token 可按用例修改
+type mockIssuer struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	claims func(nonce string) jwt.MapClaims
+	nonce  string // 最近一次授权请求的 nonce
+}
+
+func newMockIssuer(t *testing.T) *mockIssuer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &mockIssuer{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                                m.server.URL,
+			"authorization_endpoint":                m.server.URL + "/authorize",
+			"token_endpoint":                        m.server.URL + "/token",
+			"jwks_uri":                              m.server.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"alg": "RS256",
+				"use": "sig",
+				"kid": "test",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("code") != "valid-code" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, m.claims(m.nonce))
+		token.Header["kid"] = "test"
+		idToken, err := token.SignedString(key)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+			"id_token":     idToken,
+		})
+	})
+	m.server = httptest.NewServer(mux)
+	t.Cleanup(m.server.Close)
+	return m
+}
+
+// 默认签发的 ID Token 声明
+func (m *mockIssuer) defaultClaims(nonce string) jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"iss":            m.server.URL,
+		"sub":            "user-1",
+		"aud":            "wallpaper",
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Hour).Unix(),
+		"nonce":          nonce,
+		"name":           "Alice",
+		"email":          "alice@example.com",
+		"email_verified": true,
+	}
+}
+
+// 发起登录，返回 state，并记录 nonce 供令牌端点使用
+func (m *mockIssuer) authorize(t *testing.T, client *OIDCClient) string {
+	t.Helper()
+	authURL, err := client.AuthURL(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Path != "/authorize" || u.Query().Get("client_id") != "wallpaper" {
+		t.Fatalf("unexpected auth url %s", authURL)
+	}
+	m.nonce = u.Query().Get("nonce")
+	return u.Query().Get("state")
+}
+
+func TestOIDCExchange(t *testing.T) {
+	tests := []struct {
+		name      string
+		claims    func(m *mockIssuer, nonce string) jwt.MapClaims
+		code      string
+		wantErr   bool
+		wantEmail string
+	}{
+		{
+			name:      "valid",
+			claims:    func(m *mockIssuer, nonce string) jwt.MapClaims { return m.defaultClaims(nonce) },
+			code:      "valid-code",
+			wantEmail: "alice@example.com",
+		},
+		{
+			name: "unverified email is dropped",
+			claims: func(m *mockIssuer, nonce string) jwt.MapClaims {
+				claims := m.defaultClaims(nonce)
+				claims["email_verified"] = false
+				return claims
+			},
+			code: "valid-code",
+		},
+		{
+			name: "wrong audience",
+			claims: func(m *mockIssuer, nonce string) jwt.MapClaims {
+				claims := m.defaultClaims(nonce)
+				claims["aud"] = "another-client"
+				return claims
+			},
+			code:    "valid-code",
+			wantErr: true,
+		},
+		{
+			name: "wrong nonce",
+			claims: func(m *mockIssuer, nonce string) jwt.MapClaims {
+				return m.defaultClaims("replayed-nonce")
+			},
+			code:    "valid-code",
+			wantErr: true,
+		},
+		{
+			name: "wrong issuer",
+			claims: func(m *mockIssuer, nonce string) jwt.MapClaims {
+				claims := m.defaultClaims(nonce)
+				claims["iss"] = "https://evil.example.com"
+				return claims
+			},
+			code:    "valid-code",
+			wantErr: true,
+		},
+		{
+			name: "expired",
+			claims: func(m *mockIssuer, nonce string) jwt.MapClaims {
+				claims := m.defaultClaims(nonce)
+				claims["exp"] = time.Now().Add(-time.Hour).Unix()
+				return claims
+			},
+			code:    "valid-code",
+			wantErr: true,
+		},
+		{
+			name:    "rejected code",
+			claims:  func(m *mockIssuer, nonce string) jwt.MapClaims { return m.defaultClaims(nonce) },
+			code:    "bad-code",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, rdb := newTestRedis(t)
+			issuer := newMockIssuer(t)
+			issuer.claims = func(nonce string) jwt.MapClaims { return tt.claims(issuer, nonce) }
+			client := NewOIDCClient(rdb, OIDCOptions{
+				Issuer:      issuer.server.URL,
+				ClientID:    "wallpaper",
+				RedirectURL: "http://localhost/auth/oidc/callback",
+			})
+
+			state := issuer.authorize(t, client)
+			principal, err := client.Exchange(context.Background(), state, tt.code)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Exchange() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if principal.Subject != "user-1" || principal.Name != "Alice" || principal.Method != AuthMethodOIDC {
+				t.Errorf("principal = %+v", principal)
+			}
+			if principal.Email != tt.wantEmail {
+				t.Errorf("email = %q, want %q", principal.Email, tt.wantEmail)
+			}
+		})
+	}
+}
+
+func TestOIDCExchangeState(t *testing.T) {
+	_, rdb := newTestRedis(t)
+	issuer := newMockIssuer(t)
+	issuer.claims = issuer.defaultClaims
+	client := NewOIDCClient(rdb, OIDCOptions{Issuer: issuer.server.URL, ClientID: "wallpaper"})
+
+	if _, err := client.Exchange(context.Background(), "unknown", "valid-code"); !errors.Is(err, ErrInvalidOIDCState) {
+		t.Errorf("unknown state error = %v, want ErrInvalidOIDCState", err)
+	}
+
+	state := issuer.authorize(t, client)
+	if _, err := client.Exchange(context.Background(), state, "valid-code"); err != nil {
+		t.Fatal(err)
+	}
+	// state 只能使用一次
+	if _, err := client.Exchange(context.Background(), state, "valid-code"); !errors.Is(err, ErrInvalidOIDCState) {
+		t.Errorf("reused state error = %v, want ErrInvalidOIDCState", err)
+	}
+}
+
+func TestOIDCAllowed(t *testing.T) {
+	client := NewOIDCClient(nil, OIDCOptions{
+		AllowedEmails:  []string{"Bob@Example.org"},
+		AllowedDomains: []string{"@example.com", "corp.example.net"},
+	})
+	tests := []struct {
+		email string
+		want  bool
+	}{
+		{"alice@example.com", true},
+		{"ALICE@EXAMPLE.COM", true},
+		{"bob@example.org", true},
+		{"carol@example.org", false},
+		{"dave@corp.example.net", true},
+		{"eve@evil-example.com", false},
+		{"eve@example.com.evil.net", false},
+		{"", false}, // 邮箱未验证
+		{"example.com", false},
+	}
+	for _, tt := range tests {
+		if got := client.Allowed(&Principal{Email: tt.email}); got != tt.want {
+			t.Errorf("Allowed(%q) = %v, want %v", tt.email, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,376 @@
+package service
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// 存储桶事件动作
+const (
+	ObjectEventCreated = "created"
+	ObjectEventRemoved = "removed"
+)
+
+// OSSEventSignatureHeader S3 风格通知的 HMAC 签名头：sha256=HEX(HMAC-SHA256(secret, body))
+const OSSEventSignatureHeader = "X-OSS-Event-Signature"
+
+// MNS 签名证书只允许通过 https 从 MNS 官方证书地址下载
+// 不能放宽为 *.aliyuncs.com：任何人都可以在自己的 OSS 存储桶中托管证书
+const mnsCertHost = "mnstest.oss-cn-hangzhou.aliyuncs.com"
+
+// MNS 推送请求的 Date 与本机时间最多相差的时长，超出视为重放
+const mnsMaxClockSkew = 15 * time.Minute
+
+// 缓存的签名证书数量上限
+const mnsCertCacheSize = 8
+
+// ErrInvalidEventSignature 通知签名校验失败
+var ErrInvalidEventSignature = errors.New("invalid event notification signature")
+
+// ObjectEvent 解析后的存储桶对象事件
+type ObjectEvent struct {
+	Action     string // created / removed
+	EventName  string // 原始事件名，如 ObjectCreated:PutObject
+	Bucket     string
+	Key        string
+	DeviceType string
+	Filename   string
+}
+
+// MNS HTTP 推送的 XML 格式
+type mnsNotification struct {
+	XMLName xml.Name `xml:"Notification"`
+	Message string   `xml:"Message"`
+}
+
+// OSS 事件通知格式（MNS 推送的 Message 内容）
+type ossEventRecord struct {
+	EventName string `json:"eventName"`
+	OSS       struct {
+		Bucket struct {
+			Name string `json:"name"`
+		} `json:"bucket"`
+		Object struct {
+			Key string `json:"key"`
+		} `json:"object"`
+	} `json:"oss"`
+}
+
+// S3 事件通知格式（MinIO 等兼容实现）
+type s3EventRecord struct {
+	EventName string `json:"eventName"`
+	S3        struct {
+		Bucket struct {
+			Name string `json:"name"`
+		} `json:"bucket"`
+		Object struct {
+			Key string `json:"key"`
+		} `json:"object"`
+	} `json:"s3"`
+}
+
+type bucketEventBody struct {
+	Events  []ossEventRecord `json:"events"`
+	Records []s3EventRecord  `json:"Records"`
+	Message string           `json:"Message"` // MNS JSON 格式推送
+}
+
+// MNS 证书缓存，key 为证书 URL，超过上限时清空
+var (
+	mnsCertMu    sync.Mutex
+	mnsCertCache = make(map[string]*rsa.PublicKey)
+)
+
+var mnsCertClient = &http.Client{Timeout: 5 * time.Second}
+
+// IsMNSNotification 判断是否为 MNS HTTP 推送请求
+func IsMNSNotification(header http.Header) bool {
+	return header.Get("x-mns-signing-cert-url") != ""
+}
+
+// VerifyMNSSignature 校验 MNS HTTP 推送签名，并校验 Content-MD5 与请求体一致、Date 未过期
+// 待签名字符串：METHOD\nContent-MD5\nContent-Type\nDate\n{x-mns-* 头按名称排序}\n{URI}，RSA-SHA1
+func VerifyMNSSignature(r *http.Request, body []byte) error {
+	signature, err := base64.StdEncoding.DecodeString(r.Header.Get("Authorization"))
+	if err != nil || len(signature) == 0 {
+		return ErrInvalidEventSignature
+	}
+	if !verifyContentMD5(r.Header.Get("Content-MD5"), body) {
+		return fmt.Errorf("%w: content md5 mismatch", ErrInvalidEventSignature)
+	}
+	date, err := http.ParseTime(r.Header.Get("Date"))
+	if err != nil {
+		return fmt.Errorf("%w: invalid date", ErrInvalidEventSignature)
+	}
+	if skew := time.Since(date); skew > mnsMaxClockSkew || skew < -mnsMaxClockSkew {
+		return fmt.Errorf("%w: stale date %s", ErrInvalidEventSignature, r.Header.Get("Date"))
+	}
+
+	certURL, err := base64.StdEncoding.DecodeString(r.Header.Get("x-mns-signing-cert-url"))
+	if err != nil {
+		return ErrInvalidEventSignature
+	}
+	publicKey, err := loadMNSPublicKey(string(certURL))
+	if err != nil {
+		return err
+	}
+
+	var mnsHeaders []string
+	for name, values := range r.Header {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "x-mns-") && len(values) > 0 {
+			mnsHeaders = append(mnsHeaders, lower+":"+values[0])
+		}
+	}
+	sort.Strings(mnsHeaders)
+
+	stringToSign := strings.Join([]string{
+		r.Method,
+		r.Header.Get("Content-MD5"),
+		r.Header.Get("Content-Type"),
+		r.Header.Get("Date"),
+		strings.Join(mnsHeaders, "\n"),
+		r.URL.RequestURI(),
+	}, "\n")
+
+	digest := sha1.Sum([]byte(stringToSign))
+	if err := rsa.VerifyPKCS1v15(publicKey, crypto.SHA1, digest[:], signature); err != nil {
+		return ErrInvalidEventSignature
+	}
+	return nil
+}
+
+// Content-MD5 必须存在且与请求体一致，支持 Base64 和十六进制两种编码
+func verifyContentMD5(value string, body []byte) bool {
+	if value == "" {
+		return false
+	}
+	sum := md5.Sum(body)
+	if hmac.Equal([]byte(value), []byte(base64.StdEncoding.EncodeToString(sum[:]))) {
+		return true
+	}
+	return strings.EqualFold(value, hex.EncodeToString(sum[:]))
+}
+
+// 下载并缓存 MNS 签名证书
+func loadMNSPublicKey(certURL string) (*rsa.PublicKey, error) {
+	u, err := url.Parse(certURL)
+	if err != nil || u.Scheme != "https" || u.Host != mnsCertHost || u.User != nil {
+		return nil, fmt.Errorf("%w: untrusted signing cert url '%s'", ErrInvalidEventSignature, certURL)
+	}
+
+	mnsCertMu.Lock()
+	cached, ok := mnsCertCache[certURL]
+	mnsCertMu.Unlock()
+	if ok {
+		return cached, nil
+	}
+
+	resp, err := mnsCertClient.Get(certURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download signing cert: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download signing cert: status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing cert: %v", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("invalid signing cert")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse signing cert: %v", err)
+	}
+	publicKey, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("unsupported signing cert key type")
+	}
+
+	mnsCertMu.Lock()
+	if len(mnsCertCache) >= mnsCertCacheSize {
+		mnsCertCache = make(map[string]*rsa.PublicKey)
+	}
+	mnsCertCache[certURL] = publicKey
+	mnsCertMu.Unlock()
+	return publicKey, nil
+}
+
+// VerifySharedSecret 校验 S3 风格通知：HMAC 签名头或 Bearer Token（MinIO auth_token）
+func VerifySharedSecret(header http.Header, body []byte, secret string) error {
+	if secret == "" {
+		return ErrInvalidEventSignature
+	}
+
+	if signature := header.Get(OSSEventSignatureHeader); signature != "" {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		expected := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+		if hmac.Equal([]byte(signature), []byte(expected)) {
+			return nil
+		}
+		return ErrInvalidEventSignature
+	}
+
+	if token := strings.TrimPrefix(header.Get("Authorization"), "Bearer "); token != "" {
+		if hmac.Equal([]byte(token), []byte(secret)) {
+			return nil
+		}
+	}
+	return ErrInvalidEventSignature
+}
+
+// ParseBucketNotification 解析 OSS（含 MNS 推送的 XML/JSON 封装）和 S3 风格的存储桶事件通知
+func ParseBucketNotification(body []byte) ([]ObjectEvent, error) {
+	payload := body
+
+	// MNS XML 封装
+	trimmed := strings.TrimSpace(string(body))
+	if strings.HasPrefix(trimmed, "<") {
+		var notification mnsNotification
+		if err := xml.Unmarshal(body, &notification); err != nil {
+			return nil, fmt.Errorf("invalid notification xml: %v", err)
+		}
+		payload = decodeMNSMessage(notification.Message)
+	}
+
+	var parsed bucketEventBody
+	if err := json.Unmarshal(payload, &parsed); err != nil {
+		return nil, fmt.Errorf("invalid notification json: %v", err)
+	}
+
+	// MNS JSON 封装
+	if parsed.Message != "" && len(parsed.Events) == 0 && len(parsed.Records) == 0 {
+		message := parsed.Message
+		parsed = bucketEventBody{}
+		if err := json.Unmarshal(decodeMNSMessage(message), &parsed); err != nil {
+			return nil, fmt.Errorf("invalid notification message: %v", err)
+		}
+	}
+
+	var events []ObjectEvent
+	for _, record := range parsed.Events {
+		if event, ok := newObjectEvent(record.EventName, record.OSS.Bucket.Name, record.OSS.Object.Key); ok {
+			events = append(events, event)
+		}
+	}
+	for _, record := range parsed.Records {
+		// S3 事件中的对象 Key 为 URL 编码
+		key, err := url.QueryUnescape(record.S3.Object.Key)
+		if err != nil {
+			key = record.S3.Object.Key
+		}
+		if event, ok := newObjectEvent(record.EventName, record.S3.Bucket.Name, key); ok {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+// MNS 推送的消息体可能经过 Base64 编码
+func decodeMNSMessage(message string) []byte {
+	message = strings.TrimSpace(message)
+	if decoded, err := base64.StdEncoding.DecodeString(message); err == nil && json.Valid(decoded) {
+		return decoded
+	}
+	return []byte(message)
+}
+
+// 将原始事件转换为对象事件，忽略不相关的事件和路径
+func newObjectEvent(eventName string, bucketName string, key string) (ObjectEvent, bool) {
+	// MinIO 事件名带 s3: 前缀
+	name := strings.TrimPrefix(eventName, "s3:")
+
+	var action string
+	switch {
+	case strings.HasPrefix(name, "ObjectCreated:"):
+		action = ObjectEventCreated
+	case strings.HasPrefix(name, "ObjectRemoved:"):
+		action = ObjectEventRemoved
+	default:
+		return ObjectEvent{}, false
+	}
+
+	parts := strings.Split(key, "/")
+	if len(parts) < 2 || !ValidateDeviceType(parts[0]) {
+		return ObjectEvent{}, false
+	}
+	filename := parts[len(parts)-1]
+	if filename == "" || strings.HasSuffix(filename, ".alist") {
+		return ObjectEvent{}, false
+	}
+
+	return ObjectEvent{
+		Action:     action,
+		EventName:  eventName,
+		Bucket:     bucketName,
+		Key:        key,
+		DeviceType: parts[0],
+		Filename:   filename,
+	}, true
+}
+
+// ApplyObjectEvent 将对象事件同步到壁纸列表和随机壁纸缓存，返回壁纸列表是否发生变化
+func ApplyObjectEvent(ctx context.Context, rdb *redis.Client, event ObjectEvent) (bool, error) {
+	keyOriginal := "wallpaper:" + event.DeviceType
+	keyCache := "wallpaper:cache:" + event.DeviceType
+
+	switch event.Action {
+	case ObjectEventCreated:
+		// 先删除再添加，保证不重复
+		existed, err := rdb.LRem(ctx, keyOriginal, 0, event.Filename).Result()
+		if err != nil {
+			return false, fmt.Errorf("failed to remove image from wallpaper cache list: %v", err)
+		}
+		if err := rdb.LPush(ctx, keyOriginal, event.Filename).Err(); err != nil {
+			return false, fmt.Errorf("failed to add image to wallpaper cache list: %v", err)
+		}
+		if err := AddToRandomWallpaperCache(event.Filename, rdb, event.DeviceType); err != nil {
+			return false, err
+		}
+		return existed == 0, nil
+	case ObjectEventRemoved:
+		removed, err := rdb.LRem(ctx, keyOriginal, 0, event.Filename).Result()
+		if err != nil {
+			return false, fmt.Errorf("failed to remove image from wallpaper cache list: %v", err)
+		}
+		if err := rdb.LRem(ctx, keyCache, 0, event.Filename).Err(); err != nil {
+			return false, fmt.Errorf("failed to remove image from random wallpaper cache list: %v", err)
+		}
+		if err := DeleteWallpaperMeta(ctx, rdb, event.DeviceType, event.Filename); err != nil {
+			return false, fmt.Errorf("failed to remove wallpaper meta: %v", err)
+		}
+		if err := RemoveFromCollections(ctx, rdb, event.DeviceType, event.Filename); err != nil {
+			return false, fmt.Errorf("failed to remove wallpaper from collections: %v", err)
+		}
+		return removed > 0, nil
+	}
+	return false, fmt.Errorf("unsupported object event action '%s'", event.Action)
+}
@@ -0,0 +1,310 @@
+package service
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+// 生成自签名证书，返回私钥和 PEM
+func newTestCert(t *testing.T) (*rsa.PrivateKey, []byte) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "mns"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+// 证书下载改为返回内存中的证书，记录下载次数
+func stubCertDownload(t *testing.T, certs map[string][]byte) *int32 {
+	t.Helper()
+	var downloads int32
+	client := mnsCertClient
+	mnsCertClient = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		atomic.AddInt32(&downloads, 1)
+		data, ok := certs[r.URL.String()]
+		if !ok {
+			return &http.Response{StatusCode: http.StatusNotFound, Body: io.NopCloser(strings.NewReader("")), Request: r}, nil
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(data)), Request: r}, nil
+	})}
+	mnsCertMu.Lock()
+	mnsCertCache = make(map[string]*rsa.PublicKey)
+	mnsCertMu.Unlock()
+	t.Cleanup(func() { mnsCertClient = client })
+	return &downloads
+}
+
+// 按 MNS 规则签名请求
+func signMNSRequest(t *testing.T, r *http.Request, key *rsa.PrivateKey) {
+	t.Helper()
+	var mnsHeaders []string
+	for name, values := range r.Header {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "x-mns-") {
+			mnsHeaders = append(mnsHeaders, lower+":"+values[0])
+		}
+	}
+	sort.Strings(mnsHeaders)
+	stringToSign := strings.Join([]string{
+		r.Method,
+		r.Header.Get("Content-MD5"),
+		r.Header.Get("Content-Type"),
+		r.Header.Get("Date"),
+		strings.Join(mnsHeaders, "\n"),
+		r.URL.RequestURI(),
+	}, "\n")
+	digest := sha1.Sum([]byte(stringToSign))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA1, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Header.Set("Authorization", base64.StdEncoding.EncodeToString(signature))
+}
+
+func newMNSRequest(body []byte, certURL string, date time.Time) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/oss/events", bytes.NewReader(body))
+	sum := md5.Sum(body)
+	r.Header.Set("Content-MD5", base64.StdEncoding.EncodeToString(sum[:]))
+	r.Header.Set("Content-Type", "text/xml;charset=utf-8")
+	r.Header.Set("Date", date.UTC().Format(http.TimeFormat))
+	r.Header.Set("x-mns-signing-cert-url", base64.StdEncoding.EncodeToString([]byte(certURL)))
+	r.Header.Set("x-mns-request-id", "5F5B")
+	return r
+}
+
+func TestVerifyMNSSignature(t *testing.T) {
+	officialURL := "https://" + mnsCertHost + "/x509_public_certificate.pem"
+	forgedURL := "https://attacker.oss-cn-hangzhou.aliyuncs.com/x509_public_certificate.pem"
+	officialKey, officialCert := newTestCert(t)
+	forgedKey, forgedCert := newTestCert(t)
+	body := []byte(`<Notification><Message>e30=</Message></Notification>`)
+
+	tests := []struct {
+		name     string
+		build    func() *http.Request
+		wantErr  bool
+		download bool // 是否会下载证书
+	}{
+		{
+			name: "valid",
+			build: func() *http.Request {
+				r := newMNSRequest(body, officialURL, time.Now())
+				signMNSRequest(t, r, officialKey)
+				return r
+			},
+			download: true,
+		},
+		{
+			name: "hex content md5",
+			build: func() *http.Request {
+				r := newMNSRequest(body, officialURL, time.Now())
+				sum := md5.Sum(body)
+				r.Header.Set("Content-MD5", strings.ToUpper(hex.EncodeToString(sum[:])))
+				signMNSRequest(t, r, officialKey)
+				return r
+			},
+			download: true,
+		},
+		{
+			name: "forged cert in another aliyuncs bucket",
+			build: func() *http.Request {
+				r := newMNSRequest(body, forgedURL, time.Now())
+				signMNSRequest(t, r, forgedKey)
+				return r
+			},
+			wantErr: true,
+		},
+		{
+			name: "official host over http",
+			build: func() *http.Request {
+				r := newMNSRequest(body, "http://"+mnsCertHost+"/x509_public_certificate.pem", time.Now())
+				signMNSRequest(t, r, officialKey)
+				return r
+			},
+			wantErr: true,
+		},
+		{
+			name: "official url signed with another key",
+			build: func() *http.Request {
+				r := newMNSRequest(body, officialURL, time.Now())
+				signMNSRequest(t, r, forgedKey)
+				return r
+			},
+			wantErr:  true,
+			download: true,
+		},
+		{
+			name: "tampered body",
+			build: func() *http.Request {
+				r := newMNSRequest(body, officialURL, time.Now())
+				signMNSRequest(t, r, officialKey)
+				r.Body = io.NopCloser(strings.NewReader(`<Notification><Message>forged</Message></Notification>`))
+				return r
+			},
+			wantErr: true,
+		},
+		{
+			name: "missing content md5",
+			build: func() *http.Request {
+				r := newMNSRequest(body, officialURL, time.Now())
+				r.Header.Del("Content-MD5")
+				signMNSRequest(t, r, officialKey)
+				return r
+			},
+			wantErr: true,
+		},
+		{
+			name: "stale date",
+			build: func() *http.Request {
+				r := newMNSRequest(body, officialURL, time.Now().Add(-time.Hour))
+				signMNSRequest(t, r, officialKey)
+				return r
+			},
+			wantErr: true,
+		},
+		{
+			name: "future date",
+			build: func() *http.Request {
+				r := newMNSRequest(body, officialURL, time.Now().Add(time.Hour))
+				signMNSRequest(t, r, officialKey)
+				return r
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			downloads := stubCertDownload(t, map[string][]byte{officialURL: officialCert, forgedURL: forgedCert})
+			r := tt.build()
+			received, err := io.ReadAll(r.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = VerifyMNSSignature(r, received)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("VerifyMNSSignature() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidEventSignature) {
+				t.Errorf("error %v does not wrap ErrInvalidEventSignature", err)
+			}
+			if got := atomic.LoadInt32(downloads) > 0; got != tt.download {
+				t.Errorf("cert downloaded = %v, want %v", got, tt.download)
+			}
+		})
+	}
+}
+
+func TestLoadMNSPublicKeyCacheBounded(t *testing.T) {
+	_, cert := newTestCert(t)
+	certs := make(map[string][]byte)
+	for i := 0; i < mnsCertCacheSize*3; i++ {
+		certs["https://"+mnsCertHost+"/cert-"+string(rune('a'+i))+".pem"] = cert
+	}
+	downloads := stubCertDownload(t, certs)
+
+	for certURL := range certs {
+		if _, err := loadMNSPublicKey(certURL); err != nil {
+			t.Fatalf("loadMNSPublicKey(%s): %v", certURL, err)
+		}
+		mnsCertMu.Lock()
+		size := len(mnsCertCache)
+		mnsCertMu.Unlock()
+		if size > mnsCertCacheSize {
+			t.Fatalf("cert cache size %d exceeds %d", size, mnsCertCacheSize)
+		}
+	}
+
+	// 命中缓存时不再下载
+	var cached string
+	mnsCertMu.Lock()
+	for certURL := range mnsCertCache {
+		cached = certURL
+		break
+	}
+	mnsCertMu.Unlock()
+	before := atomic.LoadInt32(downloads)
+	if _, err := loadMNSPublicKey(cached); err != nil {
+		t.Fatal(err)
+	}
+	if after := atomic.LoadInt32(downloads); after != before {
+		t.Errorf("cached cert downloaded again")
+	}
+}
+
+func TestVerifySharedSecret(t *testing.T) {
+	body := []byte(`{"Records":[]}`)
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write(body)
+	signature := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	tests := []struct {
+		name    string
+		header  http.Header
+		secret  string
+		wantErr bool
+	}{
+		{"hmac", header(OSSEventSignatureHeader, signature), "secret", false},
+		{"hmac with other secret", header(OSSEventSignatureHeader, signature), "other", true},
+		{"bad hmac ignores bearer", header(OSSEventSignatureHeader, "sha256=00", "Authorization", "Bearer secret"), "secret", true},
+		{"bearer", header("Authorization", "Bearer secret"), "secret", false},
+		{"wrong bearer", header("Authorization", "Bearer other"), "secret", true},
+		{"empty secret", header("Authorization", "Bearer "), "", true},
+		{"no credentials", http.Header{}, "secret", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifySharedSecret(tt.header, body, tt.secret)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("VerifySharedSecret() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func header(pairs ...string) http.Header {
+	h := http.Header{}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		h.Set(pairs[i], pairs[i+1])
+	}
+	return h
+}
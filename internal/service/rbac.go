@@ -0,0 +1,78 @@
+package service
+
+// 角色
+const (
+	RoleViewer   = "viewer"   // 查看壁纸和元数据
+	RoleUploader = "uploader" // 上传壁纸，只能删除自己上传的壁纸
+	RoleCurator  = "curator"  // 管理壁纸库内容：删除任意壁纸、管理标签和合集
+	RoleAdmin    = "admin"    // 全部权限：重建缓存、API Key、用户和 Webhook 管理
+)
+
+// 权限
+const (
+	PermWallpaperRead      = "wallpaper:read"       // 查看壁纸元数据
+	PermWallpaperUpload    = "wallpaper:upload"     // 上传壁纸
+	PermWallpaperDeleteOwn = "wallpaper:delete:own" // 删除自己上传的壁纸
+	PermWallpaperDeleteAny = "wallpaper:delete:any" // 删除任意壁纸
+	PermTagsManage         = "tags:manage"          // 管理壁纸标签
+	PermCollectionsManage  = "collections:manage"   // 管理壁纸合集
+	PermCacheManage        = "cache:manage"         // 重建缓存、查询任务和对账报告
+	PermKeysManage         = "keys:manage"          // 管理 API Key
+	PermUsersManage        = "users:manage"         // 管理用户和角色
+	PermWebhooksManage     = "webhooks:manage"      // 管理 Webhook 订阅
+	PermConfigManage       = "config:manage"        // 查看和重新加载运行时配置（限流策略）
+	PermAuditRead          = "audit:read"           // 查询审计日志
+)
+
+// 全部权限
+var allPermissions = []string{
+	PermWallpaperRead, PermWallpaperUpload, PermWallpaperDeleteOwn, PermWallpaperDeleteAny,
+	PermTagsManage, PermCollectionsManage, PermCacheManage, PermKeysManage, PermUsersManage, PermWebhooksManage, PermConfigManage,
+	PermAuditRead,
+}
+
+// 角色拥有的权限
+var rolePermissions = map[string][]string{
+	RoleViewer:   {PermWallpaperRead},
+	RoleUploader: {PermWallpaperRead, PermWallpaperUpload, PermWallpaperDeleteOwn},
+	RoleCurator:  {PermWallpaperRead, PermWallpaperUpload, PermWallpaperDeleteOwn, PermWallpaperDeleteAny, PermTagsManage, PermCollectionsManage},
+	RoleAdmin:    allPermissions,
+}
+
+// API Key 权限范围对应的权限
+var scopePermissions = map[string][]string{
+	ScopeRead:   {PermWallpaperRead},
+	ScopeUpload: {PermWallpaperUpload},
+	ScopeDelete: {PermWallpaperDeleteAny},
+	ScopeAdmin:  allPermissions,
+}
+
+// ValidateRole 校验角色
+func ValidateRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// RoleHasPermission 判断角色是否拥有权限
+func RoleHasPermission(role string, permission string) bool {
+	return containsString(rolePermissions[role], permission)
+}
+
+// HasPermission 判断 API Key 的权限范围是否包含权限
+func (k *APIKey) HasPermission(permission string) bool {
+	for _, scope := range k.Scopes {
+		if containsString(scopePermissions[scope], permission) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(values []string, target string) bool {
+	for _, v := range values {
+		if v == target {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+package service
+
+import "testing"
+
+func TestRoleHasPermission(t *testing.T) {
+	tests := []struct {
+		role       string
+		permission string
+		want       bool
+	}{
+		{RoleViewer, PermWallpaperRead, true},
+		{RoleViewer, PermWallpaperUpload, false},
+		{RoleViewer, PermWallpaperDeleteOwn, false},
+
+		{RoleUploader, PermWallpaperUpload, true},
+		{RoleUploader, PermWallpaperDeleteOwn, true},
+		{RoleUploader, PermWallpaperDeleteAny, false},
+		{RoleUploader, PermTagsManage, false},
+
+		{RoleCurator, PermWallpaperDeleteAny, true},
+		{RoleCurator, PermTagsManage, true},
+		{RoleCurator, PermCollectionsManage, true},
+		{RoleCurator, PermCacheManage, false},
+		{RoleCurator, PermKeysManage, false},
+		{RoleCurator, PermUsersManage, false},
+
+		{RoleAdmin, PermCacheManage, true},
+		{RoleAdmin, PermKeysManage, true},
+		{RoleAdmin, PermUsersManage, true},
+		{RoleAdmin, PermWebhooksManage, true},
+		{RoleAdmin, PermConfigManage, true},
+		{RoleAdmin, PermAuditRead, true},
+
+		{"unknown", PermWallpaperRead, false},
+		{"", PermWallpaperRead, false},
+		{RoleAdmin, "unknown:permission", false},
+	}
+	for _, tt := range tests {
+		if got := RoleHasPermission(tt.role, tt.permission); got != tt.want {
+			t.Errorf("RoleHasPermission(%q, %q) = %v, want %v", tt.role, tt.permission, got, tt.want)
+		}
+	}
+}
+
+func TestRolePermissionsAreKnown(t *testing.T) {
+	for role, permissions := range rolePermissions {
+		for _, permission := range permissions {
+			if !containsString(allPermissions, permission) {
+				t.Errorf("role %s has unknown permission %s", role, permission)
+			}
+		}
+	}
+	// admin 拥有全部权限
+	for _, permission := range allPermissions {
+		if !RoleHasPermission(RoleAdmin, permission) {
+			t.Errorf("admin lacks %s", permission)
+		}
+	}
+}
+
+func TestValidateRole(t *testing.T) {
+	for _, role := range []string{RoleViewer, RoleUploader, RoleCurator, RoleAdmin} {
+		if !ValidateRole(role) {
+			t.Errorf("ValidateRole(%q) = false", role)
+		}
+	}
+	for _, role := range []string{"", "Admin", "root"} {
+		if ValidateRole(role) {
+			t.Errorf("ValidateRole(%q) = true", role)
+		}
+	}
+}
+
+func TestAPIKeyHasPermission(t *testing.T) {
+	tests := []struct {
+		name       string
+		scopes     []string
+		permission string
+		want       bool
+	}{
+		{"read", []string{ScopeRead}, PermWallpaperRead, true},
+		{"read cannot upload", []string{ScopeRead}, PermWallpaperUpload, false},
+		{"upload", []string{ScopeUpload}, PermWallpaperUpload, true},
+		{"upload cannot read metadata", []string{ScopeUpload}, PermWallpaperRead, false},
+		{"delete grants delete any", []string{ScopeDelete}, PermWallpaperDeleteAny, true},
+		{"delete cannot manage tags", []string{ScopeDelete}, PermTagsManage, false},
+		{"multiple scopes", []string{ScopeRead, ScopeUpload}, PermWallpaperUpload, true},
+		{"admin", []string{ScopeAdmin}, PermKeysManage, true},
+		{"unknown scope", []string{"superuser"}, PermWallpaperRead, false},
+		{"no scopes", nil, PermWallpaperRead, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := &APIKey{Scopes: tt.scopes}
+			if got := key.HasPermission(tt.permission); got != tt.want {
+				t.Errorf("HasPermission(%q) = %v, want %v", tt.permission, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/TXM983/wallpaper-api-v1/internal/metrics"
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+// 暂存 Key 的过期时间，重建中途失败时自动清理
+const stagingKeyTTL = 10 * time.Minute
+
+// 壁纸库版本号 Key 前缀，每次重建成功后自增
+const generationKeyPrefix = "wallpaper:generation:"
+
+// RebuildResult 缓存重建结果
+type RebuildResult struct {
+	DeviceType string `json:"deviceType"`
+	Count      int    `json:"count"`
+	Generation int64  `json:"generation"`
+}
+
+// RebuildWallpaperCache 从 OSS 重建指定设备类型的壁纸列表和随机壁纸缓存
+// 先写入暂存 Key，再在 MULTI 中用 RENAME 原子替换线上 Key，读请求不会看到空列表或半成品
+// onProgress 可为空，列举 OSS 时回调已列举数量
+func RebuildWallpaperCache(ctx context.Context, rdb *redis.Client, bucket *oss.Bucket, deviceType string, onProgress func(listed int)) (result *RebuildResult, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveCacheRefill(deviceType, metrics.RefillTriggerRebuild, start, err) }()
+
+	keyOriginal := "wallpaper:" + deviceType
+	keyCache := "wallpaper:cache:" + deviceType
+
+	filenames, err := ListWallpaperFilenamesWithProgress(ctx, bucket, deviceType, onProgress)
+	if err != nil {
+		return nil, err
+	}
+
+	// OSS 中没有壁纸时直接清空线上 Key（无法 RENAME 不存在的 Key）
+	if len(filenames) == 0 {
+		tx := rdb.TxPipeline()
+		tx.Del(ctx, keyOriginal, keyCache)
+		generation := tx.Incr(ctx, generationKeyPrefix+deviceType)
+		if _, err := tx.Exec(ctx); err != nil {
+			return nil, fmt.Errorf("failed to clear wallpaper cache for %s: %v", deviceType, err)
+		}
+		return &RebuildResult{DeviceType: deviceType, Generation: generation.Val()}, nil
+	}
+
+	suffix := uuid.New().String()
+	stagingOriginal := fmt.Sprintf("wallpaper:staging:%s:%s", deviceType, suffix)
+	stagingCache := fmt.Sprintf("wallpaper:cache:staging:%s:%s", deviceType, suffix)
+
+	shuffled := make([]string, len(filenames))
+	copy(shuffled, filenames)
+	shuffleWallpapers(shuffled)
+
+	// 写入暂存 Key
+	pipe := rdb.Pipeline()
+	pipe.LPush(ctx, stagingOriginal, stringSliceToInterfaceSlice(filenames)...)
+	pipe.Expire(ctx, stagingOriginal, stagingKeyTTL)
+	pipe.LPush(ctx, stagingCache, stringSliceToInterfaceSlice(shuffled)...)
+	pipe.Expire(ctx, stagingCache, stagingKeyTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		rdb.Del(ctx, stagingOriginal, stagingCache)
+		return nil, fmt.Errorf("failed to write staging keys for %s: %v", deviceType, err)
+	}
+
+	// 原子替换线上 Key，RENAME 会保留过期时间，需要 PERSIST
+	tx := rdb.TxPipeline()
+	tx.Rename(ctx, stagingOriginal, keyOriginal)
+	tx.Rename(ctx, stagingCache, keyCache)
+	tx.Persist(ctx, keyOriginal)
+	tx.Persist(ctx, keyCache)
+	generation := tx.Incr(ctx, generationKeyPrefix+deviceType)
+	if _, err := tx.Exec(ctx); err != nil {
+		rdb.Del(ctx, stagingOriginal, stagingCache)
+		return nil, fmt.Errorf("failed to swap wallpaper cache for %s: %v", deviceType, err)
+	}
+
+	return &RebuildResult{DeviceType: deviceType, Count: len(filenames), Generation: generation.Val()}, nil
+}
@@ -0,0 +1,286 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/TXM983/wallpaper-api-v1/internal/logger"
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+// 对账相关 Redis Key
+const (
+	reconcileReportKey    = "wallpaper:reconcile:last"     // 最近一次对账报告
+	reconcileLockKey      = "lock:wallpaper:reconcile"     // 多实例互斥锁
+	reconcileMissingKey   = "wallpaper:reconcile:missing:" // 上次对账时 OSS 中不存在的壁纸，连续两次不存在才移除
+	reconcileMissingTTL   = 7 * 24 * time.Hour
+	reconcileLockOwnerTag = "reconcile:" // 对账持有重建锁时的锁值前缀，区别于重建任务 ID
+)
+
+// 报告中每类最多保留的文件名数量，避免大量漂移时报告过大
+const reconcileReportFileLimit = 100
+
+// DeviceTypes 支持的设备类型
+var DeviceTypes = []string{"pc", "mobile"}
+
+// CategoryDrift 单个设备类型的对账结果
+type CategoryDrift struct {
+	StorageCount int      `json:"storageCount"` // OSS 中的壁纸数量
+	CacheCount   int      `json:"cacheCount"`   // 对账前 Redis 中的壁纸数量
+	AddedCount   int      `json:"addedCount"`
+	RemovedCount int      `json:"removedCount"`
+	PendingCount int      `json:"pendingCount"` // OSS 中首次发现不存在、下次对账仍不存在时移除的数量
+	Added        []string `json:"added,omitempty"`
+	Removed      []string `json:"removed,omitempty"`
+	Pending      []string `json:"pending,omitempty"`
+	Skipped      bool     `json:"skipped,omitempty"` // 该类型正在重建，本次跳过
+	Error        string   `json:"error,omitempty"`
+}
+
+// ReconcileReport 对账报告
+type ReconcileReport struct {
+	StartedAt  int64                     `json:"startedAt"`
+	FinishedAt int64                     `json:"finishedAt"`
+	DurationMs int64                     `json:"durationMs"`
+	Categories map[string]*CategoryDrift `json:"categories"`
+	Success    bool                      `json:"success"`
+}
+
+// Reconciler 定期对比 OSS 与 Redis 壁纸列表，只应用差异部分
+type Reconciler struct {
+	rdb      *redis.Client
+	bucket   *oss.Bucket
+	interval time.Duration
+	notify   func(event LibraryEvent) // 壁纸列表变化时的回调
+}
+
+// NewReconciler 创建对账任务，notify 可为空
+func NewReconciler(rdb *redis.Client, bucket *oss.Bucket, interval time.Duration, notify func(event LibraryEvent)) *Reconciler {
+	return &Reconciler{
+		rdb:      rdb,
+		bucket:   bucket,
+		interval: interval,
+		notify:   notify,
+	}
+}
+
+// Run 按间隔执行对账，直到 ctx 结束；多实例下同一周期只有一个实例执行
+func (r *Reconciler) Run(ctx context.Context) {
+	if r.interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.runLocked(ctx)
+		}
+	}
+}
+
+// 获取互斥锁后执行一次对账
+func (r *Reconciler) runLocked(ctx context.Context) {
+	lockValue := uuid.New().String()
+	// 锁的过期时间略小于间隔，实例异常退出时不影响下个周期
+	acquired, err := r.rdb.SetNX(ctx, reconcileLockKey, lockValue, r.interval*9/10).Result()
+	if err != nil {
+		logger.LogError("Error acquiring reconcile lock: %v", err)
+		return
+	}
+	if !acquired {
+		return
+	}
+	defer unlockScript.Run(ctx, r.rdb, []string{reconcileLockKey}, lockValue)
+
+	if _, err := r.ReconcileOnce(ctx); err != nil {
+		logger.LogError("Wallpaper reconcile finished with errors: %v", err)
+	}
+}
+
+// ReconcileOnce 立即执行一次对账并保存报告
+func (r *Reconciler) ReconcileOnce(ctx context.Context) (*ReconcileReport, error) {
+	start := time.Now()
+	report := &ReconcileReport{
+		StartedAt:  start.Unix(),
+		Categories: make(map[string]*CategoryDrift, len(DeviceTypes)),
+		Success:    true,
+	}
+
+	var errs []error
+	for _, deviceType := range DeviceTypes {
+		drift, err := r.reconcileDevice(ctx, deviceType)
+		if err != nil {
+			drift.Error = err.Error()
+			report.Success = false
+			errs = append(errs, fmt.Errorf("%s: %v", deviceType, err))
+		}
+		report.Categories[deviceType] = drift
+	}
+
+	report.FinishedAt = time.Now().Unix()
+	report.DurationMs = time.Since(start).Milliseconds()
+
+	if data, err := json.Marshal(report); err == nil {
+		r.rdb.Set(ctx, reconcileReportKey, data, 0)
+	}
+
+	for deviceType, drift := range report.Categories {
+		if drift.Skipped {
+			logger.LogInfo("Skipped reconciling %s wallpapers: rebuild in progress", deviceType)
+			continue
+		}
+		logger.LogInfo("Reconciled %s wallpapers: storage %d, cache %d, added %d, removed %d, pending %d",
+			deviceType, drift.StorageCount, drift.CacheCount, drift.AddedCount, drift.RemovedCount, drift.PendingCount)
+	}
+
+	return report, errors.Join(errs...)
+}
+
+// 对比单个设备类型并应用差异
+// 对账期间持有该类型的重建锁，与重建任务互斥；正在重建时跳过
+// 先读 Redis 再列举 OSS：期间上传的文件只会被补充（幂等），期间删除的文件不会被重新加入
+// 多余的壁纸需连续两次对账都不在 OSS 中才移除，避免与上传/删除并发时误删
+func (r *Reconciler) reconcileDevice(ctx context.Context, deviceType string) (*CategoryDrift, error) {
+	drift := &CategoryDrift{}
+	keyOriginal := "wallpaper:" + deviceType
+	keyCache := "wallpaper:cache:" + deviceType
+	keyMissing := reconcileMissingKey + deviceType
+
+	release, err := acquireRebuildLock(ctx, r.rdb, deviceType, reconcileLockOwnerTag+uuid.New().String())
+	if err != nil {
+		var inProgress *RebuildInProgressError
+		if errors.As(err, &inProgress) {
+			drift.Skipped = true
+			return drift, nil
+		}
+		return drift, err
+	}
+	defer release()
+
+	cachedFiles, err := r.rdb.LRange(ctx, keyOriginal, 0, -1).Result()
+	if err != nil {
+		return drift, fmt.Errorf("failed to read %s: %v", keyOriginal, err)
+	}
+	previousMissing, err := r.rdb.SMembers(ctx, keyMissing).Result()
+	if err != nil {
+		return drift, fmt.Errorf("failed to read %s: %v", keyMissing, err)
+	}
+	storageFiles, err := ListWallpaperFilenames(ctx, r.bucket, deviceType)
+	if err != nil {
+		return drift, err
+	}
+	drift.StorageCount = len(storageFiles)
+	drift.CacheCount = len(cachedFiles)
+
+	inStorage := make(map[string]bool, len(storageFiles))
+	for _, f := range storageFiles {
+		inStorage[f] = true
+	}
+	inCache := make(map[string]bool, len(cachedFiles))
+	for _, f := range cachedFiles {
+		inCache[f] = true
+	}
+	wasMissing := make(map[string]bool, len(previousMissing))
+	for _, f := range previousMissing {
+		wasMissing[f] = true
+	}
+
+	var added, removed, pending []string
+	for f := range inStorage {
+		if !inCache[f] {
+			added = append(added, f)
+		}
+	}
+	for f := range inCache {
+		if inStorage[f] {
+			continue
+		}
+		if wasMissing[f] {
+			removed = append(removed, f)
+		} else {
+			pending = append(pending, f)
+		}
+	}
+
+	// 只应用差异，不清空列表，读请求始终能取到壁纸
+	// 补充时先删除再添加，与同时完成的上传不会重复
+	pipe := r.rdb.TxPipeline()
+	for _, f := range added {
+		pipe.LRem(ctx, keyOriginal, 0, f)
+		pipe.LPush(ctx, keyOriginal, f)
+		pipe.LRem(ctx, keyCache, 0, f)
+		pipe.LPush(ctx, keyCache, f)
+	}
+	for _, f := range removed {
+		pipe.LRem(ctx, keyOriginal, 0, f)
+		pipe.LRem(ctx, keyCache, 0, f)
+	}
+	DeleteWallpaperMeta(ctx, pipe, deviceType, removed...) // 管道中的错误由 Exec 返回
+	pipe.Del(ctx, keyMissing)
+	if len(pending) > 0 {
+		pipe.SAdd(ctx, keyMissing, stringSliceToInterfaceSlice(pending)...)
+		pipe.Expire(ctx, keyMissing, reconcileMissingTTL)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return drift, fmt.Errorf("failed to apply drift: %v", err)
+	}
+
+	if len(removed) > 0 {
+		if err := RemoveFromCollections(ctx, r.rdb, deviceType, removed...); err != nil {
+			logger.LogError("Error removing reconciled %s wallpapers from collections: %v", deviceType, err)
+		}
+	}
+
+	drift.AddedCount = len(added)
+	drift.RemovedCount = len(removed)
+	drift.PendingCount = len(pending)
+	drift.Added = limitFiles(added)
+	drift.Removed = limitFiles(removed)
+	drift.Pending = limitFiles(pending)
+
+	if r.notify != nil {
+		if len(added) > 0 {
+			r.notify(NewLibraryEvent(EventWallpaperCreated, deviceType, added...))
+		}
+		if len(removed) > 0 {
+			r.notify(NewLibraryEvent(EventWallpaperDeleted, deviceType, removed...))
+		}
+	}
+
+	return drift, nil
+}
+
+// 截断文件名列表
+func limitFiles(files []string) []string {
+	if len(files) > reconcileReportFileLimit {
+		return files[:reconcileReportFileLimit]
+	}
+	return files
+}
+
+// LastReconcileReport 获取最近一次对账报告，尚未执行过时返回 nil
+func LastReconcileReport(ctx context.Context, rdb *redis.Client) (*ReconcileReport, error) {
+	data, err := rdb.Get(ctx, reconcileReportKey).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var report ReconcileReport
+	if err := json.Unmarshal([]byte(data), &report); err != nil {
+		return nil, fmt.Errorf("failed to decode reconcile report: %v", err)
+	}
+	return &report, nil
+}
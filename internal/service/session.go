@@ -0,0 +1,142 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+)
+
+// 登录方式
+const (
+	AuthMethodPassword = "password" // 管理员密码
+	AuthMethodToken    = "token"    // 管理员 API Token
+	AuthMethodAPIKey   = "apikey"   // API Key
+	AuthMethodOIDC     = "oidc"     // OIDC 单点登录
+)
+
+// 会话相关 Redis Key 与参数
+const (
+	sessionRevokedPrefix = "session:revoked:" // 已注销的会话 ID
+	sessionIssuer        = "wallpaper-api"
+)
+
+// ErrInvalidSession 会话无效、过期或已注销
+var ErrInvalidSession = errors.New("invalid session")
+
+// Principal 当前请求的操作者身份
+type Principal struct {
+	Subject string `json:"sub"`
+	Name    string `json:"name,omitempty"`
+	Email   string `json:"email,omitempty"`
+	Method  string `json:"method"`
+}
+
+// DisplayName 用于记录的操作者名称
+func (p *Principal) DisplayName() string {
+	switch {
+	case p.Email != "":
+		return p.Email
+	case p.Name != "":
+		return p.Name
+	default:
+		return p.Subject
+	}
+}
+
+// SessionClaims 会话 JWT 的声明
+type SessionClaims struct {
+	Name   string `json:"name,omitempty"`
+	Email  string `json:"email,omitempty"`
+	Method string `json:"method"`
+	jwt.RegisteredClaims
+}
+
+// SessionManager 基于 JWT（HS256）的登录会话
+type SessionManager struct {
+	rdb    *redis.Client
+	secret []byte
+	ttl    time.Duration
+}
+
+// NewSessionManager 创建会话管理器，secret 为空时随机生成（重启后会话失效，多实例间不共享）
+func NewSessionManager(rdb *redis.Client, secret string, ttl time.Duration) (*SessionManager, error) {
+	key := []byte(secret)
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, fmt.Errorf("failed to generate session secret: %v", err)
+		}
+	}
+	if ttl <= 0 {
+		ttl = 12 * time.Hour
+	}
+	return &SessionManager{rdb: rdb, secret: key, ttl: ttl}, nil
+}
+
+// TTL 会话有效期
+func (m *SessionManager) TTL() time.Duration {
+	return m.ttl
+}
+
+// Issue 为操作者签发会话令牌
+func (m *SessionManager) Issue(principal *Principal) (string, error) {
+	now := time.Now()
+	claims := SessionClaims{
+		Name:   principal.Name,
+		Email:  principal.Email,
+		Method: principal.Method,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			Issuer:    sessionIssuer,
+			Subject:   principal.Subject,
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(m.ttl)),
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(m.secret)
+}
+
+// Parse 校验会话令牌，返回操作者和声明
+func (m *SessionManager) Parse(ctx context.Context, token string) (*Principal, *SessionClaims, error) {
+	claims := &SessionClaims{}
+	parsed, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		if t.Method != jwt.SigningMethodHS256 {
+			return nil, fmt.Errorf("unexpected signing method %v", t.Header["alg"])
+		}
+		return m.secret, nil
+	})
+	if err != nil || !parsed.Valid || claims.Issuer != sessionIssuer {
+		return nil, nil, ErrInvalidSession
+	}
+
+	revoked, err := m.rdb.Exists(ctx, sessionRevokedPrefix+claims.ID).Result()
+	if err != nil {
+		return nil, nil, err
+	}
+	if revoked > 0 {
+		return nil, nil, ErrInvalidSession
+	}
+
+	return &Principal{
+		Subject: claims.Subject,
+		Name:    claims.Name,
+		Email:   claims.Email,
+		Method:  claims.Method,
+	}, claims, nil
+}
+
+// Revoke 注销会话，记录保留到令牌过期
+func (m *SessionManager) Revoke(ctx context.Context, claims *SessionClaims) error {
+	ttl := time.Until(claims.ExpiresAt.Time)
+	if ttl <= 0 {
+		return nil
+	}
+	return m.rdb.Set(ctx, sessionRevokedPrefix+claims.ID, 1, ttl).Err()
+}
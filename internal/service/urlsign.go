@@ -0,0 +1,122 @@
+package service
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"math/rand"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+)
+
+// URL 签名方式
+const (
+	URLSignNone = ""      // 不签名，返回 CDN 永久地址
+	URLSignOSS  = "oss"   // OSS 预签名 GET（使用 OSS 域名）
+	URLSignCDNA = "cdn_a" // 阿里云 CDN 鉴权 A 方式：?auth_key={timestamp}-{rand}-{uid}-{md5}
+	URLSignCDNB = "cdn_b" // 阿里云 CDN 鉴权 B 方式：/{YYYYMMDDHHMM}/{md5}/path
+	URLSignCDNC = "cdn_c" // 阿里云 CDN 鉴权 C 方式：/{md5}/{hex(timestamp)}/path
+)
+
+// CDN 鉴权 B 方式的时间使用东八区
+var cdnTimeZone = time.FixedZone("CST", 8*3600)
+
+// URLSignOptions URL 签名配置
+type URLSignOptions struct {
+	Mode    string
+	BaseURL string        // CDN 访问地址
+	TTL     time.Duration // 签名 URL 有效期
+	CDNKey  string        // CDN URL 鉴权主 KEY
+	// CDN 控制台配置的鉴权有效时长，CDN 以 timestamp + 有效时长 判断过期，
+	// 签名时将 timestamp 前移，使实际过期时间为 now + TTL
+	CDNValidity time.Duration
+}
+
+// URLSigner 生成壁纸访问地址，开启签名时返回限时有效的 URL
+type URLSigner struct {
+	opts   URLSignOptions
+	bucket *oss.Bucket
+}
+
+// NewURLSigner 创建 URL 签名器
+func NewURLSigner(opts URLSignOptions, bucket *oss.Bucket) (*URLSigner, error) {
+	switch opts.Mode {
+	case URLSignNone, URLSignOSS:
+	case URLSignCDNA, URLSignCDNB, URLSignCDNC:
+		if opts.CDNKey == "" {
+			return nil, fmt.Errorf("cdn key is required for url signing mode '%s'", opts.Mode)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported url signing mode '%s'", opts.Mode)
+	}
+	if opts.Mode != URLSignNone && opts.TTL <= 0 {
+		return nil, fmt.Errorf("url signing ttl must be positive")
+	}
+	if opts.CDNValidity <= 0 {
+		opts.CDNValidity = 30 * time.Minute
+	}
+	opts.BaseURL = strings.TrimRight(opts.BaseURL, "/")
+	return &URLSigner{opts: opts, bucket: bucket}, nil
+}
+
+// Signed 是否返回签名 URL
+func (s *URLSigner) Signed() bool {
+	return s.opts.Mode != URLSignNone
+}
+
+// WallpaperURL 壁纸访问地址
+func (s *URLSigner) WallpaperURL(deviceType string, filename string) (string, error) {
+	return s.ObjectURL(deviceType + "/" + filename)
+}
+
+// ObjectURL 对象访问地址，objectKey 如 pc/a.webp
+func (s *URLSigner) ObjectURL(objectKey string) (string, error) {
+	if s.opts.Mode == URLSignNone {
+		return fmt.Sprintf("%s/%s", s.opts.BaseURL, objectKey), nil
+	}
+	if s.opts.Mode == URLSignOSS {
+		signed, err := s.bucket.SignURL(objectKey, oss.HTTPGet, int64(s.opts.TTL.Seconds()))
+		if err != nil {
+			return "", fmt.Errorf("failed to sign url for '%s': %v", objectKey, err)
+		}
+		return signed, nil
+	}
+
+	// CDN 鉴权以请求中（URL 编码后）的路径计算签名
+	uri := "/" + escapeObjectKey(objectKey)
+	timestamp := time.Now().Add(s.opts.TTL - s.opts.CDNValidity)
+
+	switch s.opts.Mode {
+	case URLSignCDNA:
+		ts := strconv.FormatInt(timestamp.Unix(), 10)
+		nonce := strconv.FormatInt(rand.Int63(), 16)
+		hash := md5Hex(fmt.Sprintf("%s-%s-%s-0-%s", uri, ts, nonce, s.opts.CDNKey))
+		return fmt.Sprintf("%s%s?auth_key=%s-%s-0-%s", s.opts.BaseURL, uri, ts, nonce, hash), nil
+	case URLSignCDNB:
+		ts := timestamp.In(cdnTimeZone).Format("200601021504")
+		hash := md5Hex(s.opts.CDNKey + ts + uri)
+		return fmt.Sprintf("%s/%s/%s%s", s.opts.BaseURL, ts, hash, uri), nil
+	default: // URLSignCDNC
+		ts := strconv.FormatInt(timestamp.Unix(), 16)
+		hash := md5Hex(s.opts.CDNKey + uri + ts)
+		return fmt.Sprintf("%s/%s/%s%s", s.opts.BaseURL, hash, ts, uri), nil
+	}
+}
+
+// 对象 Key 按路径段编码
+func escapeObjectKey(objectKey string) string {
+	segments := strings.Split(objectKey, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+func md5Hex(s string) string {
+	sum := md5.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,139 @@
+package service
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewURLSigner(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    URLSignOptions
+		wantErr bool
+	}{
+		{name: "none", opts: URLSignOptions{}},
+		{name: "oss", opts: URLSignOptions{Mode: URLSignOSS, TTL: time.Minute}},
+		{name: "cdn a", opts: URLSignOptions{Mode: URLSignCDNA, TTL: time.Minute, CDNKey: "key"}},
+		{name: "cdn without key", opts: URLSignOptions{Mode: URLSignCDNB, TTL: time.Minute}, wantErr: true},
+		{name: "signed without ttl", opts: URLSignOptions{Mode: URLSignOSS}, wantErr: true},
+		{name: "unknown mode", opts: URLSignOptions{Mode: "cdn_d", TTL: time.Minute, CDNKey: "key"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewURLSigner(tt.opts, nil); (err != nil) != tt.wantErr {
+				t.Errorf("NewURLSigner() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestURLSignerCDN(t *testing.T) {
+	const (
+		baseURL = "https://cdn.example.com"
+		key     = "cdn-secret"
+		ttl     = 10 * time.Minute
+		// CDN 控制台的鉴权有效时长，签名时间戳 = now + TTL - validity
+		validity = 30 * time.Minute
+	)
+	// 路径按段编码后参与签名
+	const uri = "/pc/%E5%A3%81%E7%BA%B8%201.webp"
+
+	tests := []struct {
+		mode string
+		// 从签名 URL 中解析出时间戳、签名和路径，并返回期望的签名
+		verify func(t *testing.T, u *url.URL) (timestamp time.Time, hash string, want string)
+	}{
+		{
+			mode: URLSignCDNA,
+			verify: func(t *testing.T, u *url.URL) (time.Time, string, string) {
+				if u.EscapedPath() != uri {
+					t.Fatalf("path = %s, want %s", u.EscapedPath(), uri)
+				}
+				parts := strings.Split(u.Query().Get("auth_key"), "-")
+				if len(parts) != 4 || parts[2] != "0" {
+					t.Fatalf("auth_key = %s", u.Query().Get("auth_key"))
+				}
+				ts, err := strconv.ParseInt(parts[0], 10, 64)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return time.Unix(ts, 0), parts[3], md5Hex(uri + "-" + parts[0] + "-" + parts[1] + "-0-" + key)
+			},
+		},
+		{
+			mode: URLSignCDNB,
+			verify: func(t *testing.T, u *url.URL) (time.Time, string, string) {
+				parts := strings.SplitN(strings.TrimPrefix(u.EscapedPath(), "/"), "/", 3)
+				if len(parts) != 3 || "/"+parts[2] != uri {
+					t.Fatalf("path = %s", u.EscapedPath())
+				}
+				ts, err := time.ParseInLocation("200601021504", parts[0], cdnTimeZone)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return ts, parts[1], md5Hex(key + parts[0] + uri)
+			},
+		},
+		{
+			mode: URLSignCDNC,
+			verify: func(t *testing.T, u *url.URL) (time.Time, string, string) {
+				parts := strings.SplitN(strings.TrimPrefix(u.EscapedPath(), "/"), "/", 3)
+				if len(parts) != 3 || "/"+parts[2] != uri {
+					t.Fatalf("path = %s", u.EscapedPath())
+				}
+				ts, err := strconv.ParseInt(parts[1], 16, 64)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return time.Unix(ts, 0), parts[0], md5Hex(key + uri + parts[1])
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			signer, err := NewURLSigner(URLSignOptions{Mode: tt.mode, BaseURL: baseURL + "/", TTL: ttl, CDNKey: key, CDNValidity: validity}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			signed, err := signer.WallpaperURL("pc", "壁纸 1.webp")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(signed, baseURL+"/") || strings.HasPrefix(signed, baseURL+"//") {
+				t.Fatalf("signed url %s does not use base url", signed)
+			}
+			u, err := url.Parse(signed)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			timestamp, hash, want := tt.verify(t, u)
+			if hash != want {
+				t.Errorf("hash = %s, want %s", hash, want)
+			}
+			// B 方式精确到分钟
+			expected := time.Now().Add(ttl - validity)
+			if diff := timestamp.Sub(expected); diff > time.Second || diff < -time.Minute-time.Second {
+				t.Errorf("timestamp = %v, want about %v", timestamp, expected)
+			}
+		})
+	}
+}
+
+func TestURLSignerUnsigned(t *testing.T) {
+	signer, err := NewURLSigner(URLSignOptions{BaseURL: "https://cdn.example.com/"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signer.Signed() {
+		t.Error("Signed() = true for unsigned mode")
+	}
+	got, err := signer.WallpaperURL("mobile", "a.webp")
+	if err != nil || got != "https://cdn.example.com/mobile/a.webp" {
+		t.Errorf("WallpaperURL() = %s, %v", got, err)
+	}
+}
@@ -0,0 +1,230 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 用户相关 Redis Key
+const (
+	usersKey      = "user:accounts" // Hash：username -> User JSON
+	userEmailsKey = "user:email"    // Hash：email -> username，用于匹配 OIDC 登录用户
+)
+
+var (
+	// ErrUserNotFound 用户不存在
+	ErrUserNotFound = errors.New("user not found")
+	// ErrInvalidCredentials 用户名或密码错误
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	// ErrEmailInUse 邮箱已被其他用户使用
+	ErrEmailInUse = errors.New("email is already used by another user")
+)
+
+// User 管理用户，本地用户使用密码登录，OIDC 用户按邮箱匹配
+type User struct {
+	Username     string `json:"username"`
+	Name         string `json:"name,omitempty"`
+	Email        string `json:"email,omitempty"`
+	Role         string `json:"role"`
+	PasswordHash string `json:"passwordHash,omitempty"` // bcrypt 哈希，返回给客户端前清空
+	CreatedAt    int64  `json:"createdAt"`
+	UpdatedAt    int64  `json:"updatedAt"`
+}
+
+// Public 去掉密码哈希后的副本
+func (u User) Public() User {
+	u.PasswordHash = ""
+	return u
+}
+
+// UserService 用户和角色管理
+type UserService struct {
+	rdb         *redis.Client
+	defaultRole string
+}
+
+// NewUserService 创建用户服务，defaultRole 为未登记的 OIDC 用户的角色，为空表示拒绝
+func NewUserService(rdb *redis.Client, defaultRole string) *UserService {
+	return &UserService{rdb: rdb, defaultRole: defaultRole}
+}
+
+// 邮箱统一小写
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// Save 创建或更新用户，password 非空时更新密码
+func (s *UserService) Save(ctx context.Context, user User, password string) (*User, error) {
+	user.Username = strings.TrimSpace(user.Username)
+	user.Email = normalizeEmail(user.Email)
+	if user.Username == "" {
+		return nil, fmt.Errorf("username is required")
+	}
+	if !ValidateRole(user.Role) {
+		return nil, fmt.Errorf("invalid role '%s'", user.Role)
+	}
+
+	existing, err := s.Get(ctx, user.Username)
+	if err != nil && !errors.Is(err, ErrUserNotFound) {
+		return nil, err
+	}
+
+	now := time.Now().Unix()
+	user.CreatedAt = now
+	if existing != nil {
+		user.CreatedAt = existing.CreatedAt
+		user.PasswordHash = existing.PasswordHash
+	}
+	user.UpdatedAt = now
+
+	if password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, err
+		}
+		user.PasswordHash = string(hash)
+	}
+
+	if user.Email != "" {
+		owner, err := s.rdb.HGet(ctx, userEmailsKey, user.Email).Result()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return nil, err
+		}
+		if owner != "" && owner != user.Username {
+			return nil, ErrEmailInUse
+		}
+	}
+
+	data, err := json.Marshal(user)
+	if err != nil {
+		return nil, err
+	}
+
+	pipe := s.rdb.TxPipeline()
+	if existing != nil && existing.Email != "" && existing.Email != user.Email {
+		pipe.HDel(ctx, userEmailsKey, existing.Email)
+	}
+	if user.Email != "" {
+		pipe.HSet(ctx, userEmailsKey, user.Email, user.Username)
+	}
+	pipe.HSet(ctx, usersKey, user.Username, data)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to save user: %v", err)
+	}
+	return &user, nil
+}
+
+// Get 查询用户
+func (s *UserService) Get(ctx context.Context, username string) (*User, error) {
+	data, err := s.rdb.HGet(ctx, usersKey, username).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var user User
+	if err := json.Unmarshal(data, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// FindByEmail 按邮箱查询用户
+func (s *UserService) FindByEmail(ctx context.Context, email string) (*User, error) {
+	email = normalizeEmail(email)
+	if email == "" {
+		return nil, ErrUserNotFound
+	}
+	username, err := s.rdb.HGet(ctx, userEmailsKey, email).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, username)
+}
+
+// List 列出全部用户（不含密码哈希），按用户名排序
+func (s *UserService) List(ctx context.Context) ([]User, error) {
+	values, err := s.rdb.HGetAll(ctx, usersKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	users := make([]User, 0, len(values))
+	for _, data := range values {
+		var user User
+		if json.Unmarshal([]byte(data), &user) == nil {
+			users = append(users, user.Public())
+		}
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Username < users[j].Username })
+	return users, nil
+}
+
+// Delete 删除用户，已登录的会话在下次请求时失去权限
+func (s *UserService) Delete(ctx context.Context, username string) error {
+	user, err := s.Get(ctx, username)
+	if err != nil {
+		return err
+	}
+
+	pipe := s.rdb.TxPipeline()
+	pipe.HDel(ctx, usersKey, username)
+	if user.Email != "" {
+		pipe.HDel(ctx, userEmailsKey, user.Email)
+	}
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// Authenticate 校验本地用户的用户名和密码
+func (s *UserService) Authenticate(ctx context.Context, username string, password string) (*User, error) {
+	user, err := s.Get(ctx, username)
+	if errors.Is(err, ErrUserNotFound) {
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	if user.PasswordHash == "" || bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil {
+		return nil, ErrInvalidCredentials
+	}
+	return user, nil
+}
+
+// ResolveRole 查询登录用户当前的角色，每次请求实时查询，角色变更立即生效；无权限时返回空字符串
+func (s *UserService) ResolveRole(ctx context.Context, principal *Principal) (string, error) {
+	switch principal.Method {
+	case AuthMethodPassword:
+		user, err := s.Get(ctx, principal.Subject)
+		if errors.Is(err, ErrUserNotFound) {
+			return "", nil
+		}
+		if err != nil {
+			return "", err
+		}
+		return user.Role, nil
+	case AuthMethodOIDC:
+		user, err := s.FindByEmail(ctx, principal.Email)
+		if errors.Is(err, ErrUserNotFound) {
+			return s.defaultRole, nil
+		}
+		if err != nil {
+			return "", err
+		}
+		return user.Role, nil
+	}
+	return "", nil
+}
@@ -4,13 +4,17 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"github.com/TXM983/wallpaper-api-v1/internal/config"
 	"github.com/TXM983/wallpaper-api-v1/internal/logger"
+	"github.com/TXM983/wallpaper-api-v1/internal/metrics"
+	"github.com/TXM983/wallpaper-api-v1/internal/tracing"
 	"github.com/aliyun/aliyun-oss-go-sdk/oss"
 	"github.com/go-redis/redis/v8"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"math/rand"
 	"mime/multipart"
+	"net/url"
 	"path/filepath"
 	"strings"
 	"time"
@@ -38,50 +42,64 @@ var unlockScript = redis.NewScript(`
     end
 `)
 
-func GetRandomWallpaper(rdb *redis.Client, deviceType string) (string, error) {
-	ctx := context.Background()
+// GetRandomWallpaper 从随机缓存中取出一张壁纸，缓存为空时加锁填充，未抢到锁的请求等待填充完成
+func GetRandomWallpaper(ctx context.Context, rdb *redis.Client, deviceType string) (filename string, err error) {
+	ctx, span := tracing.Start(ctx, "wallpaper.random", attribute.String("wallpaper.device_type", deviceType))
+	defer func() {
+		metrics.ObserveRandomPop(deviceType, err)
+		tracing.End(span, err)
+	}()
+
 	keyOriginal := "wallpaper:" + deviceType     // 原始壁纸列表
 	keyCache := "wallpaper:cache:" + deviceType  // 缓存列表
 	lockKey := "lock:wallpaper:" + deviceType    // Redis 分布式锁
 	channel := "wallpaper_channel:" + deviceType // Pub/Sub 频道
 
+	log := logger.FromContext(ctx).WithField(logger.FieldDeviceType, deviceType)
+
+	// 加锁、填充、通知和释放锁不随请求取消而中断，否则客户端断开后锁会一直占用到过期，等待的请求全部超时；
+	// 请求上下文只用于 Span 和日志
+	refillCtx := logger.NewContext(trace.ContextWithSpan(context.Background(), span), log)
+
 	// 检查缓存是否存在
 	cacheExists, err := rdb.Exists(ctx, keyCache).Result()
 	if err != nil {
-		logger.LogErrorAsync(fmt.Sprintf("Error checking cache existence for key %s: %v", keyCache, err))
+		log.WithError(err).Errorf("Error checking cache existence for key %s", keyCache)
 		return "", err
 	}
-	logger.LogInfoAsync(fmt.Sprintf("Cache existence check for key %s: %v", keyCache, cacheExists))
+	log.Debugf("Cache existence check for key %s: %v", keyCache, cacheExists)
 
 	// 如果缓存为空，则重新填充
 	if cacheExists == 0 {
 		lockValue := uuid.New().String()
-		lockAcquired, err := rdb.SetNX(ctx, lockKey, lockValue, 5*time.Second).Result()
+		lockAcquired, err := rdb.SetNX(refillCtx, lockKey, lockValue, 5*time.Second).Result()
 		if err != nil {
-			logger.LogErrorAsync(fmt.Sprintf("Error acquiring lock %s: %v", lockKey, err))
+			log.WithError(err).Errorf("Error acquiring lock %s", lockKey)
 			return "", err
 		}
 
 		if lockAcquired {
 			// **使用 Lua 确保释放锁的原子性**
-			defer unlockScript.Run(ctx, rdb, []string{lockKey}, lockValue)
+			defer unlockScript.Run(refillCtx, rdb, []string{lockKey}, lockValue)
 
-			err = RefillCache(ctx, rdb, keyOriginal, keyCache)
+			err = RefillCache(refillCtx, rdb, keyOriginal, keyCache)
 			if err != nil {
 				return "", err
 			}
-			rdb.Publish(ctx, channel, "done") // 通知其他请求缓存已填充
+			rdb.Publish(refillCtx, channel, "done") // 通知其他请求缓存已填充
 		} else {
 			// **等待填充完成，最多等 3 秒，防止一直卡住**
-			sub := rdb.Subscribe(ctx, channel)
+			waitCtx, waitSpan := tracing.Start(ctx, "wallpaper.wait_refill")
+			sub := rdb.Subscribe(waitCtx, channel)
 			defer sub.Close()
 
-			ctxTimeout, cancel := context.WithTimeout(ctx, 3*time.Second)
+			ctxTimeout, cancel := context.WithTimeout(waitCtx, 3*time.Second)
 			defer cancel()
 
 			_, err := sub.ReceiveMessage(ctxTimeout)
+			tracing.End(waitSpan, err)
 			if err != nil {
-				logger.LogErrorAsync(fmt.Sprintf("Error waiting for cache refill: %v", err))
+				log.WithError(err).Error("Error waiting for cache refill")
 				return "", err
 			}
 		}
@@ -90,38 +108,45 @@ func GetRandomWallpaper(rdb *redis.Client, deviceType string) (string, error) {
 	// **使用 BLPop 代替 RPOP，避免并发竞争失败**
 	selectedWallpaper, err := rdb.BLPop(ctx, 2*time.Second, keyCache).Result()
 	if errors.Is(err, redis.Nil) {
-		logger.LogErrorAsync("Cache is empty, no wallpaper available.")
+		log.Error("Cache is empty, no wallpaper available.")
 		return "", fmt.Errorf("no wallpapers available in cache")
 	}
 	if err != nil {
-		logger.LogErrorAsync(fmt.Sprintf("Error fetching wallpaper from cache for device type %s: %v", deviceType, err))
+		log.WithError(err).Error("Error fetching wallpaper from cache")
 		return "", err
 	}
 
-	logger.LogInfoAsync(fmt.Sprintf("Successfully fetched wallpaper: %s", selectedWallpaper[1]))
+	log.Debugf("Successfully fetched wallpaper: %s", selectedWallpaper[1])
 
 	return selectedWallpaper[1], nil
 }
 
 // RefillCache **重置缓存**
-func RefillCache(ctx context.Context, rdb *redis.Client, keyOriginal, keyCache string) error {
+func RefillCache(ctx context.Context, rdb *redis.Client, keyOriginal, keyCache string) (err error) {
+	deviceType := strings.TrimPrefix(keyCache, "wallpaper:cache:")
+	ctx, span := tracing.Start(ctx, "wallpaper.refill_cache", attribute.String("wallpaper.device_type", deviceType))
+	start := time.Now()
+	defer func() {
+		metrics.ObserveCacheRefill(deviceType, metrics.RefillTriggerEmpty, start, err)
+		tracing.End(span, err)
+	}()
+
+	log := logger.FromContext(ctx).WithField(logger.FieldDeviceType, deviceType)
+
 	// 获取原始壁纸
-	logger.LogInfo(fmt.Sprintf("Refilling cache for key %s from original key %s", keyCache, keyOriginal))
+	log.Infof("Refilling cache for key %s from original key %s", keyCache, keyOriginal)
 	wallpapers, err := rdb.LRange(ctx, keyOriginal, 0, -1).Result()
 	if err != nil {
-		logger.LogErrorAsync(fmt.Sprintf("Error fetching original wallpapers for key %s: %v", keyOriginal, err))
+		log.WithError(err).Errorf("Error fetching original wallpapers for key %s", keyOriginal)
 		return err
 	}
 	if len(wallpapers) == 0 {
-		logger.LogErrorAsync(fmt.Sprintf("No wallpapers available for device type %s", keyOriginal))
+		log.Error("No wallpapers available")
 		return fmt.Errorf("no wallpapers available")
 	}
 
 	// 👇 在这里打乱顺序
-	r := rand.New(rand.NewSource(time.Now().UnixNano()))
-	r.Shuffle(len(wallpapers), func(i, j int) {
-		wallpapers[i], wallpapers[j] = wallpapers[j], wallpapers[i]
-	})
+	shuffleWallpapers(wallpapers)
 
 	// **使用事务保证原子性**
 	tx := rdb.TxPipeline()
@@ -129,14 +154,32 @@ func RefillCache(ctx context.Context, rdb *redis.Client, keyOriginal, keyCache s
 	tx.LPush(ctx, keyCache, stringSliceToInterfaceSlice(wallpapers)...) // **转换类型**
 	_, err = tx.Exec(ctx)
 	if err != nil {
-		logger.LogErrorAsync(fmt.Sprintf("Failed to refill cache for key %s: %v", keyCache, err))
+		log.WithError(err).Errorf("Failed to refill cache for key %s", keyCache)
 		return fmt.Errorf("failed to refill cache: %v", err)
 	}
 
-	logger.LogInfoAsync(fmt.Sprintf("Successfully refilled cache for key %s", keyCache))
+	log.WithField(logger.FieldLatency, float64(time.Since(start).Microseconds())/1000).Infof("Successfully refilled cache for key %s", keyCache)
 	return nil
 }
 
+// 随机打乱壁纸顺序
+func shuffleWallpapers(wallpapers []string) {
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	r.Shuffle(len(wallpapers), func(i, j int) {
+		wallpapers[i], wallpapers[j] = wallpapers[j], wallpapers[i]
+	})
+}
+
+// 执行一次对象存储调用，记录 Span、耗时和错误
+func storageCall(ctx context.Context, operation string, bucket *oss.Bucket, key string, call func() error) error {
+	_, span := tracing.StartStorage(ctx, operation, bucket.BucketName, key)
+	start := time.Now()
+	err := call()
+	metrics.ObserveStorage(operation, start, err)
+	tracing.End(span, err)
+	return err
+}
+
 // IsImageFile 检查文件是否是图片
 func IsImageFile(filename string) bool {
 	// 简单检查文件扩展名是否为图片格式
@@ -144,8 +187,8 @@ func IsImageFile(filename string) bool {
 	return ext == ".jpg" || ext == ".jpeg" || ext == ".png" || ext == ".gif" || ext == ".bmp" || ext == ".webp"
 }
 
-// UploadToOSS 将图片上传到OSS并返回URL
-func UploadToOSS(file *multipart.FileHeader, bucket *oss.Bucket, appConfig *config.AppConfig, deviceType string) (string, error) {
+// UploadToOSS 将图片上传到OSS并返回URL，上传者同时写入对象元数据
+func UploadToOSS(ctx context.Context, file *multipart.FileHeader, bucket *oss.Bucket, signer *URLSigner, deviceType string, uploader string) (string, error) {
 	// 打开上传的文件
 	src, err := file.Open()
 	if err != nil {
@@ -157,23 +200,32 @@ func UploadToOSS(file *multipart.FileHeader, bucket *oss.Bucket, appConfig *conf
 	ossFilePath := fmt.Sprintf("%s/%s", deviceType, file.Filename)
 
 	// 上传文件到OSS
-	err = bucket.PutObject(ossFilePath, src)
+	var options []oss.Option
+	if uploader != "" {
+		// 自定义元数据只支持 ASCII，编码后写入
+		options = append(options, oss.Meta(ossMetaUploader, url.QueryEscape(uploader)))
+	}
+	err = storageCall(ctx, "put_object", bucket, ossFilePath, func() error {
+		return bucket.PutObject(ossFilePath, src, options...)
+	})
 	if err != nil {
 		return "", fmt.Errorf("failed to upload file to OSS: %v", err)
 	}
+	metrics.ObserveUpload(deviceType, file.Size)
 
 	// 返回OSS文件URL
-	ossFileURL := fmt.Sprintf("%s/%s", appConfig.CDN.BaseURL, ossFilePath)
-	return ossFileURL, nil
+	return signer.ObjectURL(ossFilePath)
 }
 
 // DeleteFromOSS 从OSS中删除指定文件
-func DeleteFromOSS(fileName string, deviceType string, bucket *oss.Bucket) error {
+func DeleteFromOSS(ctx context.Context, fileName string, deviceType string, bucket *oss.Bucket) error {
 	// 根据 deviceType 和文件名生成文件的路径
 	ossFilePath := fmt.Sprintf("%s/%s", deviceType, fileName)
 
 	// 删除OSS中的文件
-	err := bucket.DeleteObject(ossFilePath)
+	err := storageCall(ctx, "delete_object", bucket, ossFilePath, func() error {
+		return bucket.DeleteObject(ossFilePath)
+	})
 	if err != nil {
 		return fmt.Errorf("failed to delete file '%s' from OSS: %v", ossFilePath, err)
 	}
@@ -216,6 +268,18 @@ func AddToRandomWallpaperCache(fileName string, rdb *redis.Client, deviceType st
 	return nil
 }
 
+// WallpaperExists 判断壁纸是否在壁纸列表中
+func WallpaperExists(ctx context.Context, rdb *redis.Client, deviceType string, fileName string) (bool, error) {
+	_, err := rdb.LPos(ctx, "wallpaper:"+deviceType, fileName, redis.LPosArgs{}).Result()
+	if errors.Is(err, redis.Nil) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
 // RemoveFromWallpaperCache 从壁纸缓存中删除指定文件
 func RemoveFromWallpaperCache(fileName string, rdb *redis.Client, deviceType string) error {
 	// 删除指定文件在壁纸缓存中的所有条目（最多删除 1 个）
@@ -237,8 +301,68 @@ func RemoveFromRandomWallpaperCache(fileName string, rdb *redis.Client, deviceTy
 	return nil
 }
 
+// ListWallpaperFilenames 列出 OSS 中指定 deviceType 下的所有壁纸文件名
+func ListWallpaperFilenames(ctx context.Context, bucket *oss.Bucket, deviceType string) ([]string, error) {
+	return ListWallpaperFilenamesWithProgress(ctx, bucket, deviceType, nil)
+}
+
+// ListWallpaperFilenamesWithProgress 列出壁纸文件名，每列举完一页回调一次已列举数量
+func ListWallpaperFilenamesWithProgress(ctx context.Context, bucket *oss.Bucket, deviceType string, onProgress func(listed int)) ([]string, error) {
+	prefix := deviceType + "/"
+	marker := ""
+	var filenames []string
+
+	for {
+		// 退出时停止列举（OSS SDK 不支持 context，只能在分页之间检查）
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		// 每次最多获取 1000 个文件
+		var objects oss.ListObjectsResult
+		err := storageCall(ctx, "list_objects", bucket, prefix, func() (err error) {
+			objects, err = bucket.ListObjects(oss.Marker(marker), oss.Prefix(prefix), oss.MaxKeys(1000))
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list objects for %s: %v", prefix, err)
+		}
+
+		// 筛选有效文件
+		for _, object := range objects.Objects {
+			if strings.HasSuffix(object.Key, ".alist") {
+				continue // 跳过 .alist 文件
+			}
+			filename := getFilenameFromKey(object.Key)
+			if filename == "" {
+				continue // 跳过目录占位对象
+			}
+			filenames = append(filenames, filename)
+		}
+
+		if onProgress != nil {
+			onProgress(len(filenames))
+		}
+
+		// 检查是否还有更多文件
+		if objects.IsTruncated {
+			marker = objects.NextMarker
+		} else {
+			break
+		}
+	}
+
+	return filenames, nil
+}
+
+// 辅助函数，用于从对象Key中提取文件名
+func getFilenameFromKey(objectKey string) string {
+	parts := strings.Split(objectKey, "/")
+	return parts[len(parts)-1]
+}
+
 // GetWallpaperURLsFromOSS 获取指定 deviceType 下所有图片的 URL
-func GetWallpaperURLsFromOSS(bucket *oss.Bucket, deviceType string, appConfig *config.AppConfig) ([]string, error) {
+func GetWallpaperURLsFromOSS(ctx context.Context, bucket *oss.Bucket, deviceType string, signer *URLSigner) ([]string, error) {
 
 	// 列举指定目录下的所有图片文件
 	prefix := deviceType + "/"
@@ -247,7 +371,11 @@ func GetWallpaperURLsFromOSS(bucket *oss.Bucket, deviceType string, appConfig *c
 
 	for {
 		// 列出文件（最多 1000 个）
-		result, err := bucket.ListObjects(oss.Prefix(prefix), oss.Marker(marker), oss.MaxKeys(1000))
+		var result oss.ListObjectsResult
+		err := storageCall(ctx, "list_objects", bucket, prefix, func() (err error) {
+			result, err = bucket.ListObjects(oss.Prefix(prefix), oss.Marker(marker), oss.MaxKeys(1000))
+			return err
+		})
 		if err != nil {
 			return nil, fmt.Errorf("failed to list objects: %v", err)
 		}
@@ -257,7 +385,10 @@ func GetWallpaperURLsFromOSS(bucket *oss.Bucket, deviceType string, appConfig *c
 			if strings.HasSuffix(object.Key, ".alist") {
 				continue // 跳过 .alist 文件
 			}
-			fileURL := fmt.Sprintf("%s/%s", appConfig.CDN.BaseURL, object.Key)
+			fileURL, err := signer.ObjectURL(object.Key)
+			if err != nil {
+				return nil, err
+			}
 			fileURLs = append(fileURLs, fileURL)
 		}
 
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// 执行指定命令后取消请求上下文，模拟客户端在填充缓存期间断开
+type cancelAfterHook struct {
+	command string
+	cancel  context.CancelFunc
+}
+
+func (h cancelAfterHook) BeforeProcess(ctx context.Context, _ redis.Cmder) (context.Context, error) {
+	return ctx, nil
+}
+
+func (h cancelAfterHook) AfterProcess(_ context.Context, cmd redis.Cmder) error {
+	if cmd.Name() == h.command {
+		h.cancel()
+	}
+	return nil
+}
+
+func (h cancelAfterHook) BeforeProcessPipeline(ctx context.Context, _ []redis.Cmder) (context.Context, error) {
+	return ctx, nil
+}
+
+func (h cancelAfterHook) AfterProcessPipeline(context.Context, []redis.Cmder) error {
+	return nil
+}
+
+func TestGetRandomWallpaperRefillsAfterRequestCanceled(t *testing.T) {
+	mr, rdb := newTestRedis(t)
+	mr.RPush("wallpaper:pc", "a.webp", "b.webp")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	rdb.AddHook(cancelAfterHook{command: "exists", cancel: cancel})
+
+	if _, err := GetRandomWallpaper(ctx, rdb, "pc"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("GetRandomWallpaper() error = %v, want context.Canceled", err)
+	}
+
+	// 请求取消后缓存仍然填充完成，锁已释放
+	if mr.Exists("lock:wallpaper:pc") {
+		t.Error("refill lock was not released")
+	}
+	cached, err := mr.List("wallpaper:cache:pc")
+	if err != nil || len(cached) != 2 {
+		t.Errorf("cache = %v, %v, want 2 wallpapers", cached, err)
+	}
+}
@@ -0,0 +1,483 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/TXM983/wallpaper-api-v1/internal/logger"
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+// Webhook 相关 Redis Key
+const (
+	webhookSubscriptionsKey = "webhook:subscriptions"   // Hash：id -> Webhook JSON
+	webhookQueueKey         = "webhook:queue"           // List：待投递任务
+	webhookRetryKey         = "webhook:retry"           // ZSet：待重试任务，score 为下次投递时间（毫秒）
+	webhookDeadLetterKey    = "webhook:dead_letter"     // List：超过重试次数的任务
+	webhookDeliveryLogKey   = "webhook:deliveries:"     // List：每个 Webhook 的投递记录
+	webhookDeliveryLogLimit = 200                       // 每个 Webhook 保留的投递记录数
+	webhookDeadLetterLimit  = 1000                      // 死信列表保留条数
+	webhookMaxBackoff       = time.Hour                 // 重试间隔上限
+	webhookRetryPollPeriod  = time.Second               // 重试队列扫描间隔
+	webhookResponseBodyMax  = 1024                      // 投递记录中保存的响应体长度
+	webhookSignaturePrefix  = "sha256="                 // 签名头前缀
+	webhookUserAgent        = "wallpaper-api-webhook/1" // 投递请求 User-Agent
+)
+
+// Webhook 请求头
+const (
+	WebhookHeaderEvent     = "X-Wallpaper-Event"
+	WebhookHeaderDelivery  = "X-Wallpaper-Delivery"
+	WebhookHeaderTimestamp = "X-Wallpaper-Timestamp"
+	WebhookHeaderSignature = "X-Wallpaper-Signature"
+)
+
+// ErrWebhookNotFound Webhook 不存在
+var ErrWebhookNotFound = errors.New("webhook not found")
+
+// Webhook 订阅
+type Webhook struct {
+	ID        string      `json:"id"`
+	URL       string      `json:"url"`
+	Secret    string      `json:"secret,omitempty"`
+	Events    []EventType `json:"events"` // 为空表示订阅全部事件
+	CreatedAt int64       `json:"createdAt"`
+}
+
+// 判断 Webhook 是否订阅了该事件
+func (w *Webhook) subscribes(eventType EventType) bool {
+	if len(w.Events) == 0 {
+		return true
+	}
+	for _, t := range w.Events {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery 一次投递任务
+type WebhookDelivery struct {
+	ID        string       `json:"id"`
+	WebhookID string       `json:"webhookId"`
+	Event     LibraryEvent `json:"event"`
+	Attempt   int          `json:"attempt"`             // 已尝试次数
+	LastError string       `json:"lastError,omitempty"` // 最后一次失败原因
+	FailedAt  int64        `json:"failedAt,omitempty"`  // 进入死信的时间
+}
+
+// WebhookDeliveryLog 投递记录
+type WebhookDeliveryLog struct {
+	DeliveryID  string    `json:"deliveryId"`
+	WebhookID   string    `json:"webhookId"`
+	EventID     string    `json:"eventId"`
+	EventType   EventType `json:"eventType"`
+	Attempt     int       `json:"attempt"`
+	Success     bool      `json:"success"`
+	StatusCode  int       `json:"statusCode,omitempty"`
+	Response    string    `json:"response,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	DurationMs  int64     `json:"durationMs"`
+	NextRetryAt int64     `json:"nextRetryAt,omitempty"`
+	DeadLetter  bool      `json:"deadLetter,omitempty"`
+	Timestamp   int64     `json:"timestamp"`
+}
+
+// WebhookOptions 投递参数
+type WebhookOptions struct {
+	Workers     int           // 并发投递协程数
+	MaxAttempts int           // 最大尝试次数，超过后进入死信
+	Timeout     time.Duration // 单次请求超时
+	BaseBackoff time.Duration // 首次重试间隔，之后指数增长
+}
+
+// WebhookService Webhook 订阅管理与投递
+type WebhookService struct {
+	rdb    *redis.Client
+	client *http.Client
+	opts   WebhookOptions
+}
+
+// NewWebhookService 创建 Webhook 服务
+func NewWebhookService(rdb *redis.Client, opts WebhookOptions) *WebhookService {
+	if opts.Workers <= 0 {
+		opts.Workers = 2
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 5
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	if opts.BaseBackoff <= 0 {
+		opts.BaseBackoff = 5 * time.Second
+	}
+	return &WebhookService{
+		rdb:    rdb,
+		client: &http.Client{Timeout: opts.Timeout},
+		opts:   opts,
+	}
+}
+
+// SignWebhookPayload 计算签名：HMAC-SHA256(secret, timestamp + "." + body)，接收方按同样方式校验
+func SignWebhookPayload(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return webhookSignaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// 生成随机签名密钥
+func generateWebhookSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// ValidateEventType 校验事件类型
+func ValidateEventType(eventType EventType) bool {
+	return eventType == EventWallpaperCreated || eventType == EventWallpaperDeleted || eventType == EventCacheRebuilt
+}
+
+// Create 创建 Webhook 订阅，secret 为空时自动生成
+func (s *WebhookService) Create(ctx context.Context, rawURL string, secret string, events []EventType) (*Webhook, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid webhook url '%s'", rawURL)
+	}
+	for _, t := range events {
+		if !ValidateEventType(t) {
+			return nil, fmt.Errorf("unsupported event type '%s'", t)
+		}
+	}
+	if secret == "" {
+		if secret, err = generateWebhookSecret(); err != nil {
+			return nil, fmt.Errorf("failed to generate webhook secret: %v", err)
+		}
+	}
+
+	webhook := &Webhook{
+		ID:        uuid.New().String(),
+		URL:       u.String(),
+		Secret:    secret,
+		Events:    events,
+		CreatedAt: time.Now().Unix(),
+	}
+	data, err := json.Marshal(webhook)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.rdb.HSet(ctx, webhookSubscriptionsKey, webhook.ID, data).Err(); err != nil {
+		return nil, fmt.Errorf("failed to save webhook: %v", err)
+	}
+	return webhook, nil
+}
+
+// Get 获取 Webhook（包含密钥）
+func (s *WebhookService) Get(ctx context.Context, id string) (*Webhook, error) {
+	data, err := s.rdb.HGet(ctx, webhookSubscriptionsKey, id).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrWebhookNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var webhook Webhook
+	if err := json.Unmarshal([]byte(data), &webhook); err != nil {
+		return nil, fmt.Errorf("failed to decode webhook %s: %v", id, err)
+	}
+	return &webhook, nil
+}
+
+// List 列出所有 Webhook（包含密钥，对外返回前需自行隐藏）
+func (s *WebhookService) List(ctx context.Context) ([]Webhook, error) {
+	values, err := s.rdb.HGetAll(ctx, webhookSubscriptionsKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	webhooks := make([]Webhook, 0, len(values))
+	for id, data := range values {
+		var webhook Webhook
+		if err := json.Unmarshal([]byte(data), &webhook); err != nil {
+			logger.LogError("Error decoding webhook %s: %v", id, err)
+			continue
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	return webhooks, nil
+}
+
+// Delete 删除 Webhook 及其投递记录
+func (s *WebhookService) Delete(ctx context.Context, id string) error {
+	removed, err := s.rdb.HDel(ctx, webhookSubscriptionsKey, id).Result()
+	if err != nil {
+		return err
+	}
+	if removed == 0 {
+		return ErrWebhookNotFound
+	}
+	return s.rdb.Del(ctx, webhookDeliveryLogKey+id).Err()
+}
+
+// Enqueue 为订阅了该事件的 Webhook 创建投递任务
+// 任务放入 Redis 队列，由任意实例的投递协程处理，保证多实例下只投递一次
+func (s *WebhookService) Enqueue(ctx context.Context, event LibraryEvent) error {
+	webhooks, err := s.List(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list webhooks: %v", err)
+	}
+
+	var jobs []interface{}
+	for i := range webhooks {
+		if !webhooks[i].subscribes(event.Type) {
+			continue
+		}
+		data, err := json.Marshal(WebhookDelivery{
+			ID:        uuid.New().String(),
+			WebhookID: webhooks[i].ID,
+			Event:     event,
+		})
+		if err != nil {
+			return err
+		}
+		jobs = append(jobs, data)
+	}
+
+	if len(jobs) == 0 {
+		return nil
+	}
+	return s.rdb.LPush(ctx, webhookQueueKey, jobs...).Err()
+}
+
+// Deliveries 获取 Webhook 最近的投递记录
+func (s *WebhookService) Deliveries(ctx context.Context, id string, limit int64) ([]WebhookDeliveryLog, error) {
+	values, err := s.rdb.LRange(ctx, webhookDeliveryLogKey+id, 0, limit-1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	logs := make([]WebhookDeliveryLog, 0, len(values))
+	for _, data := range values {
+		var entry WebhookDeliveryLog
+		if err := json.Unmarshal([]byte(data), &entry); err != nil {
+			continue
+		}
+		logs = append(logs, entry)
+	}
+	return logs, nil
+}
+
+// DeadLetters 获取死信列表
+func (s *WebhookService) DeadLetters(ctx context.Context, limit int64) ([]WebhookDelivery, error) {
+	values, err := s.rdb.LRange(ctx, webhookDeadLetterKey, 0, limit-1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	deliveries := make([]WebhookDelivery, 0, len(values))
+	for _, data := range values {
+		var delivery WebhookDelivery
+		if err := json.Unmarshal([]byte(data), &delivery); err != nil {
+			continue
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, nil
+}
+
+// Run 启动投递协程和重试调度，直到 ctx 结束；返回前等待进行中的投递完成
+func (s *WebhookService) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < s.opts.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.worker(ctx)
+		}()
+	}
+	s.scheduleRetries(ctx)
+	wg.Wait()
+}
+
+// 从队列中取任务并投递
+func (s *WebhookService) worker(ctx context.Context) {
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+
+		result, err := s.rdb.BRPop(ctx, 2*time.Second, webhookQueueKey).Result()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			logger.LogError("Error reading webhook queue: %v", err)
+			time.Sleep(time.Second)
+			continue
+		}
+
+		var delivery WebhookDelivery
+		if err := json.Unmarshal([]byte(result[1]), &delivery); err != nil {
+			logger.LogError("Error decoding webhook delivery: %v", err)
+			continue
+		}
+		// 已取出的任务不随 ctx 取消，投递完成（受请求超时限制）后再退出，避免丢失
+		s.deliver(context.Background(), &delivery)
+	}
+}
+
+// 定期把到期的重试任务移回队列
+func (s *WebhookService) scheduleRetries(ctx context.Context) {
+	ticker := time.NewTicker(webhookRetryPollPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+			due, err := s.rdb.ZRangeByScore(ctx, webhookRetryKey, &redis.ZRangeBy{Min: "-inf", Max: now, Count: 100}).Result()
+			if err != nil {
+				continue
+			}
+			for _, data := range due {
+				// ZRem 成功的实例负责重新入队，避免多实例重复投递
+				removed, err := s.rdb.ZRem(ctx, webhookRetryKey, data).Result()
+				if err != nil || removed == 0 {
+					continue
+				}
+				s.rdb.LPush(ctx, webhookQueueKey, data)
+			}
+		}
+	}
+}
+
+// 投递一次，失败时按指数退避重试或进入死信
+func (s *WebhookService) deliver(ctx context.Context, delivery *WebhookDelivery) {
+	webhook, err := s.Get(ctx, delivery.WebhookID)
+	if errors.Is(err, ErrWebhookNotFound) {
+		return // Webhook 已删除，丢弃任务
+	}
+	if err != nil {
+		logger.LogError("Error loading webhook %s: %v", delivery.WebhookID, err)
+		return
+	}
+
+	delivery.Attempt++
+	entry := WebhookDeliveryLog{
+		DeliveryID: delivery.ID,
+		WebhookID:  webhook.ID,
+		EventID:    delivery.Event.ID,
+		EventType:  delivery.Event.Type,
+		Attempt:    delivery.Attempt,
+		Timestamp:  time.Now().Unix(),
+	}
+
+	start := time.Now()
+	statusCode, response, err := s.post(ctx, webhook, delivery)
+	entry.DurationMs = time.Since(start).Milliseconds()
+	entry.StatusCode = statusCode
+	entry.Response = response
+
+	if err == nil {
+		entry.Success = true
+		logger.LogInfo("Delivered webhook %s event %s (attempt %d)", webhook.ID, delivery.Event.Type, delivery.Attempt)
+	} else {
+		entry.Error = err.Error()
+		delivery.LastError = err.Error()
+		logger.LogError("Failed to deliver webhook %s event %s (attempt %d): %v", webhook.ID, delivery.Event.Type, delivery.Attempt, err)
+
+		if delivery.Attempt >= s.opts.MaxAttempts {
+			entry.DeadLetter = true
+			delivery.FailedAt = time.Now().Unix()
+			if data, err := json.Marshal(delivery); err == nil {
+				pipe := s.rdb.TxPipeline()
+				pipe.LPush(ctx, webhookDeadLetterKey, data)
+				pipe.LTrim(ctx, webhookDeadLetterKey, 0, webhookDeadLetterLimit-1)
+				pipe.Exec(ctx)
+			}
+		} else {
+			nextRetry := time.Now().Add(s.backoff(delivery.Attempt))
+			entry.NextRetryAt = nextRetry.Unix()
+			if data, err := json.Marshal(delivery); err == nil {
+				s.rdb.ZAdd(ctx, webhookRetryKey, &redis.Z{Score: float64(nextRetry.UnixMilli()), Member: data})
+			}
+		}
+	}
+
+	if data, err := json.Marshal(entry); err == nil {
+		key := webhookDeliveryLogKey + webhook.ID
+		pipe := s.rdb.TxPipeline()
+		pipe.LPush(ctx, key, data)
+		pipe.LTrim(ctx, key, 0, webhookDeliveryLogLimit-1)
+		pipe.Exec(ctx)
+	}
+}
+
+// 第 attempt 次失败后的重试间隔：BaseBackoff * 2^(attempt-1)，不超过上限
+func (s *WebhookService) backoff(attempt int) time.Duration {
+	d := s.opts.BaseBackoff
+	for i := 1; i < attempt && d < webhookMaxBackoff; i++ {
+		d *= 2
+	}
+	if d > webhookMaxBackoff {
+		d = webhookMaxBackoff
+	}
+	return d
+}
+
+// 发送签名请求，非 2xx 视为失败
+func (s *WebhookService) post(ctx context.Context, webhook *Webhook, delivery *WebhookDelivery) (int, string, error) {
+	body, err := json.Marshal(delivery.Event)
+	if err != nil {
+		return 0, "", err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", webhookUserAgent)
+	req.Header.Set(WebhookHeaderEvent, string(delivery.Event.Type))
+	req.Header.Set(WebhookHeaderDelivery, delivery.ID)
+	req.Header.Set(WebhookHeaderTimestamp, timestamp)
+	req.Header.Set(WebhookHeaderSignature, SignWebhookPayload(webhook.Secret, timestamp, body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, webhookResponseBodyMax))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, string(respBody), fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return resp.StatusCode, string(respBody), nil
+}
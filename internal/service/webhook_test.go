@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSignWebhookPayload(t *testing.T) {
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp string
+		body      string
+		want      string
+	}{
+		{
+			name:      "payload",
+			secret:    "secret",
+			timestamp: "1700000000",
+			body:      `{"a":1}`,
+			want:      "sha256=49f24e537407743fa4a0242bb63b94b9a47ee99cbbe071ccd8a22550ae411686",
+		},
+		{
+			name:      "empty secret and body",
+			timestamp: "0",
+			want:      "sha256=b849d5a581847b281957065739df36df2463d1977ea8d6e1e4e6cf33fadc68c3",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SignWebhookPayload(tt.secret, tt.timestamp, []byte(tt.body)); got != tt.want {
+				t.Errorf("SignWebhookPayload() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	// 密钥、时间戳或请求体任一变化都会改变签名
+	base := SignWebhookPayload("secret", "1700000000", []byte(`{"a":1}`))
+	for _, other := range []string{
+		SignWebhookPayload("secret2", "1700000000", []byte(`{"a":1}`)),
+		SignWebhookPayload("secret", "1700000001", []byte(`{"a":1}`)),
+		SignWebhookPayload("secret", "1700000000", []byte(`{"a":2}`)),
+		// 时间戳与请求体之间有分隔符，不能通过移动边界伪造
+		SignWebhookPayload("secret", "170000000", []byte(`0{"a":1}`)),
+	} {
+		if other == base {
+			t.Errorf("signature %s collides with base", other)
+		}
+	}
+}
+
+func TestWebhookPostSignsRequest(t *testing.T) {
+	var (
+		gotHeader http.Header
+		gotBody   []byte
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHeader = r.Header.Clone()
+		gotBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	s := NewWebhookService(nil, WebhookOptions{})
+	webhook := &Webhook{ID: "hook", URL: server.URL, Secret: "secret"}
+	delivery := &WebhookDelivery{ID: "delivery", WebhookID: "hook", Event: LibraryEvent{ID: "event", Type: EventWallpaperCreated, DeviceType: "pc", Files: []string{"a.webp"}}}
+
+	status, _, err := s.post(context.Background(), webhook, delivery)
+	if err != nil || status != http.StatusNoContent {
+		t.Fatalf("post() = %d, %v", status, err)
+	}
+
+	timestamp := gotHeader.Get(WebhookHeaderTimestamp)
+	if ts, err := strconv.ParseInt(timestamp, 10, 64); err != nil || time.Since(time.Unix(ts, 0)) > time.Minute {
+		t.Errorf("timestamp header = %q", timestamp)
+	}
+	if got, want := gotHeader.Get(WebhookHeaderSignature), SignWebhookPayload("secret", timestamp, gotBody); got != want {
+		t.Errorf("signature header = %s, want %s", got, want)
+	}
+	if gotHeader.Get(WebhookHeaderEvent) != string(EventWallpaperCreated) || gotHeader.Get(WebhookHeaderDelivery) != "delivery" {
+		t.Errorf("event headers = %v", gotHeader)
+	}
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	s := NewWebhookService(nil, WebhookOptions{BaseBackoff: 5 * time.Second})
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, 5 * time.Second},
+		{2, 10 * time.Second},
+		{3, 20 * time.Second},
+		{10, 2560 * time.Second},
+		{11, webhookMaxBackoff},
+		{100, webhookMaxBackoff},
+	}
+	for _, tt := range tests {
+		if got := s.backoff(tt.attempt); got != tt.want {
+			t.Errorf("backoff(%d) = %v, want %v", tt.attempt, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// 本服务的 instrumentation 名称
+const instrumentationName = "github.com/TXM983/wallpaper-api-v1"
+
+// Options 链路追踪配置
+type Options struct {
+	Enabled     bool
+	ServiceName string
+	Endpoint    string            // OTLP/HTTP 地址（host:port），为空时使用 OTEL_EXPORTER_OTLP_ENDPOINT 或 localhost:4318
+	Insecure    bool              // 使用 HTTP 而非 HTTPS，本地 Collector 一般开启
+	Headers     map[string]string // 导出时附加的请求头，如鉴权
+	SampleRatio float64           // 根 Span 采样率（0-1），上游已采样的请求始终跟随
+}
+
+// Init 初始化全局 TracerProvider 和 W3C traceparent/baggage 传播，返回退出时调用的 shutdown
+// 未开启时只设置传播器，Span 为空实现，开销可忽略
+func Init(ctx context.Context, opts Options) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if !opts.Enabled {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	var exporterOpts []otlptracehttp.Option
+	if opts.Endpoint != "" {
+		exporterOpts = append(exporterOpts, otlptracehttp.WithEndpoint(opts.Endpoint))
+	}
+	if opts.Insecure {
+		exporterOpts = append(exporterOpts, otlptracehttp.WithInsecure())
+	}
+	if len(opts.Headers) > 0 {
+		exporterOpts = append(exporterOpts, otlptracehttp.WithHeaders(opts.Headers))
+	}
+	exporter, err := otlptracehttp.New(ctx, exporterOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create otlp exporter: %v", err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(opts.ServiceName)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace resource: %v", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opts.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Tracer 本服务的 Tracer
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Start 开始一个内部 Span
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// StartStorage 开始一个对象存储调用的 Span
+func StartStorage(ctx context.Context, operation string, bucket string, key string) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, "oss "+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("storage.system", "aliyun_oss"),
+			attribute.String("storage.operation", operation),
+			attribute.String("storage.bucket", bucket),
+			attribute.String("storage.key", key),
+		))
+}
+
+// End 结束 Span，出错时记录错误
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// RedisHook go-redis 钩子，每条命令（或每个 Pipeline）一个客户端 Span，不记录参数避免泄露数据
+type RedisHook struct {
+	Addr string
+	DB   int
+}
+
+func (h RedisHook) attrs(operation string) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		semconv.DBSystemRedis,
+		semconv.DBOperation(operation),
+		semconv.DBRedisDBIndex(h.DB),
+		attribute.String("net.peer.name", h.Addr),
+	}
+}
+
+func (h RedisHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	if !trace.SpanFromContext(ctx).IsRecording() {
+		return ctx, nil
+	}
+	ctx, _ = Tracer().Start(ctx, "redis "+cmd.Name(), trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(h.attrs(cmd.Name())...))
+	return ctx, nil
+}
+
+func (h RedisHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	endRedisSpan(ctx, cmd.Err())
+	return nil
+}
+
+func (h RedisHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	if !trace.SpanFromContext(ctx).IsRecording() {
+		return ctx, nil
+	}
+	names := make([]string, len(cmds))
+	for i, cmd := range cmds {
+		names[i] = cmd.Name()
+	}
+	attrs := append(h.attrs("pipeline"),
+		attribute.Int("db.redis.num_cmd", len(cmds)),
+		attribute.String("db.redis.commands", strings.Join(names, " ")))
+	ctx, _ = Tracer().Start(ctx, "redis pipeline", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+	return ctx, nil
+}
+
+func (h RedisHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	var firstErr error
+	for _, cmd := range cmds {
+		if err := cmd.Err(); err != nil && !errors.Is(err, redis.Nil) {
+			firstErr = err
+			break
+		}
+	}
+	endRedisSpan(ctx, firstErr)
+	return nil
+}
+
+// 结束钩子中创建的 Span，redis.Nil 不视为错误
+func endRedisSpan(ctx context.Context, err error) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+	if errors.Is(err, redis.Nil) {
+		err = nil
+	}
+	End(span, err)
+}
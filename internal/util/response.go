@@ -33,6 +33,16 @@ func SuccessResponseNoData(c *gin.Context, message string) {
 	})
 }
 
+// AcceptedResponse 异步任务已受理响应（202）
+func AcceptedResponse(c *gin.Context, message string, data interface{}) {
+	c.JSON(http.StatusAccepted, ApiResponse{
+		Code:    http.StatusAccepted,
+		Status:  "success",
+		Message: message,
+		Data:    data,
+	})
+}
+
 // ErrorResponse 错误响应
 func ErrorResponse(c *gin.Context, code int, error string, message string) {
 	c.JSON(code, ApiResponse{
@@ -43,6 +53,17 @@ func ErrorResponse(c *gin.Context, code int, error string, message string) {
 	})
 }
 
+// ErrorResponseWithData 错误响应，附带机器可读的数据
+func ErrorResponseWithData(c *gin.Context, code int, error string, message string, data interface{}) {
+	c.JSON(code, ApiResponse{
+		Code:    code,
+		Status:  "error",
+		Message: message,
+		Error:   error,
+		Data:    data,
+	})
+}
+
 // ErrorResponseNoError 错误响应无error
 func ErrorResponseNoError(c *gin.Context, code int, message string) {
 	c.JSON(code, ApiResponse{